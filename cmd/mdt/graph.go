@@ -20,6 +20,7 @@ import (
 	"github.com/marte-community/marte-dev-tools/internal/logger"
 	"github.com/marte-community/marte-dev-tools/internal/lsp"
 	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
 	"github.com/marte-community/marte-dev-tools/internal/validator"
 )
 
@@ -132,7 +133,7 @@ func runGraph(args []string) {
 			tree.AddFile(file, config)
 		}
 
-		v := validator.NewValidator(tree, projectRoot, overrides)
+		v := newValidator(tree, projectRoot, overrides)
 		v.ValidateProject(context.Background())
 
 		nodeDiags := make(map[*index.ProjectNode][]graph.NodeDiag)
@@ -457,7 +458,7 @@ func runGraphLSP(port int) {
 			return fullResult{}
 		}
 
-		v := validator.NewValidator(tree, view.Root(), nil)
+		v := validator.NewValidator(tree, view.Root(), nil, schema.WithVersion(view.MARTeVersion()))
 		v.ValidateProject(context.Background())
 
 		nodeDiags := make(map[*index.ProjectNode][]graph.NodeDiag)
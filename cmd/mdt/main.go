@@ -7,6 +7,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/marte-community/marte-dev-tools/internal/builder"
@@ -15,6 +16,7 @@ import (
 	"github.com/marte-community/marte-dev-tools/internal/logger"
 	"github.com/marte-community/marte-dev-tools/internal/lsp"
 	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
 	"github.com/marte-community/marte-dev-tools/internal/validator"
 )
 
@@ -36,8 +38,16 @@ Commands:
   fmt     Format .marte files in-place
   init    Create a new MARTe2 project scaffold
   graph   Launch the interactive signal-flow graph viewer
+  expand  Show the expansion of a #use, #foreach, #if or #switch block
+  size    Report the memory used by DataSources, GAMs, threads and states
+  schema  Work with the project schema: schema dump, schema check,
+          schema explain <class>, schema extract <paths...>
   version Show mdt version and build information
 
+Global flags:
+  --marte-version=VER  Validate against the schema of a MARTe2-components
+                       release (overrides #Target in .marte_schema.cue)
+
 Run 'mdt <command> --help' for per-command usage.
 `
 
@@ -76,6 +86,12 @@ Flags:
   -P <folder>      Scan folder recursively for .marte files
   -p <project>     Only process files belonging to this project (package prefix)
   -vVAR=VAL        Override a #var variable value
+  --marte-version=VER
+                   Validate against the schema of a MARTe2-components release
+  --timing[=F]     Estimate the load of each thread and warn above fraction F
+                   of its period (default 0.8)
+  --variants       Evaluate every combination of the enumerated #var values
+                   and report objects, signals and #if branches none uses
   -h, --help       Show this help message
 `
 
@@ -119,6 +135,19 @@ Flags:
   -h, --help           Show this help message
 `
 
+const helpExpand = `Usage: mdt expand [flags] <file[:line]> [files...]
+
+Print the definitions produced by the innermost #use, #foreach, #if or
+#switch block at line of file, fully evaluated. Without a line, every
+outermost block of the file is expanded. Blocks inside a #foreach are shown
+once per iteration. Additional files provide templates and variables.
+
+Flags:
+  -P <folder>      Scan folder recursively for additional .marte files
+  -vVAR=VAL        Override a #var variable value
+  -h, --help       Show this help message
+`
+
 func printHelp(cmd string) {
 	switch cmd {
 	case "lsp":
@@ -133,6 +162,12 @@ func printHelp(cmd string) {
 		fmt.Print(helpInit)
 	case "graph":
 		fmt.Print(helpGraph)
+	case "expand":
+		fmt.Print(helpExpand)
+	case "size":
+		fmt.Print(helpSize)
+	case "schema":
+		fmt.Print(helpSchema)
 	case "version":
 		fmt.Print(helpVersion)
 	default:
@@ -149,7 +184,31 @@ func hasHelpFlag(args []string) bool {
 	return false
 }
 
+// marteVersion is the release selected with --marte-version, or empty to
+// use the #Target of the project schema.
+var marteVersion string
+
+// takeMARTeVersion removes the --marte-version flag from args and returns
+// the release it names.
+func takeMARTeVersion(args []string) ([]string, string) {
+	var rest []string
+	version := ""
+	for _, a := range args {
+		if !strings.HasPrefix(a, "--marte-version=") {
+			rest = append(rest, a)
+			continue
+		}
+		version = strings.TrimPrefix(a, "--marte-version=")
+		if !schema.HasVersion(version) {
+			logger.Printf("Unknown MARTe version %q (bundled: %s)\n", version, strings.Join(schema.Versions(), ", "))
+			os.Exit(1)
+		}
+	}
+	return rest, version
+}
+
 func main() {
+	os.Args, marteVersion = takeMARTeVersion(os.Args)
 	if len(os.Args) < 2 || os.Args[1] == "--help" || os.Args[1] == "-h" {
 		if len(os.Args) < 2 {
 			fmt.Print(helpGeneral)
@@ -197,6 +256,24 @@ func main() {
 			os.Exit(0)
 		}
 		runGraph(os.Args[2:])
+	case "expand":
+		if hasHelpFlag(os.Args[2:]) {
+			printHelp("expand")
+			return
+		}
+		runExpand(os.Args[2:])
+	case "size":
+		if hasHelpFlag(os.Args[2:]) {
+			printHelp("size")
+			return
+		}
+		runSize(os.Args[2:])
+	case "schema":
+		if hasHelpFlag(os.Args[2:]) {
+			printHelp("schema")
+			return
+		}
+		runSchema(os.Args[2:])
 	case "version":
 		runVersion()
 	default:
@@ -206,6 +283,14 @@ func main() {
 	}
 }
 
+// newValidator creates the validator of a command and attaches its schema
+// to the tree.
+func newValidator(tree *index.ProjectTree, root string, overrides map[string]string) *validator.Validator {
+	v := validator.NewValidator(tree, root, overrides, schema.WithVersion(marteVersion))
+	validator.AttachSchema(tree, v.Schema)
+	return v
+}
+
 func runLSP() {
 	args := os.Args[2:]
 	graphEnabled := false
@@ -224,7 +309,7 @@ func runLSP() {
 	if graphEnabled {
 		go runGraphLSP(graphPort)
 	}
-	lsp.RunServer()
+	lsp.RunServer(marteVersion)
 }
 
 func runBuild(args []string) {
@@ -319,7 +404,7 @@ func runBuild(args []string) {
 		os.Exit(0)
 	}
 
-	v := validator.NewValidator(tree, ".", overrides)
+	v := newValidator(tree, ".", overrides)
 	v.ValidateProject(context.Background())
 
 	hasErrors := false
@@ -330,7 +415,7 @@ func runBuild(args []string) {
 		} else {
 			hasErrors = true
 		}
-		logger.Printf("%s:%d:%d: %s: %s\n", diag.File, diag.Position.Line, diag.Position.Column, level, diag.Message)
+		printDiagnostic(diag, level)
 	}
 
 	if hasErrors {
@@ -340,6 +425,7 @@ func runBuild(args []string) {
 
 	// 2. Perform Build
 	b := builder.NewBuilder(filteredFiles, overrides)
+	b.MARTeVersion = marteVersion
 
 	var out *os.File = os.Stdout
 	if outputFile != "" {
@@ -364,6 +450,8 @@ func runCheck(args []string) {
 	overrides := make(map[string]string)
 	root_path := ""
 	projectFilter := ""
+	timingBudget := 0.0
+	variants := false
 
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
@@ -373,6 +461,17 @@ func runCheck(args []string) {
 		} else if arg == "-p" && i+1 < len(args) {
 			projectFilter = args[i+1]
 			i++
+		} else if arg == "--variants" {
+			variants = true
+		} else if arg == "--timing" {
+			timingBudget = validator.DefaultTimingBudget
+		} else if strings.HasPrefix(arg, "--timing=") {
+			budget, err := strconv.ParseFloat(strings.TrimPrefix(arg, "--timing="), 64)
+			if err != nil || budget <= 0 {
+				logger.Printf("Invalid timing budget %q: expected a fraction of the period such as 0.8\n", arg)
+				os.Exit(1)
+			}
+			timingBudget = budget
 		} else if strings.HasPrefix(arg, "-v") {
 			pair := arg[2:]
 			parts := strings.SplitN(pair, "=", 2)
@@ -401,7 +500,7 @@ func runCheck(args []string) {
 	}
 
 	if len(files) < 1 {
-		logger.Println("Usage: mdt check [-P folder_path] [-p project_name] [-vVAR=VAL] <input_files...>")
+		logger.Println("Usage: mdt check [-P folder_path] [-p project_name] [-vVAR=VAL] [--timing[=F]] [--variants] <input_files...>")
 		os.Exit(1)
 	}
 
@@ -449,15 +548,34 @@ func runCheck(args []string) {
 		return
 	}
 
-	v := validator.NewValidator(tree, ".", overrides)
+	v := newValidator(tree, ".", overrides)
 	v.ValidateProject(context.Background())
+	var timings []validator.ThreadTiming
+	if timingBudget > 0 {
+		timings = v.CheckTiming(context.Background(), timingBudget)
+	}
+	variantCount := 0
+	if variants {
+		n, err := v.CheckVariants(context.Background())
+		if err != nil {
+			logger.Printf("Cannot check variants: %v\n", err)
+			os.Exit(1)
+		}
+		variantCount = n
+	}
 
 	for _, diag := range v.Diagnostics {
 		level := "ERROR"
 		if diag.Level == validator.LevelWarning {
 			level = "WARNING"
 		}
-		logger.Printf("%s:%d:%d: %s: %s\n", diag.File, diag.Position.Line, diag.Position.Column, level, diag.Message)
+		printDiagnostic(diag, level)
+	}
+	if timingBudget > 0 {
+		printTimings(timings)
+	}
+	if variants {
+		logger.Printf("\nChecked %d variants.\n", variantCount)
 	}
 
 	totalIssues := len(v.Diagnostics) + syntaxErrors
@@ -468,6 +586,123 @@ func runCheck(args []string) {
 	}
 }
 
+// printDiagnostic writes a diagnostic with its position; diagnostics about
+// the command line, such as unknown -v overrides, have none.
+func printDiagnostic(diag validator.Diagnostic, level string) {
+	if diag.File == "" {
+		logger.Printf("%s: %s\n", level, diag.Message)
+		return
+	}
+	logger.Printf("%s:%d:%d: %s: %s\n", diag.File, diag.Position.Line, diag.Position.Column, level, diag.Message)
+}
+
+// printTimings writes the estimated cycle time of every thread and the
+// cost of each of its GAMs.
+func printTimings(timings []validator.ThreadTiming) {
+	logger.Println("\nTiming estimate:")
+	if len(timings) == 0 {
+		logger.Println("  No real-time threads found.")
+		return
+	}
+	for _, t := range timings {
+		period := "unknown period"
+		if t.Frequency > 0 {
+			period = fmt.Sprintf("%g Hz, %.0f%% of %.0f ns", t.Frequency, t.Load()*100, t.PeriodNs())
+		}
+		cpus := ""
+		if t.CPUs != 0 {
+			cpus = fmt.Sprintf(", CPUs 0x%x", t.CPUs)
+		}
+		logger.Printf("  %s.%s.%s: %.0f ns (%s%s)\n", t.Application, t.State, t.Thread, t.CostNs, period, cpus)
+		for _, g := range t.GAMs {
+			if g.Modelled {
+				logger.Printf("    %s (%s): %.0f ns\n", g.Name, g.Class, g.CostNs)
+			} else {
+				logger.Printf("    %s (%s): no cost model\n", g.Name, g.Class)
+			}
+		}
+	}
+}
+
+func runExpand(args []string) {
+	files := []string{}
+	overrides := make(map[string]string)
+	root_path := ""
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-P" && i+1 < len(args) {
+			root_path = args[i+1]
+			i++
+		} else if strings.HasPrefix(arg, "-v") {
+			pair := arg[2:]
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) == 2 {
+				overrides[parts[0]] = parts[1]
+			}
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	if len(files) < 1 {
+		logger.Println("Usage: mdt expand [-P folder_path] [-vVAR=VAL] <file[:line]> [files...]")
+		os.Exit(1)
+	}
+
+	target, line := files[0], 0
+	if i := strings.LastIndex(target, ":"); i > 0 {
+		if _, err := fmt.Sscanf(target[i+1:], "%d", &line); err == nil {
+			target = target[:i]
+		}
+	}
+	files[0] = target
+
+	if root_path != "" {
+		err := filepath.WalkDir(root_path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".marte") && filepath.Clean(path) != filepath.Clean(target) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Printf("Error while exploring project dir: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	tree := index.NewProjectTree()
+	var targetConfig *parser.Configuration
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Printf("Error reading %s: %v\n", file, err)
+			os.Exit(1)
+		}
+		p := parser.NewParser(string(content))
+		config, err := p.Parse()
+		if err != nil {
+			logger.Printf("%s: Grammar error: %v\n", file, err)
+			os.Exit(1)
+		}
+		if targetConfig == nil {
+			targetConfig = config
+		}
+		tree.AddFile(file, config)
+	}
+
+	v := newValidator(tree, ".", overrides)
+	exps, err := tree.Expand(targetConfig, target, line, v.Variables)
+	if err != nil {
+		logger.Printf("%s: %v\n", target, err)
+		os.Exit(1)
+	}
+	fmt.Print(index.FormatExpansions(exps))
+}
+
 func runFmt(args []string) {
 	if len(args) < 1 {
 		logger.Println("Usage: mdt fmt <input_files...>")
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"cuelang.org/go/cue"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+
+	"github.com/marte-community/marte-dev-tools/internal/logger"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+)
+
+const helpSchema = `Usage: mdt schema <subcommand> [flags] [arguments]
+
+Work with the CUE schema of a project: the built-in schema, the release
+selected by #Target or --marte-version, /usr/share/mdt/marte_schema.cue,
+~/.local/share/mdt/marte_schema.cue and .marte_schema.cue, merged in this
+order.
+
+Subcommands:
+  dump                 Print the merged schema
+  check                Report the schema files that fail to compile and the
+                       conflicts between them
+  explain <class>      Show the fields of a class, whether they are optional,
+                       their allowed values, its #meta and the file setting
+                       each constraint
+  extract <paths...>   Generate a .marte_schema.cue skeleton from the MARTe2
+                       C++ component sources under paths
+
+Flags:
+  -P <folder>      Project folder holding .marte_schema.cue (default: .)
+  -o <output>      Write the extracted schema to file (default: stdout)
+  -h, --help       Show this help message
+
+extract reads the classes registered with CLASS_REGISTER, the parameters
+their Initialise method reads, the signal types checked with GetSignalType
+and the brokers of DataSources. Entries it could only guess are marked with
+a "guessed" comment.
+`
+
+func runSchema(args []string) {
+	if len(args) == 0 {
+		fmt.Print(helpSchema)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "dump":
+		runSchemaDump(args[1:])
+	case "check":
+		runSchemaCheck(args[1:])
+	case "explain":
+		runSchemaExplain(args[1:])
+	case "extract":
+		runSchemaExtract(args[1:])
+	default:
+		logger.Printf("Unknown schema subcommand: %s\n", args[0])
+		os.Exit(1)
+	}
+}
+
+// loadProjectSchema loads the merged schema of the project folder given by
+// -P and returns the remaining arguments.
+func loadProjectSchema(args []string) (*schema.Schema, []string) {
+	root := "."
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-P" && i+1 < len(args) {
+			root = args[i+1]
+			i++
+		} else {
+			rest = append(rest, args[i])
+		}
+	}
+	return schema.LoadFullSchema(root, schema.WithVersion(marteVersion)), rest
+}
+
+func runSchemaDump(args []string) {
+	s, _ := loadProjectSchema(args)
+	for _, layer := range s.Layers {
+		if layer.Err != nil {
+			logger.Printf("Skipped %s: it does not compile (see 'mdt schema check')\n", layer.Name)
+		}
+	}
+
+	node := s.Value.Syntax(cue.Definitions(true), cue.Optional(true), cue.Hidden(true), cue.Docs(true))
+	out, err := format.Node(node)
+	if err != nil {
+		logger.Printf("Error formatting schema: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("// Merged from:")
+	for _, layer := range s.Layers {
+		if layer.Err == nil {
+			fmt.Printf("//   %s\n", layer.Name)
+		}
+	}
+	fmt.Println()
+	fmt.Print(string(out))
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		fmt.Println()
+	}
+}
+
+func runSchemaCheck(args []string) {
+	s, _ := loadProjectSchema(args)
+	issues := 0
+	for _, err := range s.Errors() {
+		for _, e := range cueerrors.Errors(err) {
+			issues++
+			format, fmtArgs := e.Msg()
+			msg := fmt.Sprintf(format, fmtArgs...)
+			if path := strings.Join(e.Path(), "."); path != "" {
+				msg = path + ": " + msg
+			}
+			positions := cueerrors.Positions(e)
+			if len(positions) == 0 {
+				logger.Printf("ERROR: %s\n", msg)
+				continue
+			}
+			for _, pos := range positions[1:] {
+				msg += fmt.Sprintf(" (see %s:%d:%d)", schemaFile(pos), pos.Line(), pos.Column())
+			}
+			logger.Printf("%s:%d:%d: ERROR: %s\n", schemaFile(positions[0]), positions[0].Line(), positions[0].Column(), msg)
+		}
+	}
+	if issues > 0 {
+		logger.Printf("\nFound %d issues.\n", issues)
+		os.Exit(1)
+	}
+	logger.Printf("No issues found in %d schema files.\n", len(s.Layers))
+}
+
+// schemaFile returns the file of a schema position; the built-in schema is
+// compiled without a file name.
+func schemaFile(pos token.Pos) string {
+	if pos.Filename() == "" {
+		return schema.BuiltinLayer
+	}
+	return pos.Filename()
+}
+
+func runSchemaExplain(args []string) {
+	s, rest := loadProjectSchema(args)
+	if len(rest) != 1 {
+		logger.Println("Usage: mdt schema explain [-P folder_path] <class>")
+		os.Exit(1)
+	}
+	info := s.Explain(rest[0])
+	if info == nil {
+		logger.Printf("Unknown class '%s'\n", rest[0])
+		os.Exit(1)
+	}
+
+	fmt.Printf("Class %s\n", info.Name)
+	for _, c := range info.Sources {
+		fmt.Printf("  defined in %s:%d\n", c.Layer, c.Line)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	printFieldInfos(w, "Fields", info.Fields)
+	printFieldInfos(w, "#meta", info.Meta)
+	w.Flush()
+	if info.Open {
+		fmt.Println("\nOther fields are allowed.")
+	} else {
+		fmt.Println("\nNo other fields are allowed.")
+	}
+}
+
+func printFieldInfos(w *tabwriter.Writer, title string, fields []schema.FieldInfo) {
+	if len(fields) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, f := range fields {
+		presence := "required"
+		if f.Optional {
+			presence = "optional"
+		}
+		if len(f.Constraints) == 0 {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t\n", f.Name, presence, oneLine(f.Type))
+		}
+		for i, c := range f.Constraints {
+			if i == 0 {
+				fmt.Fprintf(w, "  %s\t%s\t%s\t%s:%d\n", f.Name, presence, oneLine(c.Expr), c.Layer, c.Line)
+			} else {
+				fmt.Fprintf(w, "  \t\t%s\t%s:%d\n", oneLine(c.Expr), c.Layer, c.Line)
+			}
+		}
+		if len(f.Enum) > 0 {
+			fmt.Fprintf(w, "  \t\tone of %s\t\n", strings.Join(f.Enum, ", "))
+		}
+		if f.Default != "" {
+			fmt.Fprintf(w, "  \t\tdefault %s\t\n", f.Default)
+		}
+	}
+}
+
+// oneLine collapses the whitespace of a CUE expression.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func runSchemaExtract(args []string) {
+	var roots []string
+	output := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-o" && i+1 < len(args) {
+			output = args[i+1]
+			i++
+		} else {
+			roots = append(roots, args[i])
+		}
+	}
+	if len(roots) == 0 {
+		logger.Println("Usage: mdt schema extract [-o output] <paths...>")
+		os.Exit(1)
+	}
+
+	extraction, err := schema.ExtractSchema(roots)
+	if err != nil {
+		logger.Printf("Error scanning sources: %v\n", err)
+		os.Exit(1)
+	}
+	if len(extraction.Classes) == 0 {
+		logger.Println("No classes registered with CLASS_REGISTER found")
+		os.Exit(1)
+	}
+
+	text := extraction.CUE()
+	if output == "" {
+		fmt.Print(text)
+	} else if err := os.WriteFile(output, []byte(text), 0644); err != nil {
+		logger.Printf("Error writing %s: %v\n", output, err)
+		os.Exit(1)
+	}
+	logger.Printf("Extracted %d classes, %d entries guessed\n", len(extraction.Classes), extraction.Guessed())
+}
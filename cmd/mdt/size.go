@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/logger"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const helpSize = `Usage: mdt size [flags] [files...]
+
+Report the memory taken by the signals of each DataSource, GAM, thread and
+state. DataSources count NumberOfBuffers; GAMs count their input and output
+copies.
+
+Flags:
+  -P <folder>           Scan folder recursively for .marte files
+  -p <project>          Only process files belonging to this project
+  -vVAR=VAL             Override a #var variable value
+  --format=FORMAT       Output format: table (default), json or csv
+  --max-total=SIZE      Fail if all DataSources and GAMs exceed SIZE
+  --max-datasource=SIZE Fail if a DataSource exceeds SIZE
+  --max-gam=SIZE        Fail if a GAM exceeds SIZE
+  --max-thread=SIZE     Fail if a thread exceeds SIZE
+  --max-state=SIZE      Fail if a state exceeds SIZE
+  -h, --help            Show this help message
+
+SIZE is a number of bytes with an optional K, M or G suffix (powers of 1024).
+`
+
+// sizeLimits are the largest sizes allowed per kind of object; 0 means no
+// limit.
+type sizeLimits struct {
+	total, dataSource, gam, thread, state int64
+}
+
+func runSize(args []string) {
+	files := []string{}
+	overrides := make(map[string]string)
+	rootPath := ""
+	projectFilter := ""
+	format := "table"
+	var limits sizeLimits
+
+	limitFlags := map[string]*int64{
+		"--max-total=":      &limits.total,
+		"--max-datasource=": &limits.dataSource,
+		"--max-gam=":        &limits.gam,
+		"--max-thread=":     &limits.thread,
+		"--max-state=":      &limits.state,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-P" && i+1 < len(args) {
+			rootPath = args[i+1]
+			i++
+		} else if arg == "-p" && i+1 < len(args) {
+			projectFilter = args[i+1]
+			i++
+		} else if strings.HasPrefix(arg, "--format=") {
+			format = strings.TrimPrefix(arg, "--format=")
+		} else if strings.HasPrefix(arg, "--max-") {
+			prefix := arg[:strings.Index(arg, "=")+1]
+			limit, ok := limitFlags[prefix]
+			if !ok {
+				logger.Printf("Unknown limit %s\n", arg)
+				os.Exit(1)
+			}
+			size, err := parseSize(strings.TrimPrefix(arg, prefix))
+			if err != nil {
+				logger.Printf("Invalid size in %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			*limit = size
+		} else if strings.HasPrefix(arg, "-v") {
+			pair := arg[2:]
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) == 2 {
+				overrides[parts[0]] = parts[1]
+			}
+		} else {
+			files = append(files, arg)
+		}
+	}
+	if format != "table" && format != "json" && format != "csv" {
+		logger.Printf("Unknown format %q: expected table, json or csv\n", format)
+		os.Exit(1)
+	}
+
+	if rootPath != "" {
+		err := filepath.WalkDir(rootPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".marte") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Printf("Error while exploring project dir: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(files) < 1 {
+		logger.Println("Usage: mdt size [-P folder_path] [-p project_name] [-vVAR=VAL] [--format=table|json|csv] [--max-KIND=SIZE] <input_files...>")
+		os.Exit(1)
+	}
+
+	tree := index.NewProjectTree()
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			logger.Printf("Error reading %s: %v\n", file, err)
+			continue
+		}
+		config, _ := parser.NewParser(string(content)).Parse()
+		if config == nil {
+			continue
+		}
+		if projectFilter != "" {
+			fileProj := ""
+			if config.Package != nil {
+				fileProj = strings.TrimSpace(strings.Split(config.Package.URI, ".")[0])
+			}
+			if fileProj != projectFilter {
+				continue
+			}
+		}
+		tree.AddFile(file, config)
+	}
+
+	v := newValidator(tree, ".", overrides)
+	v.ValidateProject(context.Background())
+	fp := v.Footprint()
+
+	var err error
+	switch format {
+	case "json":
+		err = writeSizeJSON(fp)
+	case "csv":
+		err = writeSizeCSV(fp)
+	default:
+		err = writeSizeTable(fp)
+	}
+	if err != nil {
+		logger.Printf("Error writing report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if exceeded := checkSizeLimits(fp, limits); len(exceeded) > 0 {
+		for _, msg := range exceeded {
+			logger.Println(msg)
+		}
+		os.Exit(1)
+	}
+}
+
+// parseSize reads a byte count such as 512, 64K or 2M.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, strings.TrimSuffix(s, "G")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("expected a positive number of bytes, got %q", s)
+	}
+	return n * mult, nil
+}
+
+func checkSizeLimits(fp *validator.Footprint, limits sizeLimits) []string {
+	var msgs []string
+	check := func(list []validator.MemoryUsage, limit int64) {
+		if limit <= 0 {
+			return
+		}
+		for _, u := range list {
+			if u.Bytes > limit {
+				msgs = append(msgs, fmt.Sprintf("%s '%s' uses %d bytes, more than the limit of %d", u.Kind, u.Name, u.Bytes, limit))
+			}
+		}
+	}
+	check(fp.DataSources, limits.dataSource)
+	check(fp.GAMs, limits.gam)
+	check(fp.Threads, limits.thread)
+	check(fp.States, limits.state)
+	if limits.total > 0 && fp.Total > limits.total {
+		msgs = append(msgs, fmt.Sprintf("Total memory of %d bytes is more than the limit of %d", fp.Total, limits.total))
+	}
+	return msgs
+}
+
+func sizeRows(fp *validator.Footprint) []validator.MemoryUsage {
+	var rows []validator.MemoryUsage
+	for _, list := range [][]validator.MemoryUsage{fp.DataSources, fp.GAMs, fp.Threads, fp.States} {
+		rows = append(rows, list...)
+	}
+	return rows
+}
+
+func writeSizeTable(fp *validator.Footprint) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tNAME\tCLASS\tSIGNALS\tBUFFERS\tBYTES")
+	for _, u := range sizeRows(fp) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\n", u.Kind, u.Name, u.Class, u.Signals, u.Buffers, u.Bytes)
+	}
+	fmt.Fprintf(w, "Total\t\t\t\t\t%d\n", fp.Total)
+	return w.Flush()
+}
+
+func writeSizeCSV(fp *validator.Footprint) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"kind", "name", "class", "signals", "buffers", "bytes"})
+	for _, u := range sizeRows(fp) {
+		w.Write([]string{u.Kind, u.Name, u.Class, strconv.Itoa(u.Signals), strconv.FormatInt(u.Buffers, 10), strconv.FormatInt(u.Bytes, 10)})
+	}
+	w.Write([]string{"Total", "", "", "", "", strconv.FormatInt(fp.Total, 10)})
+	w.Flush()
+	return w.Error()
+}
+
+func writeSizeJSON(fp *validator.Footprint) error {
+	type row struct {
+		Name    string `json:"name"`
+		Class   string `json:"class"`
+		Signals int    `json:"signals"`
+		Buffers int64  `json:"buffers"`
+		Bytes   int64  `json:"bytes"`
+	}
+	rows := func(list []validator.MemoryUsage) []row {
+		out := []row{}
+		for _, u := range list {
+			out = append(out, row{u.Name, u.Class, u.Signals, u.Buffers, u.Bytes})
+		}
+		return out
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		DataSources []row `json:"dataSources"`
+		GAMs        []row `json:"gams"`
+		Threads     []row `json:"threads"`
+		States      []row `json:"states"`
+		Total       int64 `json:"total"`
+	}{rows(fp.DataSources), rows(fp.GAMs), rows(fp.Threads), rows(fp.States), fp.Total})
+}
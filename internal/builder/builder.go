@@ -14,8 +14,11 @@ import (
 )
 
 type Builder struct {
-	Files           []string
-	Overrides       map[string]string
+	Files     []string
+	Overrides map[string]string
+	// MARTeVersion selects the release schema validated against, overriding
+	// the #Target of the project schema.
+	MARTeVersion    string
 	variables       map[string]parser.Value
 	tree            *index.ProjectTree
 	activeNodes     map[*index.ProjectNode]bool
@@ -133,6 +136,16 @@ func (b *Builder) collectActiveNodes(node *index.ProjectNode, evalCtx *index.Eva
 					}
 					processEval(b.tree.EvaluateDefinitions(d.Else, ed.Ctx, ed.File), node)
 				}
+			case *parser.SwitchBlock:
+				id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+				if branch := b.tree.SelectSwitchBranch(d, ed.Ctx); branch != nil {
+					for _, f := range node.Fragments {
+						if f.IsConditional && f.BranchID == id+":"+branch.ID {
+							b.activeFragments[f] = true
+						}
+					}
+					processEval(b.tree.EvaluateDefinitions(branch.Body, ed.Ctx, ed.File), node)
+				}
 			case *parser.ForeachBlock:
 				iterable := b.tree.EvaluateValue(d.Iterable, ed.Ctx)
 				id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
@@ -266,7 +279,7 @@ func (b *Builder) Build(f *os.File) error {
 		ActiveNodes:     make(map[*index.ProjectNode]bool),
 		Variables:       b.variables,
 		Overrides:       make(map[string]parser.Value),
-		Schema:          schema.LoadFullSchema("."),
+		Schema:          schema.LoadFullSchema(".", schema.WithVersion(b.MARTeVersion)),
 	}
 	v.ValidateProject(context.Background())
 	if len(v.Diagnostics) > 0 {
@@ -371,7 +384,7 @@ func (b *Builder) collectVariables(tree *index.ProjectTree) {
 							// fmt.Printf("[DEBUG-BUILDER] Checking %s, type=%s, val=%s\n", vdef.Name, vdef.TypeExpr, valStr)
 							if shouldAutoQuoteWithDef(valStr, vdef) {
 								// fmt.Printf("[DEBUG-BUILDER] Auto-quoting %s\n", vdef.Name)
-								valStr = "\"" + valStr + "\""
+								valStr = parser.QuoteString(valStr)
 							}
 							p := parser.NewParser("Temp = " + valStr)
 							cfg, _ := p.Parse()
@@ -586,7 +599,7 @@ func (b *Builder) formatValueWithCtx(val parser.Value, ctx *index.EvaluationCont
 	switch v := val.(type) {
 	case *parser.StringValue:
 		if v.Quoted {
-			return fmt.Sprintf("\"%s\"", v.Value)
+			return parser.QuoteString(v.Value)
 		}
 		return v.Value
 	case *parser.IntValue:
@@ -602,7 +615,13 @@ func (b *Builder) formatValueWithCtx(val parser.Value, ctx *index.EvaluationCont
 	case *parser.ArrayValue:
 		elements := []string{}
 		for _, e := range v.Elements {
-			elements = append(elements, b.formatValueWithCtx(e, ctx))
+			// An inactive conditional branch contributes no elements.
+			if s := b.formatValueWithCtx(e, ctx); s != "" {
+				elements = append(elements, s)
+			}
+		}
+		if len(elements) == 0 {
+			return "{}"
 		}
 		return fmt.Sprintf("{ %s }", strings.Join(elements, " "))
 	case *parser.ConditionalArrayElements:
@@ -146,6 +146,34 @@ func (f *Formatter) formatDefinition(def parser.Definition, indent int) int {
 		}
 		fmt.Fprintf(f.writer, "%s#end", indentStr)
 		return d.EndPosition.Line
+	case *parser.SwitchBlock:
+		fmt.Fprintf(f.writer, "%s#switch ", indentStr)
+		f.formatValue(d.Subject, indent)
+		if f.hasTrailingComment(d.Position.Line) {
+			fmt.Fprintf(f.writer, " %s", f.popComment())
+		}
+		fmt.Fprintln(f.writer)
+		for _, b := range d.Branches() {
+			f.flushCommentsBefore(b.Position, indent, true)
+			if b.ID == "default" {
+				fmt.Fprintf(f.writer, "%s#default", indentStr)
+			} else {
+				fmt.Fprintf(f.writer, "%s#case ", indentStr)
+				for i, v := range b.Values {
+					if i > 0 {
+						fmt.Fprint(f.writer, ", ")
+					}
+					f.formatValue(v, indent)
+				}
+			}
+			if f.hasTrailingComment(b.Position.Line) {
+				fmt.Fprintf(f.writer, " %s", f.popComment())
+			}
+			fmt.Fprintln(f.writer)
+			f.formatBlock(b.Body, indent+1)
+		}
+		fmt.Fprintf(f.writer, "%s#end", indentStr)
+		return d.EndPosition.Line
 	case *parser.ForeachBlock:
 		fmt.Fprintf(f.writer, "%s#foreach ", indentStr)
 		if d.KeyVar != "" {
@@ -173,6 +201,18 @@ func (f *Formatter) formatDefinition(def parser.Definition, indent int) int {
 			}
 		}
 		fmt.Fprint(f.writer, ")")
+		if d.Base != "" {
+			fmt.Fprintf(f.writer, " extends %s", d.Base)
+		}
+		if f.hasTrailingComment(d.Position.Line) {
+			fmt.Fprintf(f.writer, " %s", f.popComment())
+		}
+		fmt.Fprintln(f.writer)
+		f.formatBlock(d.Body, indent+1)
+		fmt.Fprintf(f.writer, "%s#end", indentStr)
+		return d.EndPosition.Line
+	case *parser.SlotBlock:
+		fmt.Fprintf(f.writer, "%s#slot %s", indentStr, d.Name)
 		if f.hasTrailingComment(d.Position.Line) {
 			fmt.Fprintf(f.writer, " %s", f.popComment())
 		}
@@ -190,7 +230,25 @@ func (f *Formatter) formatDefinition(def parser.Definition, indent int) int {
 			f.formatValue(arg.Value, indent)
 		}
 		fmt.Fprint(f.writer, ")")
-		return d.Position.Line
+		if len(d.Blocks) == 0 {
+			return d.Position.Line
+		}
+		if f.hasTrailingComment(d.Position.Line) {
+			fmt.Fprintf(f.writer, " %s", f.popComment())
+		}
+		fmt.Fprintln(f.writer)
+		for _, b := range d.Blocks {
+			f.flushCommentsBefore(b.Position, indent+1, true)
+			fmt.Fprintf(f.writer, "%s  #fill %s", indentStr, b.Name)
+			if f.hasTrailingComment(b.Position.Line) {
+				fmt.Fprintf(f.writer, " %s", f.popComment())
+			}
+			fmt.Fprintln(f.writer)
+			f.formatBlock(b.Body, indent+2)
+			fmt.Fprintf(f.writer, "%s  #end\n", indentStr)
+		}
+		fmt.Fprintf(f.writer, "%s#end", indentStr)
+		return d.EndPosition.Line
 	}
 	return 0
 }
@@ -226,12 +284,18 @@ func (f *Formatter) formatValue(val parser.Value, indent int) int {
 	switch v := val.(type) {
 	case *parser.StringValue:
 		if v.Quoted {
-			fmt.Fprintf(f.writer, "\"%s\"", v.Value)
+			// Keep the literal as written so raw strings and line
+			// continuations survive formatting.
+			if v.Raw != "" {
+				fmt.Fprint(f.writer, v.Raw)
+			} else {
+				fmt.Fprint(f.writer, parser.QuoteString(v.Value))
+			}
 		} else {
 			// Should strictly parse unquoted as ReferenceValue or identifiers, but fallback here
 			fmt.Fprint(f.writer, v.Value)
 		}
-		return v.Position.Line
+		return v.End().Line
 	case *parser.IntValue:
 		fmt.Fprint(f.writer, v.Raw)
 		return v.Position.Line
@@ -345,6 +409,10 @@ func (f *Formatter) formatConditionalArrayElement(v *parser.ConditionalArrayElem
 }
 
 func (f *Formatter) formatArrayInline(v *parser.ArrayValue, indent int) {
+	if len(v.Elements) == 0 {
+		fmt.Fprint(f.writer, "{}")
+		return
+	}
 	fmt.Fprint(f.writer, "{ ")
 	for i, e := range v.Elements {
 		if i > 0 {
@@ -1272,30 +1272,14 @@ func extractStates(tree *index.ProjectTree, gamIDMap map[*index.ProjectNode]stri
 		if n.Metadata["Class"] != "RealTimeApplication" {
 			return
 		}
-		statesNode := n.Children["States"]
-		if statesNode == nil {
-			return
-		}
-		for _, state := range statesNode.Children {
-			if state.Metadata["Class"] != "RealTimeState" {
-				continue
-			}
+		for _, state := range tree.ApplicationStates(n) {
 			si := &StateInfo{Threads: make(map[string][]string)}
-			findThreads := func(container *index.ProjectNode) {
-				for _, child := range container.Children {
-					if child.Metadata["Class"] != "RealTimeThread" {
-						continue
-					}
-					ids := getThreadGAMIDs(tree, child, gamIDMap)
-					if len(ids) > 0 {
-						si.Threads[child.Name] = ids
-					}
+			for _, thread := range tree.StateThreads(state) {
+				ids := getThreadGAMIDs(tree, thread, gamIDMap)
+				if len(ids) > 0 {
+					si.Threads[thread.Name] = ids
 				}
 			}
-			if tc, ok := state.Children["Threads"]; ok {
-				findThreads(tc)
-			}
-			findThreads(state)
 			if len(si.Threads) > 0 {
 				states[state.Name] = si
 			}
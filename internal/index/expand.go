@@ -0,0 +1,316 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// Expansion is the evaluated form of one #use, #foreach, #if or #switch
+// block: the definitions it produces with all directives applied and
+// variables substituted.
+type Expansion struct {
+	Directive   parser.Definition
+	File        string
+	Bindings    []string // loop variables of the enclosing #foreach blocks, e.g. "I = 1"
+	Inactive    bool     // the block is in a branch that is not selected
+	Definitions []parser.Definition
+}
+
+// Expand evaluates the innermost #use, #foreach, #if or #switch of config
+// that contains line, or every outermost one when line is 0. Blocks nested
+// in a #foreach are expanded once per iteration. vars holds the global
+// variable values.
+func (pt *ProjectTree) Expand(config *parser.Configuration, file string, line int, vars map[string]parser.Value) ([]Expansion, error) {
+	ctx := &EvaluationContext{Variables: make(map[string]parser.Value), Tree: pt}
+	for k, v := range vars {
+		ctx.Variables[k] = v
+	}
+
+	var targets [][]parser.Definition
+	if line > 0 {
+		path := expansionPath(config.Definitions, line)
+		if path == nil {
+			return nil, fmt.Errorf("no #use, #foreach, #if or #switch at line %d", line)
+		}
+		targets = append(targets, path)
+	} else {
+		targets = outermostDirectives(config.Definitions, nil)
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no #use, #foreach, #if or #switch in %s", file)
+		}
+	}
+
+	var result []Expansion
+	for _, path := range targets {
+		exps, err := pt.expandPath(path, ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, exps...)
+	}
+	return result, nil
+}
+
+// expansionScope is one way of reaching the target of an expansion path.
+type expansionScope struct {
+	ctx      *EvaluationContext
+	bindings []string
+	inactive bool
+}
+
+func (pt *ProjectTree) expandPath(path []parser.Definition, ctx *EvaluationContext, file string) ([]Expansion, error) {
+	target := path[len(path)-1]
+	scopes := []expansionScope{{ctx: ctx}}
+	for i, def := range path[:len(path)-1] {
+		next := path[i+1]
+		switch d := def.(type) {
+		case *parser.TemplateDefinition:
+			return nil, fmt.Errorf("%d:%d is inside #template %s; expand a #use of it instead", target.Pos().Line, target.Pos().Column, d.Name)
+		case *parser.ForeachBlock:
+			var expanded []expansionScope
+			for _, s := range scopes {
+				arr, ok := pt.EvaluateValue(d.Iterable, s.ctx).(*parser.ArrayValue)
+				if !ok {
+					continue
+				}
+				for j, val := range arr.Elements {
+					sub := pt.loopContext(d, s.ctx, j, val)
+					bindings := append([]string(nil), s.bindings...)
+					if d.KeyVar != "" {
+						bindings = append(bindings, fmt.Sprintf("%s = %d", d.KeyVar, j))
+					}
+					if d.ValueVar != "" {
+						bindings = append(bindings, fmt.Sprintf("%s = %s", d.ValueVar, pt.ValueToString(val)))
+					}
+					expanded = append(expanded, expansionScope{ctx: sub, bindings: bindings, inactive: s.inactive})
+				}
+			}
+			scopes = expanded
+		case *parser.IfBlock:
+			inThen := containsDefinition(d.Then, next)
+			for j := range scopes {
+				if pt.IsTrue(pt.EvaluateValue(d.Condition, scopes[j].ctx)) != inThen {
+					scopes[j].inactive = true
+				}
+			}
+		case *parser.SwitchBlock:
+			for j := range scopes {
+				b := pt.SelectSwitchBranch(d, scopes[j].ctx)
+				if b == nil || !containsDefinition(b.Body, next) {
+					scopes[j].inactive = true
+				}
+			}
+		}
+	}
+
+	var result []Expansion
+	for _, s := range scopes {
+		result = append(result, Expansion{
+			Directive:   target,
+			File:        file,
+			Bindings:    s.bindings,
+			Inactive:    s.inactive,
+			Definitions: pt.expandDefinitions([]parser.Definition{target}, s.ctx, file),
+		})
+	}
+	return result, nil
+}
+
+// expandDefinitions evaluates defs and applies every directive, returning
+// plain fields, objects and signals.
+func (pt *ProjectTree) expandDefinitions(defs []parser.Definition, ctx *EvaluationContext, file string) []parser.Definition {
+	var out []parser.Definition
+	for _, ed := range pt.EvaluateDefinitions(defs, ctx, file) {
+		switch d := ed.Def.(type) {
+		case *parser.IfBlock:
+			branch := d.Else
+			if pt.IsTrue(pt.EvaluateValue(d.Condition, ed.Ctx)) {
+				branch = d.Then
+			}
+			out = append(out, pt.expandDefinitions(branch, ed.Ctx, ed.File)...)
+		case *parser.SwitchBlock:
+			if b := pt.SelectSwitchBranch(d, ed.Ctx); b != nil {
+				out = append(out, pt.expandDefinitions(b.Body, ed.Ctx, ed.File)...)
+			}
+		case *parser.ForeachBlock:
+			arr, ok := pt.EvaluateValue(d.Iterable, ed.Ctx).(*parser.ArrayValue)
+			if !ok {
+				continue
+			}
+			for i, val := range arr.Elements {
+				out = append(out, pt.expandDefinitions(d.Body, pt.loopContext(d, ed.Ctx, i, val), ed.File)...)
+			}
+		case *parser.ObjectNode:
+			out = append(out, &parser.ObjectNode{
+				Position: d.Position,
+				Name:     pt.EvaluateValue(d.Name, ed.Ctx),
+				Subnode: parser.Subnode{
+					Position:    d.Subnode.Position,
+					EndPosition: d.Subnode.EndPosition,
+					Definitions: pt.expandDefinitions(d.Subnode.Definitions, ed.Ctx, ed.File),
+				},
+			})
+		case *parser.Field:
+			out = append(out, &parser.Field{
+				Position: d.Position,
+				Name:     d.Name,
+				Value:    pt.EvaluateValue(d.Value, ed.Ctx),
+			})
+		default:
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func (pt *ProjectTree) loopContext(d *parser.ForeachBlock, ctx *EvaluationContext, i int, val parser.Value) *EvaluationContext {
+	sub := &EvaluationContext{Variables: make(map[string]parser.Value), Parent: ctx, Tree: pt}
+	if d.KeyVar != "" {
+		sub.Variables[d.KeyVar] = &parser.IntValue{Value: int64(i), Raw: fmt.Sprintf("%d", i)}
+	}
+	if d.ValueVar != "" {
+		sub.Variables[d.ValueVar] = val
+	}
+	return sub
+}
+
+// FormatExpansions renders expansions as MARTe configuration, each preceded
+// by a comment naming the block it comes from.
+func FormatExpansions(exps []Expansion) string {
+	var sb strings.Builder
+	for i, e := range exps {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		pos := e.Directive.Pos()
+		fmt.Fprintf(&sb, "// %s (%s:%d:%d)", directiveLabel(e.Directive), e.File, pos.Line, pos.Column)
+		if len(e.Bindings) > 0 {
+			fmt.Fprintf(&sb, " with %s", strings.Join(e.Bindings, ", "))
+		}
+		if e.Inactive {
+			sb.WriteString(" [inactive]")
+		}
+		sb.WriteString("\n")
+		var buf bytes.Buffer
+		formatter.Format(&parser.Configuration{Definitions: e.Definitions}, &buf)
+		sb.WriteString(buf.String())
+	}
+	return sb.String()
+}
+
+func directiveLabel(def parser.Definition) string {
+	switch d := def.(type) {
+	case *parser.TemplateInstantiation:
+		return fmt.Sprintf("#use %s %s", d.Template, d.Name)
+	case *parser.ForeachBlock:
+		if d.KeyVar != "" {
+			return fmt.Sprintf("#foreach %s, %s", d.KeyVar, d.ValueVar)
+		}
+		return "#foreach " + d.ValueVar
+	case *parser.IfBlock:
+		return "#if"
+	case *parser.SwitchBlock:
+		return "#switch"
+	}
+	return ""
+}
+
+// ExpandableAt returns the innermost #use, #foreach, #if or #switch of
+// config containing line, or nil.
+func ExpandableAt(config *parser.Configuration, line int) parser.Definition {
+	if path := expansionPath(config.Definitions, line); path != nil {
+		return path[len(path)-1]
+	}
+	return nil
+}
+
+func isExpandable(def parser.Definition) bool {
+	switch def.(type) {
+	case *parser.TemplateInstantiation, *parser.ForeachBlock, *parser.IfBlock, *parser.SwitchBlock:
+		return true
+	}
+	return false
+}
+
+// expansionPath returns the definitions enclosing line, outermost first,
+// ending with the innermost expandable block. It returns nil when line is
+// not inside such a block.
+func expansionPath(defs []parser.Definition, line int) []parser.Definition {
+	for _, def := range defs {
+		start, end := def.Pos().Line, def.End().Line
+		if inst, ok := def.(*parser.TemplateInstantiation); ok && len(inst.Blocks) == 0 {
+			// The end of a #use without #fill blocks is not precise.
+			end = start
+		}
+		if line < start || line > end {
+			continue
+		}
+		for _, children := range childDefinitions(def) {
+			if sub := expansionPath(children, line); sub != nil {
+				return append([]parser.Definition{def}, sub...)
+			}
+		}
+		if isExpandable(def) {
+			return []parser.Definition{def}
+		}
+	}
+	return nil
+}
+
+// outermostDirectives returns the paths to the expandable blocks of defs
+// that are not nested in another one. Template definitions are skipped.
+func outermostDirectives(defs []parser.Definition, prefix []parser.Definition) [][]parser.Definition {
+	var paths [][]parser.Definition
+	for _, def := range defs {
+		path := append(append([]parser.Definition(nil), prefix...), def)
+		if isExpandable(def) {
+			paths = append(paths, path)
+			continue
+		}
+		if obj, ok := def.(*parser.ObjectNode); ok {
+			paths = append(paths, outermostDirectives(obj.Subnode.Definitions, path)...)
+		}
+	}
+	return paths
+}
+
+func childDefinitions(def parser.Definition) [][]parser.Definition {
+	switch d := def.(type) {
+	case *parser.ObjectNode:
+		return [][]parser.Definition{d.Subnode.Definitions}
+	case *parser.IfBlock:
+		return [][]parser.Definition{d.Then, d.Else}
+	case *parser.SwitchBlock:
+		var bodies [][]parser.Definition
+		for _, b := range d.Branches() {
+			bodies = append(bodies, b.Body)
+		}
+		return bodies
+	case *parser.ForeachBlock:
+		return [][]parser.Definition{d.Body}
+	case *parser.TemplateDefinition:
+		return [][]parser.Definition{d.Body}
+	case *parser.SlotBlock:
+		return [][]parser.Definition{d.Body}
+	case *parser.TemplateInstantiation:
+		var bodies [][]parser.Definition
+		for _, b := range d.Blocks {
+			bodies = append(bodies, b.Body)
+		}
+		return bodies
+	}
+	return nil
+}
+
+func containsDefinition(defs []parser.Definition, def parser.Definition) bool {
+	for _, d := range defs {
+		if d == def {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,6 +29,14 @@ type ProjectTree struct {
 	Templates      map[string]*parser.TemplateDefinition
 	TemplateFiles  map[string]string // Maps template name to the file it came from
 	mu             sync.RWMutex
+
+	// Objects generated by #use, kept so that repeated evaluation yields
+	// the same definitions. Reset whenever a file changes.
+	expansions map[*parser.TemplateInstantiation]*parser.ObjectNode
+	expMu      sync.Mutex
+
+	// Types declared in the #Types section of the schema.
+	schemaTypes map[string]*TypeDef
 }
 
 func (pt *ProjectTree) ScanDirectory(rootPath string) error {
@@ -161,6 +170,11 @@ type EvaluationContext struct {
 	Variables map[string]parser.Value
 	Parent    *EvaluationContext
 	Tree      *ProjectTree
+
+	// Set on the context of a template expansion.
+	template string
+	caller   *EvaluationContext            // context of the #use
+	slots    map[string]*EvaluationContext // filled slot -> #use context
 }
 
 func (ctx *EvaluationContext) Resolve(name string) parser.Value {
@@ -222,6 +236,7 @@ func NormalizeName(name string) string {
 func (pt *ProjectTree) RemoveFile(file string) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
+	pt.resetExpansions()
 	// Remove references for this file
 	delete(pt.FileReferences, file)
 
@@ -406,6 +421,7 @@ func (pt *ProjectTree) valToString(val parser.Value) string {
 func (pt *ProjectTree) AddFile(file string, config *parser.Configuration) {
 	pt.mu.Lock()
 	defer pt.mu.Unlock()
+	pt.resetExpansions()
 
 	// We call internal removeFile (without lock, as we hold it)
 	// But RemoveFile is public and locks.
@@ -574,6 +590,13 @@ func (pt *ProjectTree) populateNode(node *ProjectNode, file string, config *pars
 			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
 			pt.indexNestedDefinitions(node, file, d.Then, config.Comments, config.Pragmas, true, id+":then")
 			pt.indexNestedDefinitions(node, file, d.Else, config.Comments, config.Pragmas, true, id+":else")
+		case *parser.SwitchBlock:
+			fileFragment.Definitions = append(fileFragment.Definitions, d)
+			pt.indexSwitch(node, file, d, config.Comments, config.Pragmas)
+		case *parser.SlotBlock:
+			fileFragment.Definitions = append(fileFragment.Definitions, d)
+			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+			pt.indexNestedDefinitions(node, file, d.Body, config.Comments, config.Pragmas, true, id+":slot")
 		case *parser.ForeachBlock:
 			fileFragment.Definitions = append(fileFragment.Definitions, d)
 			pt.IndexValue(file, d.Iterable)
@@ -618,6 +641,7 @@ func (pt *ProjectTree) populateNode(node *ProjectNode, file string, config *pars
 			for _, arg := range d.Arguments {
 				pt.IndexValue(file, arg.Value)
 			}
+			pt.indexTemplateBlocks(node, file, d, config.Comments, config.Pragmas)
 		default:
 			fileFragment.Definitions = append(fileFragment.Definitions, d)
 		}
@@ -714,6 +738,13 @@ func (pt *ProjectTree) addObjectFragment(node *ProjectNode, file string, obj *pa
 			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
 			pt.indexNestedDefinitions(node, file, d.Then, comments, pragmas, true, id+":then")
 			pt.indexNestedDefinitions(node, file, d.Else, comments, pragmas, true, id+":else")
+		case *parser.SwitchBlock:
+			frag.Definitions = append(frag.Definitions, d)
+			pt.indexSwitch(node, file, d, comments, pragmas)
+		case *parser.SlotBlock:
+			frag.Definitions = append(frag.Definitions, d)
+			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+			pt.indexNestedDefinitions(node, file, d.Body, comments, pragmas, true, id+":slot")
 		case *parser.ForeachBlock:
 			frag.Definitions = append(frag.Definitions, d)
 			pt.IndexValue(file, d.Iterable)
@@ -756,6 +787,7 @@ func (pt *ProjectTree) addObjectFragment(node *ProjectNode, file string, obj *pa
 			for _, arg := range d.Arguments {
 				pt.IndexValue(file, arg.Value)
 			}
+			pt.indexTemplateBlocks(node, file, d, comments, pragmas)
 		default:
 			frag.Definitions = append(frag.Definitions, d)
 		}
@@ -949,6 +981,11 @@ func (pt *ProjectTree) indexNestedDefinitions(node *ProjectNode, file string, de
 			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
 			pt.indexNestedDefinitions(node, file, d.Then, comments, pragmas, true, id+":then")
 			pt.indexNestedDefinitions(node, file, d.Else, comments, pragmas, true, id+":else")
+		case *parser.SwitchBlock:
+			pt.indexSwitch(node, file, d, comments, pragmas)
+		case *parser.SlotBlock:
+			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+			pt.indexNestedDefinitions(node, file, d.Body, comments, pragmas, true, id+":slot")
 		case *parser.ForeachBlock:
 			pt.IndexValue(file, d.Iterable)
 			id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
@@ -988,10 +1025,33 @@ func (pt *ProjectTree) indexNestedDefinitions(node *ProjectNode, file string, de
 			for _, arg := range d.Arguments {
 				pt.IndexValue(file, arg.Value)
 			}
+			pt.indexTemplateBlocks(node, file, d, comments, pragmas)
 		}
 	}
 }
 
+// indexTemplateBlocks indexes the #fill blocks of a #use as conditional
+// fragments with BranchID "L:C:fill:<slot>".
+func (pt *ProjectTree) indexTemplateBlocks(node *ProjectNode, file string, d *parser.TemplateInstantiation, comments []parser.Comment, pragmas []parser.Pragma) {
+	id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+	for _, b := range d.Blocks {
+		pt.indexNestedDefinitions(node, file, b.Body, comments, pragmas, true, id+":fill:"+b.Name)
+	}
+}
+
+// indexSwitch indexes each branch of a #switch as a conditional fragment
+// with BranchID "L:C:<branch>", e.g. "12:1:case0" or "12:1:default".
+func (pt *ProjectTree) indexSwitch(node *ProjectNode, file string, d *parser.SwitchBlock, comments []parser.Comment, pragmas []parser.Pragma) {
+	pt.IndexValue(file, d.Subject)
+	id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+	for _, b := range d.Branches() {
+		for _, v := range b.Values {
+			pt.IndexValue(file, v)
+		}
+		pt.indexNestedDefinitions(node, file, b.Body, comments, pragmas, true, id+":"+b.ID)
+	}
+}
+
 func (pt *ProjectTree) findDoc(comments []parser.Comment, pos parser.Position) string {
 	var docBuilder strings.Builder
 	targetLine := pos.Line - 1
@@ -1152,10 +1212,10 @@ func (pt *ProjectTree) ResolveReferences(activeFragments map[*Fragment]bool) {
 		pt.RebuildIndex()
 	}
 
-	// Iterate map
-	pt.References = nil // Clear legacy slice to rebuild it consistent with map
-
-	for _, refs := range pt.FileReferences {
+	// References in inactive fragments are left out of the legacy slice,
+	// which is rebuilt once the symbol fields are linked.
+	inactive := make(map[string]map[int]bool)
+	for file, refs := range pt.FileReferences {
 		for i := range refs {
 			ref := &refs[i]
 			container := pt.getNodeContaining(ref.File, ref.Position)
@@ -1174,6 +1234,10 @@ func (pt *ProjectTree) ResolveReferences(activeFragments map[*Fragment]bool) {
 
 				if parentFrag != nil && parentFrag.IsConditional {
 					if !activeFragments[parentFrag] {
+						if inactive[file] == nil {
+							inactive[file] = make(map[int]bool)
+						}
+						inactive[file][i] = true
 						continue
 					}
 				}
@@ -1186,7 +1250,6 @@ func (pt *ProjectTree) ResolveReferences(activeFragments map[*Fragment]bool) {
 			} else {
 				ref.Target = pt.resolveName(container, ref.Name, nil)
 			}
-			pt.References = append(pt.References, *ref) // Keep legacy slice updated
 		}
 	}
 
@@ -1209,6 +1272,151 @@ func (pt *ProjectTree) ResolveReferences(activeFragments map[*Fragment]bool) {
 			}
 		}
 	})
+
+	pt.resolveSymbolReferences()
+
+	pt.References = nil
+	for file, refs := range pt.FileReferences {
+		for i, ref := range refs {
+			if !inactive[file][i] {
+				pt.References = append(pt.References, ref)
+			}
+		}
+	}
+}
+
+// symbolField is a field naming an object outside the scope the field is
+// written in.
+type symbolField struct {
+	class string // class of the object holding the field, "" for any class
+	// path is the field name, preceded by the names of the children holding
+	// it, e.g. "Parameters.param1".
+	path string
+	// resolve returns the object called name for the object n of the class.
+	resolve func(pt *ProjectTree, n *ProjectNode, name string) *ProjectNode
+}
+
+// symbolFields lists the fields linked by resolveSymbolReferences: the
+// NextState and NextStateError of a StateMachineEvent name a state of its
+// StateMachine, the param1 of a PrepareNextState Message names a state of
+// the application, and the Destination of a Message and the
+// TimingDataSource of a scheduler name any object.
+var symbolFields = []symbolField{
+	{"StateMachineEvent", "NextState", resolveMachineState},
+	{"StateMachineEvent", "NextStateError", resolveMachineState},
+	{"Message", "Destination", resolveInScope},
+	{"Message", "Parameters.param1", resolveNextState},
+	{"", "TimingDataSource", resolveInScope},
+}
+
+func resolveInScope(pt *ProjectTree, n *ProjectNode, name string) *ProjectNode {
+	return pt.resolveName(n, name, nil)
+}
+
+// resolveMachineState finds a state of the StateMachine holding the event n.
+func resolveMachineState(pt *ProjectTree, n *ProjectNode, name string) *ProjectNode {
+	if n.Parent == nil || n.Parent.Parent == nil {
+		return nil
+	}
+	if s := n.Parent.Parent.Children[NormalizeName(name)]; s != nil && strings.HasPrefix(s.RealName, "+") {
+		return s
+	}
+	return nil
+}
+
+// resolveNextState finds a state of the application the PrepareNextState
+// Message n is sent to.
+func resolveNextState(pt *ProjectTree, n *ProjectNode, name string) *ProjectNode {
+	fs := n.Fields["Function"]
+	if len(fs) == 0 || pt.valToString(fs[len(fs)-1].Value) != "PrepareNextState" {
+		return nil
+	}
+	dest, _, _, ok := symbolName(n.Fields["Destination"])
+	if !ok {
+		return nil
+	}
+	app := pt.resolveName(n, dest, nil)
+	if app == nil || app.Children["States"] == nil {
+		return nil
+	}
+	return app.Children["States"].Children[NormalizeName(name)]
+}
+
+// resolveSymbolReferences links the fields of symbolFields to the objects
+// they name. Quoted names, which are not indexed as references, are added.
+func (pt *ProjectTree) resolveSymbolReferences() {
+	if pt.FileReferences == nil {
+		return
+	}
+	byPos := make(map[string]map[parser.Position]int)
+	pt.walk(func(n *ProjectNode) {
+		class := n.Metadata["Class"]
+		for _, sf := range symbolFields {
+			if sf.class != "" && sf.class != class {
+				continue
+			}
+			holder := n
+			parts := strings.Split(sf.path, ".")
+			for _, part := range parts[:len(parts)-1] {
+				if holder = holder.Children[part]; holder == nil {
+					break
+				}
+			}
+			if holder != nil {
+				pt.linkSymbol(byPos, n, holder.Fields[parts[len(parts)-1]], sf.resolve)
+			}
+		}
+	})
+}
+
+// symbolName returns the name held by the last of fs and the position of
+// the name. Computed names are not symbols.
+func symbolName(fs []EvaluatedField) (string, parser.Position, string, bool) {
+	if len(fs) == 0 {
+		return "", parser.Position{}, "", false
+	}
+	f := fs[len(fs)-1]
+	switch t := f.Value.(type) {
+	case *parser.StringValue:
+		pos := t.Position
+		if t.Quoted {
+			pos.Column++
+		}
+		return t.Value, pos, f.File, true
+	case *parser.ReferenceValue:
+		return t.Value, t.Position, f.File, true
+	}
+	return "", parser.Position{}, "", false
+}
+
+// linkSymbol points the reference held by the last of fs to the object
+// resolve finds for n. byPos indexes the references of each file by
+// position as they are looked up.
+func (pt *ProjectTree) linkSymbol(byPos map[string]map[parser.Position]int, n *ProjectNode, fs []EvaluatedField, resolve func(*ProjectTree, *ProjectNode, string) *ProjectNode) {
+	name, pos, file, ok := symbolName(fs)
+	if !ok {
+		return
+	}
+	target := resolve(pt, n, name)
+	if target == nil {
+		return
+	}
+	idx, ok := byPos[file]
+	if !ok {
+		idx = make(map[parser.Position]int)
+		for i, ref := range pt.FileReferences[file] {
+			if _, dup := idx[ref.Position]; !dup {
+				idx[ref.Position] = i
+			}
+		}
+		byPos[file] = idx
+	}
+	if i, ok := idx[pos]; ok {
+		pt.FileReferences[file][i].Target = target
+		return
+	}
+	idx[pos] = len(pt.FileReferences[file])
+	pt.FileReferences[file] = append(pt.FileReferences[file], Reference{Name: name, Position: pos, File: file, Target: target})
 }
 
 func (pt *ProjectTree) getBranchIDForNodeFragment(node *ProjectNode, targetFrag *Fragment) string {
@@ -1275,20 +1483,24 @@ func (pt *ProjectTree) GetActiveField(node *ProjectNode, key string, container *
 				targetBranchID := parts[2]
 
 				var actualIfBlock *parser.IfBlock
+				var actualSwitch *parser.SwitchBlock
 				if node.Parent != nil {
 					for _, pf := range node.Parent.Fragments {
 						for _, pdef := range pf.Definitions {
-							if ib, ok := pdef.(*parser.IfBlock); ok {
-								if ib.Position.Line == ifBlockLine && ib.Position.Column == ifBlockCol {
-									actualIfBlock = ib
-									break
-								}
+							if pdef.Pos().Line != ifBlockLine || pdef.Pos().Column != ifBlockCol {
+								continue
+							}
+							switch b := pdef.(type) {
+							case *parser.IfBlock:
+								actualIfBlock = b
+							case *parser.SwitchBlock:
+								actualSwitch = b
 							}
 						}
 					}
 				}
 
-				if actualIfBlock != nil {
+				if actualIfBlock != nil || actualSwitch != nil {
 					evalCtx := &EvaluationContext{
 						Variables: make(map[string]parser.Value),
 						Parent:    nil,
@@ -1306,11 +1518,17 @@ func (pt *ProjectTree) GetActiveField(node *ProjectNode, key string, container *
 						}
 					}
 
-					cond := pt.EvaluateValue(actualIfBlock.Condition, evalCtx)
-					isTrue := pt.IsTrue(cond)
-					activeBranch := "then"
-					if !isTrue {
-						activeBranch = "else"
+					activeBranch := ""
+					if actualSwitch != nil {
+						if b := pt.SelectSwitchBranch(actualSwitch, evalCtx); b != nil {
+							activeBranch = b.ID
+						}
+					} else {
+						cond := pt.EvaluateValue(actualIfBlock.Condition, evalCtx)
+						activeBranch = "then"
+						if !pt.IsTrue(cond) {
+							activeBranch = "else"
+						}
 					}
 
 					if activeBranch == targetBranchID {
@@ -1376,43 +1594,20 @@ func (pt *ProjectTree) EvaluateDefinitions(defs []parser.Definition, ctx *Evalua
 		switch d := def.(type) {
 		case *parser.IfBlock:
 			result = append(result, EvaluatedDefinition{Def: d, Ctx: ctx, File: file})
+		case *parser.SwitchBlock:
+			result = append(result, EvaluatedDefinition{Def: d, Ctx: ctx, File: file})
 		case *parser.ForeachBlock:
 			result = append(result, EvaluatedDefinition{Def: d, Ctx: ctx, File: file})
 		case *parser.TemplateInstantiation:
-			var tdef *parser.TemplateDefinition
-			if pt.Templates != nil {
-				tdef = pt.Templates[d.Template]
-			}
-
-			if tdef != nil {
-				templateCtx := &EvaluationContext{
-					Variables: make(map[string]parser.Value),
-					Parent:    ctx,
-					Tree:      pt,
-				}
-				// Bind arguments
-				argMap := make(map[string]parser.Value)
-				for _, arg := range d.Arguments {
-					argMap[arg.Name] = pt.EvaluateValue(arg.Value, ctx)
-				}
-
-				for _, param := range tdef.Parameters {
-					if val, ok := argMap[param.Name]; ok {
-						templateCtx.Variables[param.Name] = val
-					} else {
-						templateCtx.Variables[param.Name] = param.DefaultValue
-					}
-				}
-				// The template generates an object with Name d.Name
-				obj := &parser.ObjectNode{
-					Position: d.Position,
-					Name:     &parser.StringValue{Value: d.Name, Quoted: false},
-					Subnode: parser.Subnode{
-						Definitions: tdef.Body,
-					},
-				}
+			if obj, templateCtx := pt.ExpandTemplate(d, ctx); obj != nil {
 				result = append(result, EvaluatedDefinition{Def: obj, Ctx: templateCtx, File: file})
 			}
+		case *parser.SlotBlock:
+			slotCtx := ctx
+			if c := ctx.slotScope(d.Name); c != nil {
+				slotCtx = c
+			}
+			result = append(result, pt.EvaluateDefinitions(d.Body, slotCtx, file)...)
 		case *parser.TemplateDefinition:
 			// Skip template definitions during evaluation
 		case *parser.VariableDefinition:
@@ -1499,6 +1694,40 @@ func (pt *ProjectTree) IsTrue(val parser.Value) bool {
 	return false
 }
 
+// SelectSwitchBranch returns the branch of s selected in ctx: the first
+// #case with a value equal to the subject, otherwise #default. It returns nil
+// when no branch matches.
+func (pt *ProjectTree) SelectSwitchBranch(s *parser.SwitchBlock, ctx *EvaluationContext) *parser.SwitchBranch {
+	subject := pt.EvaluateValue(s.Subject, ctx)
+	branches := s.Branches()
+	for i := range branches {
+		for _, v := range branches[i].Values {
+			if pt.SwitchValueEqual(subject, pt.EvaluateValue(v, ctx)) {
+				return &branches[i]
+			}
+		}
+	}
+	for i := range branches {
+		if branches[i].ID == "default" {
+			return &branches[i]
+		}
+	}
+	return nil
+}
+
+// SwitchValueEqual compares a #switch subject with a #case value. Bare
+// identifiers compare equal to strings with the same text.
+func (pt *ProjectTree) SwitchValueEqual(a, b parser.Value) bool {
+	text := func(v parser.Value) parser.Value {
+		if r, ok := v.(*parser.ReferenceValue); ok {
+			return &parser.StringValue{Value: r.Value, Quoted: true}
+		}
+		return v
+	}
+	res := pt.compute(text(a), parser.Token{Type: parser.TokenSymbol, Value: "=="}, text(b))
+	return res != nil && pt.IsTrue(res)
+}
+
 func (pt *ProjectTree) FindNode(root *ProjectNode, name string, predicate func(*ProjectNode) bool, strict bool) *ProjectNode {
 	pt.mu.RLock()
 	defer pt.mu.RUnlock()
@@ -1627,6 +1856,41 @@ func (pt *ProjectTree) walk(visitor func(*ProjectNode)) {
 	}
 }
 
+// OrderedChildren returns the object children of node in the order they
+// are first written. Children that do not come from a plain definition,
+// such as those of conditional blocks, follow sorted by name.
+func (pt *ProjectTree) OrderedChildren(node *ProjectNode) []*ProjectNode {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.orderedChildren(node)
+}
+
+func (pt *ProjectTree) orderedChildren(node *ProjectNode) []*ProjectNode {
+	var children []*ProjectNode
+	seen := map[*ProjectNode]bool{}
+	for _, frag := range node.Fragments {
+		for _, def := range frag.Definitions {
+			obj, ok := def.(*parser.ObjectNode)
+			if !ok {
+				continue
+			}
+			child := node.Children[NormalizeName(pt.valToString(obj.Name))]
+			if child != nil && !seen[child] {
+				seen[child] = true
+				children = append(children, child)
+			}
+		}
+	}
+	var rest []*ProjectNode
+	for _, child := range node.Children {
+		if !seen[child] {
+			rest = append(rest, child)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool { return rest[i].Name < rest[j].Name })
+	return append(children, rest...)
+}
+
 func (pt *ProjectTree) walkRecursive(node *ProjectNode, visitor func(*ProjectNode)) {
 	visitor(node)
 	for _, child := range node.Children {
@@ -1861,6 +2125,8 @@ func (pt *ProjectTree) Clone() *ProjectTree {
 		newPT.Templates[k] = v
 	}
 
+	newPT.schemaTypes = pt.schemaTypes
+
 	// Clone TemplateFiles
 	for k, v := range pt.TemplateFiles {
 		newPT.TemplateFiles[k] = v
@@ -2208,6 +2474,44 @@ func (pt *ProjectTree) IsSignal(node *ProjectNode) bool {
 	return false
 }
 
+// ApplicationStates returns the RealTimeStates in the States container of a
+// RealTimeApplication, in declaration order.
+func (pt *ProjectTree) ApplicationStates(app *ProjectNode) []*ProjectNode {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	statesNode := app.Children["States"]
+	if statesNode == nil {
+		return nil
+	}
+	var states []*ProjectNode
+	for _, state := range pt.orderedChildren(statesNode) {
+		if state.Metadata["Class"] == "RealTimeState" {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// StateThreads returns the RealTimeThreads of a state, those of its Threads
+// container first and then those declared directly in it.
+func (pt *ProjectTree) StateThreads(state *ProjectNode) []*ProjectNode {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	var threads []*ProjectNode
+	collect := func(container *ProjectNode) {
+		for _, child := range pt.orderedChildren(container) {
+			if child.Metadata["Class"] == "RealTimeThread" {
+				threads = append(threads, child)
+			}
+		}
+	}
+	if tc, ok := state.Children["Threads"]; ok {
+		collect(tc)
+	}
+	collect(state)
+	return threads
+}
+
 func (pt *ProjectTree) GetSignalInfo(node *ProjectNode) (*ProjectNode, string) {
 	if node.Parent == nil {
 		return nil, ""
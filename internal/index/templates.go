@@ -0,0 +1,306 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// MaxTemplateDepth bounds nested #use expansion, both when expanding and
+// when the validator follows expansion chains.
+const MaxTemplateDepth = 32
+
+// TemplateChain returns the template called name preceded by the templates it
+// extends, base first.
+func (pt *ProjectTree) TemplateChain(name string) ([]*parser.TemplateDefinition, error) {
+	var chain []*parser.TemplateDefinition
+	seen := map[string]bool{}
+	path := []string{}
+	for name != "" {
+		if seen[name] {
+			return nil, fmt.Errorf("template inheritance cycle: %s", strings.Join(append(path, name), " extends "))
+		}
+		tdef := pt.Templates[name]
+		if tdef == nil {
+			if len(path) == 0 {
+				return nil, fmt.Errorf("unknown template '%s'", name)
+			}
+			return nil, fmt.Errorf("template '%s' extends unknown template '%s'", path[len(path)-1], name)
+		}
+		seen[name] = true
+		path = append(path, name)
+		chain = append([]*parser.TemplateDefinition{tdef}, chain...)
+		name = tdef.Base
+	}
+	return chain, nil
+}
+
+// TemplateParameters returns the parameters of the last template of chain,
+// inherited ones first. A derived template may re-declare a parameter to
+// change its type or default value.
+func TemplateParameters(chain []*parser.TemplateDefinition) []parser.TemplateParameter {
+	var params []parser.TemplateParameter
+	index := map[string]int{}
+	for _, tdef := range chain {
+		for _, p := range tdef.Parameters {
+			if i, ok := index[p.Name]; ok {
+				params[i] = p
+				continue
+			}
+			index[p.Name] = len(params)
+			params = append(params, p)
+		}
+	}
+	return params
+}
+
+// TemplateBody composes the body of the last template of chain. The #slot
+// blocks of a derived template override the base slots with the same name;
+// its other definitions, and slots the base does not have, are appended to
+// the base body. The #fill blocks of a #use then replace the slots they name.
+func TemplateBody(chain []*parser.TemplateDefinition, fills []parser.TemplateBlock) []parser.Definition {
+	var body []parser.Definition
+	for i, tdef := range chain {
+		if i == 0 {
+			body = tdef.Body
+			continue
+		}
+		overrides := map[string]*parser.SlotBlock{}
+		for _, def := range tdef.Body {
+			if s, ok := def.(*parser.SlotBlock); ok {
+				overrides[s.Name] = s
+			}
+		}
+		body, _ = replaceSlots(body, overrides)
+		defined := TemplateSlots(body)
+		body = append([]parser.Definition(nil), body...)
+		for _, def := range tdef.Body {
+			if s, ok := def.(*parser.SlotBlock); ok && defined[s.Name] != nil {
+				continue
+			}
+			body = append(body, def)
+		}
+	}
+	if len(fills) > 0 {
+		replacements := map[string]*parser.SlotBlock{}
+		for _, f := range fills {
+			replacements[f.Name] = &parser.SlotBlock{Position: f.Position, EndPosition: f.EndPosition, Name: f.Name, Body: f.Body}
+		}
+		body, _ = replaceSlots(body, replacements)
+	}
+	return body
+}
+
+// TemplateSlots returns the #slot blocks of a template body by name,
+// including slots nested in objects and conditional blocks.
+func TemplateSlots(defs []parser.Definition) map[string]*parser.SlotBlock {
+	slots := map[string]*parser.SlotBlock{}
+	var walk func([]parser.Definition)
+	walk = func(defs []parser.Definition) {
+		for _, def := range defs {
+			switch d := def.(type) {
+			case *parser.SlotBlock:
+				if slots[d.Name] == nil {
+					slots[d.Name] = d
+				}
+				walk(d.Body)
+			case *parser.ObjectNode:
+				walk(d.Subnode.Definitions)
+			case *parser.IfBlock:
+				walk(d.Then)
+				walk(d.Else)
+			case *parser.SwitchBlock:
+				for _, b := range d.Branches() {
+					walk(b.Body)
+				}
+			case *parser.ForeachBlock:
+				walk(d.Body)
+			case *parser.TemplateInstantiation:
+				for _, b := range d.Blocks {
+					walk(b.Body)
+				}
+			}
+		}
+	}
+	walk(defs)
+	return slots
+}
+
+// replaceSlots returns defs with the slots named in repl replaced. Nodes on
+// the path to a replaced slot are copied; the input is never modified.
+func replaceSlots(defs []parser.Definition, repl map[string]*parser.SlotBlock) ([]parser.Definition, bool) {
+	var out []parser.Definition
+	changed := false
+	for i, def := range defs {
+		newDef := def
+		switch d := def.(type) {
+		case *parser.SlotBlock:
+			if r, ok := repl[d.Name]; ok {
+				newDef = r
+			} else if body, ok := replaceSlots(d.Body, repl); ok {
+				c := *d
+				c.Body = body
+				newDef = &c
+			}
+		case *parser.ObjectNode:
+			if body, ok := replaceSlots(d.Subnode.Definitions, repl); ok {
+				c := *d
+				c.Subnode.Definitions = body
+				newDef = &c
+			}
+		case *parser.IfBlock:
+			then, ok1 := replaceSlots(d.Then, repl)
+			els, ok2 := replaceSlots(d.Else, repl)
+			if ok1 || ok2 {
+				c := *d
+				c.Then, c.Else = then, els
+				newDef = &c
+			}
+		case *parser.SwitchBlock:
+			c := *d
+			c.Cases = make([]parser.SwitchCase, len(d.Cases))
+			copy(c.Cases, d.Cases)
+			ok := false
+			for j := range c.Cases {
+				if body, ok1 := replaceSlots(c.Cases[j].Body, repl); ok1 {
+					c.Cases[j].Body = body
+					ok = true
+				}
+			}
+			if body, ok1 := replaceSlots(c.Default, repl); ok1 {
+				c.Default = body
+				ok = true
+			}
+			if ok {
+				newDef = &c
+			}
+		case *parser.ForeachBlock:
+			if body, ok := replaceSlots(d.Body, repl); ok {
+				c := *d
+				c.Body = body
+				newDef = &c
+			}
+		case *parser.TemplateInstantiation:
+			c := *d
+			c.Blocks = make([]parser.TemplateBlock, len(d.Blocks))
+			copy(c.Blocks, d.Blocks)
+			ok := false
+			for j := range c.Blocks {
+				if body, ok1 := replaceSlots(c.Blocks[j].Body, repl); ok1 {
+					c.Blocks[j].Body = body
+					ok = true
+				}
+			}
+			if ok {
+				newDef = &c
+			}
+		}
+		if newDef != def && !changed {
+			changed = true
+			out = append([]parser.Definition(nil), defs[:i]...)
+		}
+		if changed {
+			out = append(out, newDef)
+		}
+	}
+	if !changed {
+		return defs, false
+	}
+	return out, true
+}
+
+// ExpandTemplate returns the object generated by a #use and the context its
+// body is evaluated in. Template bodies are lexically scoped: they see their
+// parameters and the global variables, not the variables of the #use site.
+// Arguments and #fill blocks are evaluated at the #use site. It returns nil
+// for unknown templates and recursive expansion.
+func (pt *ProjectTree) ExpandTemplate(inst *parser.TemplateInstantiation, ctx *EvaluationContext) (*parser.ObjectNode, *EvaluationContext) {
+	chain, err := pt.TemplateChain(inst.Template)
+	if err != nil {
+		return nil, nil
+	}
+	depth := 0
+	for f := ctx.templateFrame(); f != nil; f = f.caller.templateFrame() {
+		if f.template == inst.Template || depth >= MaxTemplateDepth {
+			return nil, nil
+		}
+		depth++
+	}
+
+	root := ctx
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	templateCtx := &EvaluationContext{
+		Variables: make(map[string]parser.Value),
+		Parent:    root,
+		Tree:      pt,
+		template:  inst.Template,
+		caller:    ctx,
+		slots:     make(map[string]*EvaluationContext),
+	}
+
+	argMap := make(map[string]parser.Value)
+	for _, arg := range inst.Arguments {
+		argMap[arg.Name] = pt.EvaluateValue(arg.Value, ctx)
+	}
+	for _, param := range TemplateParameters(chain) {
+		if val, ok := argMap[param.Name]; ok {
+			templateCtx.Variables[param.Name] = val
+		} else {
+			templateCtx.Variables[param.Name] = param.DefaultValue
+		}
+	}
+	for _, b := range inst.Blocks {
+		templateCtx.slots[b.Name] = ctx
+	}
+
+	// The template generates an object with Name inst.Name. Its definitions
+	// only depend on the templates, so the object is shared by every
+	// evaluation of inst.
+	pt.expMu.Lock()
+	defer pt.expMu.Unlock()
+	obj := pt.expansions[inst]
+	if obj == nil {
+		obj = &parser.ObjectNode{
+			Position: inst.Position,
+			Name:     &parser.StringValue{Value: inst.Name, Quoted: false},
+			Subnode: parser.Subnode{
+				Definitions: TemplateBody(chain, inst.Blocks),
+			},
+		}
+		if pt.expansions == nil {
+			pt.expansions = make(map[*parser.TemplateInstantiation]*parser.ObjectNode)
+		}
+		pt.expansions[inst] = obj
+	}
+	return obj, templateCtx
+}
+
+func (pt *ProjectTree) resetExpansions() {
+	pt.expMu.Lock()
+	pt.expansions = nil
+	pt.expMu.Unlock()
+}
+
+// templateFrame returns the context of the template expansion ctx belongs to,
+// or nil outside templates.
+func (ctx *EvaluationContext) templateFrame() *EvaluationContext {
+	for c := ctx; c != nil; c = c.Parent {
+		if c.template != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+// slotScope returns the context a filled #slot is evaluated in: the #use
+// site of the enclosing template expansion. It returns nil for slots that
+// were not filled.
+func (ctx *EvaluationContext) slotScope(name string) *EvaluationContext {
+	if f := ctx.templateFrame(); f != nil {
+		return f.slots[name]
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// StructureClass is the MARTe class that registers a structured type from
+// the configuration. Each subnode of such an object is a member with a Type
+// and an optional NumberOfElements.
+const StructureClass = "IntrospectionStructure"
+
+// TypeMember is one member of a structured type.
+type TypeMember struct {
+	Name             string
+	Type             string
+	NumberOfElements int64
+	Node             *ProjectNode // nil for members declared in the schema
+}
+
+// TypeDef is a user-defined signal type. Structs list their members in
+// declaration order; enums name the integer type that stores them.
+type TypeDef struct {
+	Name    string
+	Members []TypeMember
+	Base    string   // storage type of an enum
+	Values  []string // labels of an enum
+	Node    *ProjectNode
+}
+
+func (t *TypeDef) IsEnum() bool { return t.Base != "" }
+
+// LayoutEntry is a flattened struct member with its offset in bytes.
+type LayoutEntry struct {
+	Path             string // dotted member path, e.g. "Pos.X"
+	Type             string
+	NumberOfElements int64
+	Offset           int64
+	Size             int64 // total size of the member; -1 if it has a variable size
+}
+
+// BaseTypeSize returns the size in bytes of a MARTe base type, -1 for
+// string and 0 for names that are not base types.
+func BaseTypeSize(name string) int64 {
+	switch name {
+	case "uint8", "int8", "char8", "bool":
+		return 1
+	case "uint16", "int16":
+		return 2
+	case "uint32", "int32", "float32":
+		return 4
+	case "uint64", "int64", "float64":
+		return 8
+	case "string":
+		return -1
+	}
+	return 0
+}
+
+func IsBaseType(name string) bool {
+	return BaseTypeSize(name) != 0
+}
+
+// IntTypeRange returns the smallest and largest values of an integer base
+// type.
+func IntTypeRange(name string) (min, max *big.Int, ok bool) {
+	var bits uint
+	signed := true
+	switch name {
+	case "int8", "int16", "int32", "int64":
+	case "uint8", "uint16", "uint32", "uint64":
+		signed = false
+	default:
+		return nil, nil, false
+	}
+	bits = uint(BaseTypeSize(name) * 8)
+	if signed {
+		max = new(big.Int).Lsh(big.NewInt(1), bits-1)
+		min = new(big.Int).Neg(max)
+		max.Sub(max, big.NewInt(1))
+	} else {
+		min = big.NewInt(0)
+		max = new(big.Int).Lsh(big.NewInt(1), bits)
+		max.Sub(max, big.NewInt(1))
+	}
+	return min, max, true
+}
+
+// FloatTypeMax returns the largest finite magnitude of a float base type.
+func FloatTypeMax(name string) (float64, bool) {
+	switch name {
+	case "float32":
+		return math.MaxFloat32, true
+	case "float64":
+		return math.MaxFloat64, true
+	}
+	return 0, false
+}
+
+// TypeRange describes the values a numeric base type can represent, e.g.
+// "0 .. 255", or returns "" for other types.
+func TypeRange(name string) string {
+	if min, max, ok := IntTypeRange(name); ok {
+		return fmt.Sprintf("%s .. %s", min, max)
+	}
+	if max, ok := FloatTypeMax(name); ok {
+		return fmt.Sprintf("%g .. %g", -max, max)
+	}
+	if name == "bool" {
+		return "0 .. 1"
+	}
+	return ""
+}
+
+// SetSchemaTypes registers the types declared in the schema. Types declared
+// with IntrospectionStructure objects take precedence.
+func (pt *ProjectTree) SetSchemaTypes(types map[string]*TypeDef) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.schemaTypes = types
+}
+
+// LookupType returns the user-defined type with the given name, or nil.
+func (pt *ProjectTree) LookupType(name string) *TypeDef {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.lookupType(name)
+}
+
+func (pt *ProjectTree) lookupType(name string) *TypeDef {
+	if name == "" || IsBaseType(name) {
+		return nil
+	}
+	for _, n := range pt.NodeMap[name] {
+		if n.Name == name && n.Metadata["Class"] == StructureClass {
+			return pt.structureType(n)
+		}
+	}
+	return pt.schemaTypes[name]
+}
+
+// UserTypes returns every user-defined type sorted by name.
+func (pt *ProjectTree) UserTypes() []*TypeDef {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	seen := map[string]bool{}
+	var types []*TypeDef
+	pt.walk(func(n *ProjectNode) {
+		if n.Metadata["Class"] == StructureClass && !seen[n.Name] {
+			seen[n.Name] = true
+			types = append(types, pt.structureType(n))
+		}
+	})
+	for name, t := range pt.schemaTypes {
+		if !seen[name] {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// structureType reads the members of an IntrospectionStructure object in
+// the order they are written.
+func (pt *ProjectTree) structureType(node *ProjectNode) *TypeDef {
+	t := &TypeDef{Name: node.Name, Node: node}
+	for _, child := range pt.orderedChildren(node) {
+		m := TypeMember{Name: child.Name, NumberOfElements: 1, Node: child}
+		if fs := child.Fields["Type"]; len(fs) > 0 {
+			m.Type = pt.valToString(pt.evaluate(fs[len(fs)-1].Value, child))
+		}
+		if fs := child.Fields["NumberOfElements"]; len(fs) > 0 {
+			if i, ok := pt.evaluate(fs[len(fs)-1].Value, child).(*parser.IntValue); ok {
+				m.NumberOfElements = i.Value
+			}
+		}
+		t.Members = append(t.Members, m)
+	}
+	return t
+}
+
+// TypeSize returns the size in bytes of a base or user-defined type. Struct
+// members are packed. It returns -1 for types of variable size and 0 for
+// unknown or recursive types.
+func (pt *ProjectTree) TypeSize(name string) int64 {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	return pt.typeSize(name, map[string]bool{})
+}
+
+func (pt *ProjectTree) typeSize(name string, visiting map[string]bool) int64 {
+	if size := BaseTypeSize(name); size != 0 {
+		return size
+	}
+	t := pt.lookupType(name)
+	if t == nil || visiting[name] {
+		return 0
+	}
+	if t.IsEnum() {
+		return BaseTypeSize(t.Base)
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	total := int64(0)
+	for _, m := range t.Members {
+		size := pt.typeSize(m.Type, visiting)
+		if size <= 0 {
+			return size
+		}
+		total += size * m.NumberOfElements
+	}
+	return total
+}
+
+// TypeLayout flattens a struct type into its base-typed members with their
+// offsets. Enum and struct members that cannot be sized end the layout.
+func (pt *ProjectTree) TypeLayout(name string) []LayoutEntry {
+	pt.mu.RLock()
+	defer pt.mu.RUnlock()
+	var entries []LayoutEntry
+	pt.layout(name, "", 0, map[string]bool{}, &entries)
+	return entries
+}
+
+func (pt *ProjectTree) layout(name, prefix string, offset int64, visiting map[string]bool, entries *[]LayoutEntry) int64 {
+	t := pt.lookupType(name)
+	if t == nil || t.IsEnum() || visiting[name] {
+		return 0
+	}
+	visiting[name] = true
+	defer delete(visiting, name)
+	start := offset
+	for _, m := range t.Members {
+		path := prefix + m.Name
+		size := pt.typeSize(m.Type, visiting)
+		if sub := pt.lookupType(m.Type); sub != nil && !sub.IsEnum() && m.NumberOfElements == 1 {
+			offset += pt.layout(m.Type, path+".", offset, visiting, entries)
+			continue
+		}
+		entry := LayoutEntry{Path: path, Type: m.Type, NumberOfElements: m.NumberOfElements, Offset: offset, Size: size}
+		if size > 0 {
+			entry.Size = size * m.NumberOfElements
+			offset += entry.Size
+		}
+		*entries = append(*entries, entry)
+		if size <= 0 {
+			break
+		}
+	}
+	return offset - start
+}
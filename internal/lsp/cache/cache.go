@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 
 	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
 	"github.com/marte-community/marte-dev-tools/internal/schema"
 )
 
@@ -84,6 +85,7 @@ func (s *Session) CreateView(id, root string) *View {
 		view:         v,
 		tree:         index.NewProjectTree(),
 		documents:    make(map[string]string),
+		parsed:       make(map[string]*parser.Document),
 		parserErrors: make(map[string][]error),
 	})
 	
@@ -96,6 +98,9 @@ type View struct {
 	root     string
 	session  *Session
 	snapshot atomic.Value // *Snapshot
+	// marteVersion selects the release schema of the workspace, overriding
+	// the #Target of the project schema.
+	marteVersion string
 }
 
 func (v *View) Snapshot() *Snapshot {
@@ -112,13 +117,29 @@ func (v *View) Root() string {
 	return v.root
 }
 
+// MARTeVersion returns the release the workspace is validated against, or
+// an empty string to use the #Target of the project schema.
+func (v *View) MARTeVersion() string {
+	return v.marteVersion
+}
+
+// SetMARTeVersion selects the release the workspace is validated against.
+// It is called when the view is created, before the view is shared.
+func (v *View) SetMARTeVersion(version string) {
+	v.marteVersion = version
+}
+
 type Snapshot struct {
 	view         *View
 	tree         *index.ProjectTree
 	schema       *schema.Schema
 	documents    map[string]string
+	parsed       map[string]*parser.Document // parse results of open documents
 	parserErrors map[string][]error
 	refCount     sync.WaitGroup // To track active usages? (Simplified: Go GC handles memory, we just need consistency)
+
+	resultsMu sync.Mutex
+	variables map[string]parser.Value // evaluated by the validation of this snapshot
 }
 
 func (s *Snapshot) Tree() *index.ProjectTree {
@@ -133,6 +154,12 @@ func (s *Snapshot) Documents() map[string]string {
 	return s.documents
 }
 
+// Parsed returns the parse results of open documents, used to re-parse
+// edits incrementally.
+func (s *Snapshot) Parsed() map[string]*parser.Document {
+	return s.parsed
+}
+
 func (s *Snapshot) ParserErrors() map[string][]error {
 	return s.parserErrors
 }
@@ -141,6 +168,21 @@ func (s *Snapshot) Schema() *schema.Schema {
 	return s.schema
 }
 
+// SetVariables records the variables evaluated by validating the snapshot.
+func (s *Snapshot) SetVariables(vars map[string]parser.Value) {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+	s.variables = vars
+}
+
+// Variables returns the variables evaluated by validating the snapshot, or
+// nil until it has been validated.
+func (s *Snapshot) Variables() map[string]parser.Value {
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+	return s.variables
+}
+
 // Clone creates a deep copy of the snapshot (and the underlying tree).
 // This is used when modifying the state.
 func (s *Snapshot) Clone(ctx context.Context) *Snapshot {
@@ -149,11 +191,15 @@ func (s *Snapshot) Clone(ctx context.Context) *Snapshot {
 		tree:         s.tree.Clone(), // This is the heavy part
 		schema:       s.schema,       // Schema is likely static or reloaded separately
 		documents:    make(map[string]string),
+		parsed:       make(map[string]*parser.Document),
 		parserErrors: make(map[string][]error),
 	}
 	for k, v := range s.documents {
 		newSnap.documents[k] = v
 	}
+	for k, v := range s.parsed {
+		newSnap.parsed[k] = v
+	}
 	for k, v := range s.parserErrors {
 		newSnap.parserErrors[k] = v
 	}
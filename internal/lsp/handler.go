@@ -13,16 +13,17 @@ import (
 
 	"github.com/marte-community/marte-dev-tools/internal/lsp/cache"
 	"github.com/marte-community/marte-dev-tools/internal/logger"
-	"github.com/marte-community/marte-dev-tools/internal/schema"
 )
 
 // RunServer starts the LSP server using the go-lsp framework over stdio.
 // It replaces the hand-rolled JSON-RPC loop that was previously here.
-func RunServer() {
+// marteVersion is the release validated against unless the client selects
+// another one in its initialization options.
+func RunServer(marteVersion string) {
 	SynchronousValidation = false
 	GlobalSession = cache.NewSession("default")
 
-	handler := &marteHandler{}
+	handler := &marteHandler{marteVersion: marteVersion}
 	srv := golspserver.NewServer(handler)
 	if err := srv.Run(context.Background(), golspserver.RunStdio()); err != nil {
 		logger.Printf("LSP server exited: %v\n", err)
@@ -31,11 +32,18 @@ func RunServer() {
 
 // marteHandler implements all go-lsp server handler interfaces.
 // Each method converts types and delegates to the existing Handle* functions.
-type marteHandler struct{}
+type marteHandler struct {
+	codeLensRefresh bool   // the client accepts workspace/codeLens/refresh
+	marteVersion    string // the release given with mdt lsp --marte-version
+}
 
 // ─── Lifecycle ────────────────────────────────────────────────────────────────
 
 func (h *marteHandler) Initialize(ctx context.Context, params *golsp.InitializeParams) (*golsp.InitializeResult, error) {
+	if ws := params.Capabilities.Workspace; ws != nil && ws.CodeLens != nil && ws.CodeLens.RefreshSupport != nil {
+		h.codeLensRefresh = *ws.CodeLens.RefreshSupport
+	}
+
 	root := ""
 	if params.RootURI != nil && *params.RootURI != "" {
 		root = uriToPath(string(*params.RootURI))
@@ -44,7 +52,14 @@ func (h *marteHandler) Initialize(ctx context.Context, params *golsp.InitializeP
 	}
 
 	if root != "" {
+		var options InitializationOptions
+		if len(params.InitializationOptions) > 0 {
+			if err := json.Unmarshal(params.InitializationOptions, &options); err != nil {
+				logger.Printf("Invalid initialization options: %v\n", err)
+			}
+		}
 		view := GlobalSession.CreateView("main", root)
+		view.SetMARTeVersion(selectMARTeVersion(h.marteVersion, options.MARTeVersion))
 		snap := view.Snapshot()
 		logger.Printf("Scanning workspace: %s\n", root)
 		if err := snap.Tree().ScanDirectory(root); err != nil {
@@ -53,7 +68,7 @@ func (h *marteHandler) Initialize(ctx context.Context, params *golsp.InitializeP
 		snap.Tree().ResolveReferences(nil)
 		snap.Tree().ResolveFields(nil)
 		view.SetSnapshot(snap)
-		GlobalSchema = schema.LoadFullSchema(root)
+		loadSchema(snap, root)
 		logger.Printf("Workspace ready\n")
 
 		// Trigger initial workspace-wide validation in the background.
@@ -73,6 +88,9 @@ func (h *marteHandler) Initialize(ctx context.Context, params *golsp.InitializeP
 			CompletionProvider: &golsp.CompletionOptions{
 				TriggerCharacters: []string{"=", " ", "@"},
 			},
+			ExecuteCommandProvider: &golsp.ExecuteCommandOptions{
+				Commands: []string{ExpandCommand},
+			},
 		},
 	}, nil
 }
@@ -108,6 +126,18 @@ func (h *marteHandler) SetClient(client *golspserver.Client) {
 			logger.Printf("PublishDiagnostics error: %v\n", err)
 		}
 	}
+	// The refresh is a request; send it without waiting for the client, and
+	// only to clients that announced support for it.
+	RefreshCodeLensFn = func(ctx context.Context) {
+		if !h.codeLensRefresh {
+			return
+		}
+		go func() {
+			if err := client.CodeLensRefresh(context.Background()); err != nil {
+				logger.Printf("CodeLensRefresh error: %v\n", err)
+			}
+		}()
+	}
 }
 
 // ─── Text Document Sync ───────────────────────────────────────────────────────
@@ -274,6 +304,23 @@ func (h *marteHandler) InlayHint(ctx context.Context, params *golsp.InlayHintPar
 	return result, nil
 }
 
+func (h *marteHandler) CodeLens(ctx context.Context, params *golsp.CodeLensParams) ([]golsp.CodeLens, error) {
+	lenses := HandleCodeLens(CodeLensParams{
+		TextDocument: TextDocumentIdentifier{URI: string(params.TextDocument.URI)},
+	})
+	result := make([]golsp.CodeLens, len(lenses))
+	for i, l := range lenses {
+		result[i] = golsp.CodeLens{
+			Range: golsp.Range{
+				Start: golsp.Position{Line: l.Range.Start.Line, Character: l.Range.Start.Character},
+				End:   golsp.Position{Line: l.Range.End.Line, Character: l.Range.End.Character},
+			},
+			Command: &golsp.Command{Title: l.Command.Title},
+		}
+	}
+	return result, nil
+}
+
 func (h *marteHandler) DocumentSymbol(ctx context.Context, params *golsp.DocumentSymbolParams) ([]golsp.DocumentSymbol, error) {
 	syms := HandleDocumentSymbol(DocumentSymbolParams{
 		TextDocument: TextDocumentIdentifier{URI: string(params.TextDocument.URI)},
@@ -341,11 +388,22 @@ func (h *marteHandler) CodeAction(ctx context.Context, params *golsp.CodeActionP
 			}
 			ga.Edit = &golsp.WorkspaceEdit{Changes: changes}
 		}
+		if a.Command != nil {
+			ga.Command = &golsp.Command{Title: a.Command.Title, Command: a.Command.Command}
+			for _, arg := range a.Command.Arguments {
+				raw, _ := json.Marshal(arg)
+				ga.Command.Arguments = append(ga.Command.Arguments, raw)
+			}
+		}
 		result[i] = ga
 	}
 	return result, nil
 }
 
+func (h *marteHandler) ExecuteCommand(ctx context.Context, params *golsp.ExecuteCommandParams) (any, error) {
+	return HandleExecuteCommand(ExecuteCommandParams{Command: params.Command, Arguments: params.Arguments})
+}
+
 // ─── Call Hierarchy ───────────────────────────────────────────────────────────
 
 func (h *marteHandler) PrepareCallHierarchy(ctx context.Context, params *golsp.CallHierarchyPrepareParams) ([]golsp.CallHierarchyItem, error) {
@@ -403,7 +461,9 @@ var (
 	_ golspserver.DocumentSymbolHandler  = (*marteHandler)(nil)
 	_ golspserver.WorkspaceSymbolHandler = (*marteHandler)(nil)
 	_ golspserver.CodeActionHandler      = (*marteHandler)(nil)
+	_ golspserver.ExecuteCommandHandler  = (*marteHandler)(nil)
 	_ golspserver.CallHierarchyHandler   = (*marteHandler)(nil)
+	_ golspserver.CodeLensHandler        = (*marteHandler)(nil)
 )
 
 // ─── Type conversion helpers ─────────────────────────────────────────────────
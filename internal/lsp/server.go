@@ -70,8 +70,16 @@ type JsonRpcError struct {
 }
 
 type InitializeParams struct {
-	RootURI  string `json:"rootUri"`
-	RootPath string `json:"rootPath"`
+	RootURI               string                `json:"rootUri"`
+	RootPath              string                `json:"rootPath"`
+	InitializationOptions InitializationOptions `json:"initializationOptions"`
+}
+
+// InitializationOptions are the settings a client sends with initialize.
+type InitializationOptions struct {
+	// MARTeVersion selects the release schema of the workspace, as
+	// --marte-version does on the command line.
+	MARTeVersion string `json:"marteVersion"`
 }
 
 type DidOpenTextDocumentParams struct {
@@ -157,6 +165,15 @@ type InlayHint struct {
 	PaddingRight bool     `json:"paddingRight,omitempty"`
 }
 
+type CodeLensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type CodeLens struct {
+	Range   Range    `json:"range"`
+	Command *Command `json:"command,omitempty"`
+}
+
 type TypeDefinitionParams struct {
 	TextDocument TextDocumentIdentifier `json:"textDocument"`
 	Position     Position               `json:"position"`
@@ -240,6 +257,8 @@ type LSPDiagnostic struct {
 	Severity int    `json:"severity"`
 	Message  string `json:"message"`
 	Source   string `json:"source"`
+	Code     string `json:"code,omitempty"`
+	Tags     []int  `json:"tags,omitempty"` // 1: Unnecessary (rendered faded)
 }
 
 type DocumentFormattingParams struct {
@@ -301,6 +320,11 @@ type WorkspaceSymbolParams struct {
 	Query string `json:"query"`
 }
 
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
 type SymbolInformation struct {
 	Name          string     `json:"name"`
 	Kind          SymbolKind `json:"kind"`
@@ -341,6 +365,10 @@ var (
 	lastPublished = make(map[string]string) // URI -> Hash of diagnostics
 )
 
+// RefreshCodeLensFn is set by the go-lsp handler to ask the client to request
+// code lenses again after a validation.
+var RefreshCodeLensFn func(ctx context.Context)
+
 func publishDiagnosticsForFile(ctx context.Context, fileURI string, diags []LSPDiagnostic) {
 	if PublishDiagnosticsFn != nil {
 		PublishDiagnosticsFn(ctx, fileURI, diags)
@@ -357,6 +385,26 @@ func publishDiagnosticsForFile(ctx context.Context, fileURI string, diags []LSPD
 	send(notification)
 }
 
+// selectMARTeVersion returns the release set by the client, or def when the
+// client sets none or names a release that is not bundled.
+func selectMARTeVersion(def, option string) string {
+	if option == "" {
+		return def
+	}
+	if !schema.HasVersion(option) {
+		logger.Printf("Unknown MARTe version %q (bundled: %s)\n", option, strings.Join(schema.Versions(), ", "))
+		return def
+	}
+	return option
+}
+
+// loadSchema loads the schema of the workspace at root and attaches it to
+// the tree of the snapshot.
+func loadSchema(snap *cache.Snapshot, root string) {
+	GlobalSchema = schema.LoadFullSchema(root, schema.WithVersion(snap.View().MARTeVersion()))
+	validator.AttachSchema(snap.Tree(), GlobalSchema)
+}
+
 func triggerValidation(uri string) {
 	if SynchronousValidation {
 		view := GlobalSession.ViewOf(uri)
@@ -442,6 +490,7 @@ func HandleMessage(msg *JsonRpcMessage) {
 					GlobalSession = cache.NewSession("default")
 				}
 				view := GlobalSession.CreateView("main", root)
+				view.SetMARTeVersion(selectMARTeVersion("", params.InitializationOptions.MARTeVersion))
 				snap := view.Snapshot()
 
 				logger.Printf("Scanning workspace: %s\n", root)
@@ -453,7 +502,7 @@ func HandleMessage(msg *JsonRpcMessage) {
 				snap.Tree().ResolveFields(nil)
 				logger.Printf("Resolve done")
 				view.SetSnapshot(snap)
-				GlobalSchema = schema.LoadFullSchema(root)
+				loadSchema(snap, root)
 				logger.Printf("Schema done")
 			}
 		}
@@ -472,6 +521,10 @@ func HandleMessage(msg *JsonRpcMessage) {
 				"typeDefinitionProvider":     true,
 				"codeActionProvider":         true,
 				"callHierarchyProvider":      true,
+				"codeLensProvider":           map[string]any{},
+				"executeCommandProvider": map[string]any{
+					"commands": []string{ExpandCommand},
+				},
 				"completionProvider": map[string]any{
 					"triggerCharacters": []string{"=", " ", "@"},
 				},
@@ -548,6 +601,11 @@ func HandleMessage(msg *JsonRpcMessage) {
 		if err := json.Unmarshal(msg.Params, &params); err == nil {
 			respond(msg.ID, HandleTypeDefinition(params))
 		}
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			respond(msg.ID, HandleCodeLens(params))
+		}
 	case "textDocument/codeAction":
 		var params CodeActionParams
 		if err := json.Unmarshal(msg.Params, &params); err == nil {
@@ -573,6 +631,15 @@ func HandleMessage(msg *JsonRpcMessage) {
 		if err := json.Unmarshal(msg.Params, &params); err == nil {
 			respond(msg.ID, HandleWorkspaceSymbol(params))
 		}
+	case "workspace/executeCommand":
+		var params ExecuteCommandParams
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			res, err := HandleExecuteCommand(params)
+			if err != nil {
+				logger.Printf("executeCommand %s: %v", params.Command, err)
+			}
+			respond(msg.ID, res)
+		}
 	default:
 	}
 }
@@ -602,9 +669,10 @@ func HandleDidOpen(params DidOpenTextDocumentParams) {
 	path := uriToPath(params.TextDocument.URI)
 	newSnap.Documents()[params.TextDocument.URI] = params.TextDocument.Text
 
-	p := parser.NewParser(params.TextDocument.Text)
-	config, _ := p.Parse()
-	newSnap.ParserErrors()[params.TextDocument.URI] = p.Errors()
+	doc := parser.ParseDocument(params.TextDocument.Text)
+	config := doc.Config()
+	newSnap.Parsed()[params.TextDocument.URI] = doc
+	newSnap.ParserErrors()[params.TextDocument.URI] = doc.Errors()
 
 	// 1. Immediately publish parser errors
 	publishImmediateDiagnostics(params.TextDocument.URI, newSnap)
@@ -614,6 +682,8 @@ func HandleDidOpen(params DidOpenTextDocumentParams) {
 	delete(lastPublished, params.TextDocument.URI)
 	diagMu.Unlock()
 
+	// Only the parse is incremental: the file is re-indexed as a whole and
+	// the project resolved again.
 	if config != nil {
 		newSnap.Tree().AddFile(path, config)
 		newSnap.Tree().ResolveReferences(nil)
@@ -652,15 +722,26 @@ func HandleDidChange(params DidChangeTextDocumentParams) {
 
 	newSnap.Documents()[uri] = text
 	path := uriToPath(uri)
-	p := parser.NewParser(text)
-	config, _ := p.Parse()
-	newSnap.ParserErrors()[uri] = p.Errors()
+
+	// Re-parse only the definitions touched by the edit when the previous
+	// parse result is still current.
+	doc, ok := newSnap.Parsed()[uri]
+	if ok && doc.Text() == oldSnap.Documents()[uri] {
+		doc = doc.Update(text)
+	} else {
+		doc = parser.ParseDocument(text)
+	}
+	config := doc.Config()
+	newSnap.Parsed()[uri] = doc
+	newSnap.ParserErrors()[uri] = doc.Errors()
 
 	// Immediately publish parser errors
 	publishImmediateDiagnostics(uri, newSnap)
 
 	// Parser errors are now handled in runValidation to avoid blinking
 
+	// Only the parse is incremental: the file is re-indexed as a whole and
+	// the project resolved again.
 	if config != nil {
 		newSnap.Tree().AddFile(path, config)
 		newSnap.Tree().ResolveReferences(nil)
@@ -704,6 +785,7 @@ func HandleDidClose(params DidCloseTextDocumentParams) {
 	oldSnap := view.Snapshot()
 	newSnap := oldSnap.Clone(context.Background())
 	delete(newSnap.Documents(), uri)
+	delete(newSnap.Parsed(), uri)
 	delete(newSnap.ParserErrors(), uri)
 	view.SetSnapshot(newSnap)
 }
@@ -769,20 +851,34 @@ func HandleFormatting(params DocumentFormattingParams) []TextEdit {
 	formatter.Format(config, &buf)
 	newText := buf.String()
 
-	lines := strings.Count(text, "\n")
-	if len(text) > 0 && !strings.HasSuffix(text, "\n") {
-		lines++
+	// The formatter prints from the AST; only send the parts of its output
+	// that changed so comments and cursor positions on untouched lines are
+	// preserved.
+	edits := []TextEdit{}
+	for _, e := range parser.ComputeEdits(text, newText) {
+		edits = append(edits, TextEdit{Range: toLSPRange(e.Start, e.End), NewText: e.NewText})
 	}
+	return edits
+}
 
-	return []TextEdit{
-		{
-			Range: Range{
-				Start: Position{0, 0},
-				End:   Position{lines + 1, 0},
-			},
-			NewText: newText,
-		},
+func toLSPRange(start, end parser.Position) Range {
+	return Range{
+		Start: Position{Line: start.Line - 1, Character: start.Column - 1},
+		End:   Position{Line: end.Line - 1, Character: end.Column - 1},
+	}
+}
+
+// documentText returns the content of a file, preferring the open editor
+// buffer over the file on disk.
+func documentText(snap *cache.Snapshot, file string) (string, bool) {
+	if text, ok := snap.Documents()["file://"+file]; ok {
+		return text, true
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
 	}
+	return string(data), true
 }
 
 func publishImmediateDiagnostics(uri string, snap *cache.Snapshot) {
@@ -878,7 +974,7 @@ func runValidation(ctx context.Context, uri string, snap *cache.Snapshot) {
 	}
 
 	// Semantic Validation
-	v := validator.NewValidator(snap.Tree(), snap.View().Root(), nil)
+	v := validator.NewValidator(snap.Tree(), snap.View().Root(), nil, schema.WithVersion(snap.View().MARTeVersion()))
 	v.ValidateProject(ctx)
 
 	if ctx.Err() != nil {
@@ -886,6 +982,9 @@ func runValidation(ctx context.Context, uri string, snap *cache.Snapshot) {
 		return
 	}
 
+	snap.SetVariables(v.Variables)
+	setFootprint(snap, v.Footprint())
+
 	for _, d := range v.Diagnostics {
 		severity := 1 // Error
 		levelStr := "ERROR"
@@ -902,6 +1001,7 @@ func runValidation(ctx context.Context, uri string, snap *cache.Snapshot) {
 			Severity: severity,
 			Message:  fmt.Sprintf("%s: %s", levelStr, d.Message),
 			Source:   "mdt",
+			Code:     d.Rule,
 		}
 
 		path := d.File
@@ -910,6 +1010,23 @@ func runValidation(ctx context.Context, uri string, snap *cache.Snapshot) {
 		}
 	}
 
+	// Unselected #switch branches are faded out by the client.
+	for _, r := range v.InactiveRanges {
+		if r.File == "" {
+			continue
+		}
+		fileDiags[r.File] = append(fileDiags[r.File], LSPDiagnostic{
+			Range: Range{
+				Start: Position{Line: r.Start.Line - 1, Character: r.Start.Column - 1},
+				End:   Position{Line: r.End.Line - 1, Character: r.End.Column - 1},
+			},
+			Severity: 4, // Hint
+			Message:  "Inactive #switch branch",
+			Source:   "mdt",
+			Tags:     []int{1},
+		})
+	}
+
 	// 2. Send diagnostics ONLY if they changed
 	diagMu.Lock()
 	defer diagMu.Unlock()
@@ -929,6 +1046,10 @@ func runValidation(ctx context.Context, uri string, snap *cache.Snapshot) {
 		publishDiagnosticsForFile(ctx, fileURI, diags)
 	}
 
+	if RefreshCodeLensFn != nil {
+		RefreshCodeLensFn(ctx)
+	}
+
 	// Notify graph server that the project tree has been updated.
 	if GraphNotifyFn != nil {
 		GraphNotifyFn("reload", "")
@@ -986,9 +1107,9 @@ func HandleHover(params HoverParams) *Hover {
 
 	if res.Node != nil {
 		if res.Node.Target != nil {
-			content = fmt.Sprintf("**Link**: `%s` -> `%s`\n\n%s", res.Node.RealName, res.Node.Target.RealName, formatNodeInfo(tree, res.Node.Target, container))
+			content = fmt.Sprintf("**Link**: `%s` -> `%s`\n\n%s", res.Node.RealName, res.Node.Target.RealName, formatNodeInfo(snap, res.Node.Target, container))
 		} else {
-			content = formatNodeInfo(tree, res.Node, container)
+			content = formatNodeInfo(snap, res.Node, container)
 		}
 	} else if res.Field != nil {
 		content = fmt.Sprintf("**Field**: `%s`", res.Field.Name)
@@ -1016,11 +1137,11 @@ func HandleHover(params HoverParams) *Hover {
 			if resolvedTarget != nil {
 				targetName = resolvedTarget.RealName
 				targetDoc = resolvedTarget.Doc
-				fullInfo = formatNodeInfo(tree, resolvedTarget, container)
+				fullInfo = formatNodeInfo(snap, resolvedTarget, container)
 			} else {
 				targetName = res.Reference.Target.RealName
 				targetDoc = res.Reference.Target.Doc
-				fullInfo = formatNodeInfo(tree, res.Reference.Target, container)
+				fullInfo = formatNodeInfo(snap, res.Reference.Target, container)
 			}
 		} else if res.Reference.TargetVariable != nil {
 			v := res.Reference.TargetVariable
@@ -1087,7 +1208,7 @@ func HandleHover(params HoverParams) *Hover {
 func valueToString(tree *index.ProjectTree, val parser.Value, ctx *index.ProjectNode) string {
 	res := tree.Evaluate(val, ctx)
 	if s, ok := res.(*parser.StringValue); ok && s.Quoted {
-		return fmt.Sprintf("\"%s\"", s.Value)
+		return parser.QuoteString(s.Value)
 	}
 	return tree.ValueToString(res)
 }
@@ -1892,9 +2013,36 @@ func nodeToLocations(node *index.ProjectNode) []Location {
 	return locations
 }
 
+// renameSuggestion extracts the name suggested by a naming rule diagnostic.
+var renameSuggestion = regexp.MustCompile(`; rename to '([^']+)'$`)
+
 func HandleCodeAction(params CodeActionParams) []CodeAction {
 	var actions []CodeAction
 
+	// Match the indentation of the surrounding code when the document is
+	// known, otherwise fall back to the default 4 spaces.
+	var cst *parser.CST
+	var doc *parser.Document
+	if GlobalSession != nil {
+		if view := GlobalSession.ViewOf(params.TextDocument.URI); view != nil {
+			snap := view.Snapshot()
+			if t, ok := snap.Documents()[params.TextDocument.URI]; ok {
+				cst = parser.ParseCST(t)
+			}
+			doc = snap.Parsed()[params.TextDocument.URI]
+		}
+	}
+	indentAt := func(line int, nested bool) string {
+		if cst == nil {
+			return "    "
+		}
+		indent := cst.Indent(line + 1)
+		if nested {
+			indent += "  "
+		}
+		return indent
+	}
+
 	for _, diag := range params.Context.Diagnostics {
 		// 1. Missing Class
 		if strings.Contains(diag.Message, "must contain a 'Class' field") {
@@ -1909,7 +2057,7 @@ func HandleCodeAction(params CodeActionParams) []CodeAction {
 									Start: Position{Line: diag.Range.Start.Line + 1, Character: 0},
 									End:   Position{Line: diag.Range.Start.Line + 1, Character: 0},
 								},
-								NewText: "    Class = ReferenceContainer\n",
+								NewText: indentAt(diag.Range.Start.Line, true) + "Class = ReferenceContainer\n",
 							},
 						},
 					},
@@ -1930,7 +2078,7 @@ func HandleCodeAction(params CodeActionParams) []CodeAction {
 									Start: Position{Line: diag.Range.Start.Line, Character: 0},
 									End:   Position{Line: diag.Range.Start.Line, Character: 0},
 								},
-								NewText: "    //! ignore(implicit)\n",
+								NewText: indentAt(diag.Range.Start.Line, false) + "//! ignore(implicit)\n",
 							},
 						},
 					},
@@ -1951,7 +2099,7 @@ func HandleCodeAction(params CodeActionParams) []CodeAction {
 									Start: Position{Line: diag.Range.Start.Line + 1, Character: 0},
 									End:   Position{Line: diag.Range.Start.Line + 1, Character: 0},
 								},
-								NewText: "    Type = uint32\n",
+								NewText: indentAt(diag.Range.Start.Line, true) + "Type = uint32\n",
 							},
 						},
 					},
@@ -1972,7 +2120,44 @@ func HandleCodeAction(params CodeActionParams) []CodeAction {
 									Start: Position{Line: diag.Range.Start.Line, Character: 0},
 									End:   Position{Line: diag.Range.Start.Line, Character: 0},
 								},
-								NewText: "    //! ignore(unused)\n",
+								NewText: indentAt(diag.Range.Start.Line, false) + "//! ignore(unused)\n",
+							},
+						},
+					},
+				},
+			})
+		}
+
+		// 5. Naming rule -> rename to the suggested name everywhere
+		if m := renameSuggestion.FindStringSubmatch(diag.Message); m != nil {
+			edit := HandleRename(RenameParams{
+				TextDocument: params.TextDocument,
+				Position:     diag.Range.Start,
+				NewName:      m[1],
+			})
+			if edit != nil && len(edit.Changes) > 0 {
+				actions = append(actions, CodeAction{
+					Title: fmt.Sprintf("Rename to '%s'", m[1]),
+					Kind:  "quickfix",
+					Edit:  edit,
+				})
+			}
+		}
+
+		// 6. '$' outside Functions and Data -> define with '+'
+		if strings.Contains(diag.Message, "is defined with '$' outside Functions and Data") {
+			actions = append(actions, CodeAction{
+				Title: "Define with '+'",
+				Kind:  "quickfix",
+				Edit: &WorkspaceEdit{
+					Changes: map[string][]TextEdit{
+						params.TextDocument.URI: {
+							{
+								Range: Range{
+									Start: diag.Range.Start,
+									End:   Position{Line: diag.Range.Start.Line, Character: diag.Range.Start.Character + 1},
+								},
+								NewText: "+",
 							},
 						},
 					},
@@ -1981,9 +2166,88 @@ func HandleCodeAction(params CodeActionParams) []CodeAction {
 		}
 	}
 
+	// Offer the expansion preview on #use, #foreach, #if and #switch lines.
+	if doc != nil {
+		line := params.Range.Start.Line + 1
+		if config := doc.Config(); config != nil {
+			if def := index.ExpandableAt(config, line); def != nil && def.Pos().Line == line {
+				actions = append(actions, CodeAction{
+					Title: "Show expansion",
+					Kind:  "source",
+					Command: &Command{
+						Title:     "Show expansion",
+						Command:   ExpandCommand,
+						Arguments: []any{params.TextDocument.URI, params.Range.Start.Line},
+					},
+				})
+			}
+		}
+	}
+
 	return actions
 }
 
+// ExpandCommand is the workspace/executeCommand that previews the expansion
+// of a block. Its arguments are the document URI and a 0-based line.
+const ExpandCommand = "mdt.expand"
+
+// ExpandResult is the read-only virtual document showing an expansion.
+type ExpandResult struct {
+	URI     string `json:"uri"`
+	Content string `json:"content"`
+}
+
+// HandleExecuteCommand runs the commands listed in the server capabilities.
+func HandleExecuteCommand(params ExecuteCommandParams) (any, error) {
+	switch params.Command {
+	case ExpandCommand:
+		var uri string
+		var line int
+		if len(params.Arguments) != 2 ||
+			json.Unmarshal(params.Arguments[0], &uri) != nil ||
+			json.Unmarshal(params.Arguments[1], &line) != nil {
+			return nil, fmt.Errorf("%s expects a document URI and a line", ExpandCommand)
+		}
+		return HandleExpand(uri, line)
+	}
+	return nil, fmt.Errorf("unknown command: %s", params.Command)
+}
+
+// HandleExpand expands the block at the 0-based line of a document, or the
+// whole document when line is negative.
+func HandleExpand(uri string, line int) (*ExpandResult, error) {
+	if GlobalSession == nil {
+		return nil, fmt.Errorf("no workspace")
+	}
+	view := GlobalSession.ViewOf(uri)
+	if view == nil {
+		return nil, fmt.Errorf("unknown document: %s", uri)
+	}
+	snap := view.Snapshot()
+	doc, ok := snap.Parsed()[uri]
+	if !ok {
+		return nil, fmt.Errorf("unknown document: %s", uri)
+	}
+	config := doc.Config()
+	if config == nil {
+		return nil, fmt.Errorf("cannot parse %s", uri)
+	}
+	vars := snap.Variables()
+	if vars == nil {
+		return nil, fmt.Errorf("%s has not been validated yet", uri)
+	}
+
+	path := uriToPath(uri)
+	exps, err := snap.Tree().Expand(config, path, line+1, vars)
+	if err != nil {
+		return nil, err
+	}
+	return &ExpandResult{
+		URI:     fmt.Sprintf("mdt-expand://%s?line=%d", path, line+1),
+		Content: index.FormatExpansions(exps),
+	}, nil
+}
+
 func HandlePrepareCallHierarchy(params CallHierarchyPrepareParams) []CallHierarchyItem {
 	view := GlobalSession.ViewOf(params.TextDocument.URI)
 	if view == nil {
@@ -2429,7 +2693,8 @@ func getEvaluatedMetadata(tree *index.ProjectTree, node *index.ProjectNode, key
 	return node.Metadata[key]
 }
 
-func formatNodeInfo(tree *index.ProjectTree, node *index.ProjectNode, container *index.ProjectNode) string {
+func formatNodeInfo(snap *cache.Snapshot, node *index.ProjectNode, container *index.ProjectNode) string {
+	tree := snap.Tree()
 	info := ""
 	class := getEvaluatedMetadata(tree, node, "Class", container)
 	if class != "" {
@@ -2468,7 +2733,19 @@ func formatNodeInfo(tree *index.ProjectTree, node *index.ProjectNode, container
 		} else {
 			sigInfo += fmt.Sprintf("**Size**: %s ", desc)
 		}
+		if r := index.TypeRange(typ); r != "" {
+			sigInfo += fmt.Sprintf("**Range**: `%s` ", r)
+		}
+		if t := tree.LookupType(typ); t != nil {
+			sigInfo += formatTypeInfo(tree, t)
+		}
 		info += sigInfo
+	} else if class == index.StructureClass {
+		if t := tree.LookupType(node.Name); t != nil {
+			info += formatTypeInfo(tree, t)
+		}
+	} else if u, ok := footprintUsage(snap, node); ok {
+		info += fmt.Sprintf("\n**Memory**: %s", formatMemoryUsage(u))
 	}
 
 	if node.Doc != "" {
@@ -2694,18 +2971,24 @@ func HandleRename(params RenameParams) *WorkspaceEdit {
 				if strings.HasPrefix(vi.Def.TypeExpr, "loop ") || strings.HasPrefix(vi.Def.TypeExpr, "template parameter:") {
 					continue
 				}
-				macro := "#var "
-				if vi.Def.IsConst {
-					macro = "#let "
+				// Position points to the '#var'/'#let' keyword; the name is
+				// the next token.
+				text, ok := documentText(snap, vi.File)
+				if !ok {
+					continue
 				}
-				// Position.Column is 1-indexed, pointing to '#' of the macro keyword.
-				// The variable name starts after the macro (e.g. "#var ") = len(macro) chars later.
-				nameColLSP := vi.Def.Position.Column - 1 + len(macro)
-				rng := Range{
-					Start: Position{Line: vi.Def.Position.Line - 1, Character: nameColLSP},
-					End:   Position{Line: vi.Def.Position.Line - 1, Character: nameColLSP + len(varName)},
+				cst := parser.ParseCST(text)
+				kw := cst.TokenAt(vi.Def.Position)
+				if kw == nil {
+					continue
+				}
+				nameTok := cst.Next(kw)
+				if nameTok == nil || nameTok.Value != varName {
+					continue
 				}
-				addEdit(vi.File, rng, params.NewName)
+				end := nameTok.Position
+				end.Column += len(nameTok.Value)
+				addEdit(vi.File, toLSPRange(nameTok.Position, end), params.NewName)
 			}
 		})
 
@@ -2963,20 +3246,29 @@ func valueToInt(val parser.Value) int64 {
 	return 0
 }
 
-func getTypeSize(typeName string) int64 {
-	switch typeName {
-	case "uint8", "int8", "char8", "bool":
-		return 1
-	case "uint16", "int16":
-		return 2
-	case "uint32", "int32", "float32":
-		return 4
-	case "uint64", "int64", "float64":
-		return 8
-	case "string":
-		return 0 // Dynamic
+// formatTypeInfo describes a user-defined type for hovers: the labels of an
+// enum or the flattened layout of a struct.
+func formatTypeInfo(tree *index.ProjectTree, t *index.TypeDef) string {
+	if t.IsEnum() {
+		return fmt.Sprintf("\n\n**Enum** `%s`: `%s` {%s}", t.Name, t.Base, strings.Join(t.Values, ", "))
 	}
-	return 0
+	info := fmt.Sprintf("\n\n**Struct** `%s`", t.Name)
+	if size := tree.TypeSize(t.Name); size > 0 {
+		info += fmt.Sprintf(" (%d bytes)", size)
+	}
+	info += "\n\n| Offset | Member | Type | Size |\n|---|---|---|---|"
+	for _, e := range tree.TypeLayout(t.Name) {
+		typ := e.Type
+		if e.NumberOfElements > 1 {
+			typ = fmt.Sprintf("%s[%d]", e.Type, e.NumberOfElements)
+		}
+		size := "?"
+		if e.Size > 0 {
+			size = fmt.Sprintf("%d", e.Size)
+		}
+		info += fmt.Sprintf("\n| %d | `%s` | `%s` | %s |", e.Offset, e.Path, typ, size)
+	}
+	return info
 }
 
 func calculateSignalElements(tree *index.ProjectTree, node *index.ProjectNode) (int64, int64, string) {
@@ -2985,7 +3277,10 @@ func calculateSignalElements(tree *index.ProjectTree, node *index.ProjectNode) (
 	if typ == "" && node.Target != nil {
 		typ = getEvaluatedMetadata(tree, node.Target, "Type", nil)
 	}
-	typeSize := getTypeSize(typ)
+	typeSize := tree.TypeSize(typ)
+	if typeSize < 0 {
+		typeSize = 0 // Dynamic
+	}
 
 	// Check Modifiers
 	rangesVal := getEvaluatedField(tree, node, "Ranges")
@@ -3055,6 +3350,88 @@ func isComplexValue(val parser.Value) bool {
 	return false
 }
 
+// footprints holds, for each view, the memory footprint computed by the
+// validation of its latest validated snapshot. It is kept here rather than
+// in the snapshot so that the cache does not depend on the validator.
+var footprints = struct {
+	sync.Mutex
+	byView map[*cache.View]snapshotFootprint
+}{byView: make(map[*cache.View]snapshotFootprint)}
+
+type snapshotFootprint struct {
+	snap *cache.Snapshot
+	fp   *validator.Footprint
+}
+
+// setFootprint records the memory footprint computed by validating snap.
+func setFootprint(snap *cache.Snapshot, fp *validator.Footprint) {
+	footprints.Lock()
+	defer footprints.Unlock()
+	footprints.byView[snap.View()] = snapshotFootprint{snap: snap, fp: fp}
+}
+
+// footprintOf returns the memory footprint of the tree of snap, or nil until
+// snap has been validated.
+func footprintOf(snap *cache.Snapshot) *validator.Footprint {
+	footprints.Lock()
+	defer footprints.Unlock()
+	if e := footprints.byView[snap.View()]; e.snap == snap {
+		return e.fp
+	}
+	return nil
+}
+
+// footprintUsage returns the memory computed for node by the validation of
+// the snapshot.
+func footprintUsage(snap *cache.Snapshot, node *index.ProjectNode) (validator.MemoryUsage, bool) {
+	fp := footprintOf(snap)
+	if fp == nil {
+		return validator.MemoryUsage{}, false
+	}
+	return fp.Usage(node)
+}
+
+func formatMemoryUsage(u validator.MemoryUsage) string {
+	switch {
+	case u.Kind == "DataSource" && u.Buffers > 1:
+		return fmt.Sprintf("%d bytes (%d signals x %d buffers)", u.Bytes, u.Signals, u.Buffers)
+	case u.Kind == "State":
+		return fmt.Sprintf("%d bytes (GAMs and DataSources)", u.Bytes)
+	}
+	return fmt.Sprintf("%d bytes (%d signals)", u.Bytes, u.Signals)
+}
+
+// HandleCodeLens shows the memory footprint above every DataSource, GAM,
+// thread and state defined in the document.
+func HandleCodeLens(params CodeLensParams) []CodeLens {
+	view := GlobalSession.ViewOf(params.TextDocument.URI)
+	if view == nil {
+		return nil
+	}
+	fp := footprintOf(view.Snapshot())
+	if fp == nil {
+		return nil
+	}
+	path := uriToPath(params.TextDocument.URI)
+	var lenses []CodeLens
+	for _, list := range [][]validator.MemoryUsage{fp.DataSources, fp.GAMs, fp.Threads, fp.States} {
+		for _, u := range list {
+			for _, frag := range u.Node.Fragments {
+				if frag.File != path || !frag.IsObject || frag.ObjectPos.Line == 0 {
+					continue
+				}
+				pos := Position{Line: frag.ObjectPos.Line - 1, Character: frag.ObjectPos.Column - 1}
+				lenses = append(lenses, CodeLens{
+					Range:   Range{Start: pos, End: pos},
+					Command: &Command{Title: "Memory: " + formatMemoryUsage(u)},
+				})
+				break
+			}
+		}
+	}
+	return lenses
+}
+
 func HandleInlayHint(params InlayHintParams) []InlayHint {
 	view := GlobalSession.ViewOf(params.TextDocument.URI)
 	if view == nil {
@@ -3219,6 +3596,16 @@ func HandleInlayHint(params InlayHintParams) []InlayHint {
 							Kind:     2,
 						})
 					}
+				} else if bsw, ok := def.(*parser.SwitchBlock); ok {
+					res := valueToString(tree, bsw.Subject, node)
+					if res != "" {
+						end := bsw.Subject.End()
+						addHint(InlayHint{
+							Position: Position{Line: end.Line - 1, Character: end.Column - 1},
+							Label:    " => " + res,
+							Kind:     2,
+						})
+					}
 				} else if bfor, ok := def.(*parser.ForeachBlock); ok {
 					res := valueToString(tree, bfor.Iterable, node)
 					if res != "" {
@@ -3387,6 +3774,23 @@ func HandleDocumentSymbol(params DocumentSymbolParams) []DocumentSymbol {
 					s.Children = append(s.Children, getFromDefs(v.Else)...)
 				}
 				syms = append(syms, s)
+			case *parser.SwitchBlock:
+				s := DocumentSymbol{
+					Name: "#switch",
+					Kind: SymbolKindOperator,
+					Range: Range{
+						Start: Position{Line: v.Position.Line - 1, Character: v.Position.Column},
+						End:   Position{Line: v.EndPosition.Line - 1, Character: v.EndPosition.Column},
+					},
+					SelectionRange: Range{
+						Start: Position{Line: v.Position.Line - 1, Character: v.Position.Column},
+						End:   Position{Line: v.Position.Line - 1, Character: v.Position.Column + 7},
+					},
+				}
+				for _, b := range v.Branches() {
+					s.Children = append(s.Children, getFromDefs(b.Body)...)
+				}
+				syms = append(syms, s)
 			case *parser.ForeachBlock:
 				syms = append(syms, DocumentSymbol{
 					Name: "#foreach",
@@ -3401,8 +3805,22 @@ func HandleDocumentSymbol(params DocumentSymbolParams) []DocumentSymbol {
 					},
 					Children: getFromDefs(v.Body),
 				})
-			case *parser.TemplateInstantiation:
+			case *parser.SlotBlock:
 				syms = append(syms, DocumentSymbol{
+					Name: "#slot " + v.Name,
+					Kind: SymbolKindOperator,
+					Range: Range{
+						Start: Position{Line: v.Position.Line - 1, Character: v.Position.Column},
+						End:   Position{Line: v.EndPosition.Line - 1, Character: v.EndPosition.Column},
+					},
+					SelectionRange: Range{
+						Start: Position{Line: v.Position.Line - 1, Character: v.Position.Column},
+						End:   Position{Line: v.Position.Line - 1, Character: v.Position.Column + 5},
+					},
+					Children: getFromDefs(v.Body),
+				})
+			case *parser.TemplateInstantiation:
+				s := DocumentSymbol{
 					Name:   v.Name,
 					Detail: "instance of " + v.Template,
 					Kind:   SymbolKindModule,
@@ -3414,7 +3832,23 @@ func HandleDocumentSymbol(params DocumentSymbolParams) []DocumentSymbol {
 						Start: Position{Line: v.Position.Line - 1, Character: v.Position.Column},
 						End:   Position{Line: v.Position.Line - 1, Character: v.Position.Column + len(v.Name) + 5},
 					},
-				})
+				}
+				for _, b := range v.Blocks {
+					s.Children = append(s.Children, DocumentSymbol{
+						Name: "#fill " + b.Name,
+						Kind: SymbolKindOperator,
+						Range: Range{
+							Start: Position{Line: b.Position.Line - 1, Character: b.Position.Column},
+							End:   Position{Line: b.EndPosition.Line - 1, Character: b.EndPosition.Column},
+						},
+						SelectionRange: Range{
+							Start: Position{Line: b.Position.Line - 1, Character: b.Position.Column},
+							End:   Position{Line: b.Position.Line - 1, Character: b.Position.Column + 5},
+						},
+						Children: getFromDefs(b.Body),
+					})
+				}
+				syms = append(syms, s)
 			}
 		}
 		return syms
@@ -1,5 +1,11 @@
 package parser
 
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
 type Node interface {
 	Pos() Position
 	End() Position
@@ -58,12 +64,20 @@ type Value interface {
 
 type StringValue struct {
 	Position Position
-	Value    string
+	Value    string // unescaped content
 	Quoted   bool
+	Raw      string // source literal including quotes; empty when synthesized
 }
 
 func (v *StringValue) Pos() Position { return v.Position }
 func (v *StringValue) End() Position {
+	if v.Raw != "" {
+		// Raw and continued strings may span lines.
+		if nl := strings.LastIndex(v.Raw, "\n"); nl != -1 {
+			return Position{Line: v.Position.Line + strings.Count(v.Raw, "\n"), Column: len(v.Raw) - nl}
+		}
+		return Position{Line: v.Position.Line, Column: v.Position.Column + len(v.Raw)}
+	}
 	col := v.Position.Column + len(v.Value)
 	if v.Quoted {
 		col += 2
@@ -132,6 +146,50 @@ func (v *ArrayValue) Pos() Position { return v.Position }
 func (v *ArrayValue) End() Position { return v.EndPosition }
 func (v *ArrayValue) isValue()      {}
 
+// Shape returns the length of each nesting level of an array literal,
+// outermost first, and whether the array is regular (every element at a
+// given level has the same shape). A vector { 1 2 3 } has shape [3] and the
+// matrix { {1 2 3} {4 5 6} } has shape [2 3].
+//
+// Shape should be called on evaluated arrays: unexpanded
+// ConditionalArrayElements are counted as scalar elements.
+func (v *ArrayValue) Shape() ([]int, bool) {
+	shape := []int{len(v.Elements)}
+	regular := true
+	var inner []int
+	for i, e := range v.Elements {
+		var sub []int
+		if arr, ok := e.(*ArrayValue); ok {
+			var subRegular bool
+			sub, subRegular = arr.Shape()
+			regular = regular && subRegular
+		}
+		if i == 0 {
+			inner = sub
+			continue
+		}
+		if !sameShape(inner, sub) {
+			regular = false
+		}
+	}
+	if regular {
+		shape = append(shape, inner...)
+	}
+	return shape, regular
+}
+
+func sameShape(a, b []int) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // ConditionalArrayElements represents a #if/#else/#end block inside an array.
 // It implements Value so it can appear as an element of ArrayValue.Elements.
 // When the containing array is evaluated the active branch is flattened inline.
@@ -239,6 +297,73 @@ func (i *IfBlock) Pos() Position { return i.Position }
 func (i *IfBlock) End() Position { return i.EndPosition }
 func (i *IfBlock) isDefinition() {}
 
+// SwitchBlock selects one list of definitions by comparing Subject with the
+// values of each #case; Default is used when no case matches:
+//
+//	#switch @Target
+//	#case "sim"
+//	  …
+//	#case "hw", "hil"
+//	  …
+//	#default
+//	  …
+//	#end
+type SwitchBlock struct {
+	Position        Position
+	EndPosition     Position
+	Subject         Value
+	Cases           []SwitchCase
+	Default         []Definition
+	DefaultPosition *Position // nil when there is no #default
+}
+
+type SwitchCase struct {
+	Position Position
+	Values   []Value
+	Body     []Definition
+}
+
+func (s *SwitchBlock) Pos() Position { return s.Position }
+func (s *SwitchBlock) End() Position { return s.EndPosition }
+func (s *SwitchBlock) isDefinition() {}
+
+// SwitchBranch is a #case or #default of a SwitchBlock. ID is "case<N>" or
+// "default"; End is the position of the following #case, #default or #end.
+type SwitchBranch struct {
+	ID       string
+	Position Position
+	End      Position
+	Values   []Value // empty for #default
+	Body     []Definition
+}
+
+// Branches returns the branches of s in source order.
+func (s *SwitchBlock) Branches() []SwitchBranch {
+	var branches []SwitchBranch
+	for i, c := range s.Cases {
+		branches = append(branches, SwitchBranch{
+			ID:       "case" + strconv.Itoa(i),
+			Position: c.Position,
+			Values:   c.Values,
+			Body:     c.Body,
+		})
+	}
+	if s.DefaultPosition != nil {
+		branches = append(branches, SwitchBranch{ID: "default", Position: *s.DefaultPosition, Body: s.Default})
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return before(branches[i].Position, branches[j].Position)
+	})
+	for i := range branches {
+		if i+1 < len(branches) {
+			branches[i].End = branches[i+1].Position
+		} else {
+			branches[i].End = s.EndPosition
+		}
+	}
+	return branches
+}
+
 type ForeachBlock struct {
 	Position    Position
 	EndPosition Position
@@ -253,11 +378,13 @@ func (f *ForeachBlock) End() Position { return f.EndPosition }
 func (f *ForeachBlock) isDefinition() {}
 
 type TemplateDefinition struct {
-	Position    Position
-	EndPosition Position
-	Name        string
-	Parameters  []TemplateParameter
-	Body        []Definition
+	Position     Position
+	EndPosition  Position
+	Name         string
+	Parameters   []TemplateParameter
+	Body         []Definition
+	Base         string // template named after 'extends', if any
+	BasePosition Position
 }
 
 type TemplateParameter struct {
@@ -276,17 +403,56 @@ type TemplateInstantiation struct {
 	Name        string // Name of the instance
 	Template    string // Name of the template
 	Arguments   []TemplateArgument
+	Blocks      []TemplateBlock // #fill blocks
 }
 
 type TemplateArgument struct {
-	Name  string
-	Value Value
+	Position Position
+	Name     string
+	Value    Value
+}
+
+// TemplateBlock is a '#fill NAME … #end' block argument of a #use. Its
+// definitions replace the template's '#slot NAME' and are evaluated in the
+// scope of the #use.
+type TemplateBlock struct {
+	Position    Position
+	EndPosition Position
+	Name        string
+	Body        []Definition
 }
 
 func (t *TemplateInstantiation) Pos() Position { return t.Position }
 func (t *TemplateInstantiation) End() Position { return t.EndPosition }
 func (t *TemplateInstantiation) isDefinition() {}
 
+// SlotBlock is a '#slot NAME … #end' placeholder in a template body. Body is
+// the default content, used when the #use has no matching #fill and no
+// derived template overrides the slot.
+type SlotBlock struct {
+	Position    Position
+	EndPosition Position
+	Name        string
+	Body        []Definition
+}
+
+func (s *SlotBlock) Pos() Position { return s.Position }
+func (s *SlotBlock) End() Position { return s.EndPosition }
+func (s *SlotBlock) isDefinition() {}
+
+// ErrorNode stands in for a definition that failed to parse. The parser
+// skips ahead to the next definition boundary and records the skipped range
+// so that the rest of the file can still be indexed.
+type ErrorNode struct {
+	Position    Position
+	EndPosition Position
+	Err         *Error // the syntax error that made the parser skip
+}
+
+func (e *ErrorNode) Pos() Position { return e.Position }
+func (e *ErrorNode) End() Position { return e.EndPosition }
+func (e *ErrorNode) isDefinition() {}
+
 // SignalShorthand is syntactic sugar for a signal entry inside
 // InputSignals or OutputSignals blocks:
 //
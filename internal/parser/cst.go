@@ -0,0 +1,382 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// CST is a lossless concrete syntax tree. Unlike the AST it keeps every byte
+// of the source: whitespace and comments are attached to the following token
+// as leading trivia, so String reproduces the input exactly.
+//
+// The tree only records the coarse structure needed for source edits:
+// definitions (one node per field, object, signal shorthand or directive
+// line such as #if or #end) and '{ … }' blocks. Semantic analysis keeps using
+// the AST.
+type CST struct {
+	Root   *CSTNode
+	input  string
+	tokens []*CSTToken
+	lines  []int // byte offset of the start of each line
+}
+
+type CSTKind int
+
+const (
+	CSTFile CSTKind = iota
+	CSTDefinition
+	CSTBlock
+)
+
+// CSTElement is either a *CSTNode or a *CSTToken.
+type CSTElement interface {
+	cstElement()
+}
+
+type CSTNode struct {
+	Kind     CSTKind
+	Parent   *CSTNode
+	Children []CSTElement
+}
+
+func (n *CSTNode) cstElement() {}
+
+// FirstToken returns the first token of the node, or nil if it is empty.
+func (n *CSTNode) FirstToken() *CSTToken {
+	for _, c := range n.Children {
+		switch e := c.(type) {
+		case *CSTToken:
+			return e
+		case *CSTNode:
+			if t := e.FirstToken(); t != nil {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// Trivia is source text that carries no syntax: whitespace and comments.
+type Trivia struct {
+	Type     TokenType // TokenWhitespace, TokenComment, TokenDocstring or TokenPragma
+	Text     string
+	Position Position
+	Offset   int
+}
+
+type CSTToken struct {
+	Token
+	Leading []Trivia
+	Parent  *CSTNode
+	index   int
+}
+
+func (t *CSTToken) cstElement() {}
+
+// LeadingOffset returns the offset where the token's leading trivia starts.
+func (t *CSTToken) LeadingOffset() int {
+	if len(t.Leading) > 0 {
+		return t.Leading[0].Offset
+	}
+	return t.Offset
+}
+
+// EndOffset returns the offset just past the token.
+func (t *CSTToken) EndOffset() int {
+	return t.Offset + len(t.Value)
+}
+
+// ParseCST builds the concrete syntax tree of input. It never fails:
+// malformed input yields TokenError leaves.
+func ParseCST(input string) *CST {
+	c := &CST{input: input, lines: lineOffsets(input)}
+
+	lex := NewLexer(input)
+	var pending []Trivia
+	prevEnd := 0
+	for {
+		t := lex.NextToken()
+		if t.Offset > prevEnd {
+			pending = append(pending, Trivia{
+				Type:     TokenWhitespace,
+				Text:     input[prevEnd:t.Offset],
+				Position: c.PositionOf(prevEnd),
+				Offset:   prevEnd,
+			})
+		}
+		prevEnd = t.Offset + len(t.Value)
+
+		switch t.Type {
+		case TokenComment, TokenDocstring, TokenPragma:
+			pending = append(pending, Trivia{Type: t.Type, Text: t.Value, Position: t.Position, Offset: t.Offset})
+			continue
+		}
+
+		c.tokens = append(c.tokens, &CSTToken{Token: t, Leading: pending, index: len(c.tokens)})
+		pending = nil
+		if t.Type == TokenEOF {
+			break
+		}
+	}
+
+	c.buildTree()
+	return c
+}
+
+func (c *CST) buildTree() {
+	c.Root = &CSTNode{Kind: CSTFile}
+	stack := []*CSTNode{c.Root}
+	open := map[*CSTNode]*CSTNode{} // container -> definition being built
+
+	add := func(parent *CSTNode, e CSTElement) {
+		parent.Children = append(parent.Children, e)
+		switch x := e.(type) {
+		case *CSTToken:
+			x.Parent = parent
+		case *CSTNode:
+			x.Parent = parent
+		}
+	}
+
+	header := false // inside a '#var'/'#let' declaration, before its '='
+	for i, t := range c.tokens {
+		container := stack[len(stack)-1]
+
+		if t.Type == TokenRBrace && len(stack) > 1 {
+			add(container, t)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if t.Type == TokenEOF {
+			add(c.Root, t)
+			break
+		}
+
+		if !header && c.startsDefinition(i) {
+			def := &CSTNode{Kind: CSTDefinition}
+			add(container, def)
+			open[container] = def
+			header = t.Type == TokenVar || t.Type == TokenLet
+		} else if t.Type == TokenEqual {
+			header = false
+		}
+		target := container
+		if def := open[container]; def != nil {
+			target = def
+		}
+
+		if t.Type == TokenLBrace {
+			block := &CSTNode{Kind: CSTBlock}
+			add(target, block)
+			add(block, t)
+			stack = append(stack, block)
+			continue
+		}
+		add(target, t)
+	}
+}
+
+func (c *CST) startsDefinition(i int) bool {
+	t := c.tokens[i]
+	switch t.Type {
+	case TokenObjectIdentifier, TokenLet, TokenVar, TokenIf, TokenElse, TokenEnd,
+		TokenSwitch, TokenCase, TokenDefault, TokenForeach, TokenTemplate, TokenUse, TokenSlot, TokenFill, TokenPackage:
+		return true
+	case TokenIdentifier:
+		if strings.Contains(t.Value, "::") {
+			return true
+		}
+		return i+1 < len(c.tokens) && c.tokens[i+1].Type == TokenEqual
+	}
+	return false
+}
+
+// String returns the source text of the tree.
+func (c *CST) String() string {
+	var sb strings.Builder
+	for _, t := range c.tokens {
+		for _, tr := range t.Leading {
+			sb.WriteString(tr.Text)
+		}
+		sb.WriteString(t.Value)
+	}
+	return sb.String()
+}
+
+// Tokens returns all tokens in source order, ending with TokenEOF.
+func (c *CST) Tokens() []*CSTToken {
+	return c.tokens
+}
+
+// Next returns the token following t, or nil at the end.
+func (c *CST) Next(t *CSTToken) *CSTToken {
+	if t.index+1 < len(c.tokens) {
+		return c.tokens[t.index+1]
+	}
+	return nil
+}
+
+// TokenAt returns the token covering pos, or nil if pos is in trivia.
+func (c *CST) TokenAt(pos Position) *CSTToken {
+	off := c.OffsetOf(pos)
+	i := sort.Search(len(c.tokens), func(i int) bool { return c.tokens[i].EndOffset() > off })
+	if i < len(c.tokens) && c.tokens[i].Offset <= off && c.tokens[i].Type != TokenEOF {
+		return c.tokens[i]
+	}
+	return nil
+}
+
+// OffsetOf converts a 1-based position to a byte offset.
+func (c *CST) OffsetOf(pos Position) int {
+	return offsetOf(c.lines, len(c.input), pos)
+}
+
+// PositionOf converts a byte offset to a 1-based position.
+func (c *CST) PositionOf(off int) Position {
+	return positionOf(c.lines, off)
+}
+
+// Indent returns the leading whitespace of a 1-based line.
+func (c *CST) Indent(line int) string {
+	if line < 1 || line > len(c.lines) {
+		return ""
+	}
+	start := c.lines[line-1]
+	end := start
+	for end < len(c.input) && (c.input[end] == ' ' || c.input[end] == '\t') {
+		end++
+	}
+	return c.input[start:end]
+}
+
+// TextEdit replaces the source from Start up to (excluding) End.
+type TextEdit struct {
+	Start   Position
+	End     Position
+	NewText string
+}
+
+// ComputeEdits returns the edits that turn oldText into newText. When both
+// texts have the same tokens (e.g. newText is the output of the formatter,
+// which prints from the AST) only the trivia that differs is replaced,
+// leaving comments and untouched lines alone.
+// Otherwise the changed token range is replaced as a single edit.
+func ComputeEdits(oldText, newText string) []TextEdit {
+	a, b := ParseCST(oldText), ParseCST(newText)
+	at, bt := a.tokens, b.tokens
+
+	edit := func(oldStart, oldEnd, newStart, newEnd int) TextEdit {
+		// Shrink to the part that really differs.
+		for oldStart < oldEnd && newStart < newEnd && oldText[oldStart] == newText[newStart] {
+			oldStart++
+			newStart++
+		}
+		for oldEnd > oldStart && newEnd > newStart && oldText[oldEnd-1] == newText[newEnd-1] {
+			oldEnd--
+			newEnd--
+		}
+		// Do not split multi-byte characters.
+		for oldStart > 0 && newStart > 0 && oldStart < len(oldText) && !utf8.RuneStart(oldText[oldStart]) {
+			oldStart--
+			newStart--
+		}
+		for oldEnd < len(oldText) && newEnd < len(newText) && !utf8.RuneStart(oldText[oldEnd]) {
+			oldEnd++
+			newEnd++
+		}
+		return TextEdit{Start: a.PositionOf(oldStart), End: a.PositionOf(oldEnd), NewText: newText[newStart:newEnd]}
+	}
+
+	if sameTokens(at, bt) {
+		var edits []TextEdit
+		for i := range at {
+			oldLead, newLead := at[i].LeadingOffset(), bt[i].LeadingOffset()
+			if oldText[oldLead:at[i].Offset] == newText[newLead:bt[i].Offset] {
+				continue
+			}
+			// With the same comments on both sides, only touch the
+			// whitespace around them.
+			if ow, nw, ok := whitespaceRuns(at[i], bt[i]); ok {
+				for j := range ow {
+					if oldText[ow[j][0]:ow[j][1]] != newText[nw[j][0]:nw[j][1]] {
+						edits = append(edits, edit(ow[j][0], ow[j][1], nw[j][0], nw[j][1]))
+					}
+				}
+				continue
+			}
+			edits = append(edits, edit(oldLead, at[i].Offset, newLead, bt[i].Offset))
+		}
+		return edits
+	}
+
+	equal := func(x, y *CSTToken) bool {
+		return x.Type == y.Type && x.Value == y.Value &&
+			oldText[x.LeadingOffset():x.Offset] == newText[y.LeadingOffset():y.Offset]
+	}
+	prefix := 0
+	for prefix < len(at) && prefix < len(bt) && equal(at[prefix], bt[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(at)-prefix && suffix < len(bt)-prefix && equal(at[len(at)-1-suffix], bt[len(bt)-1-suffix]) {
+		suffix++
+	}
+	startOf := func(toks []*CSTToken, i, size int) int {
+		if i < len(toks) {
+			return toks[i].LeadingOffset()
+		}
+		return size
+	}
+	oldStart := startOf(at, prefix, len(oldText))
+	newStart := startOf(bt, prefix, len(newText))
+	oldEnd := startOf(at, len(at)-suffix, len(oldText))
+	newEnd := startOf(bt, len(bt)-suffix, len(newText))
+	if oldStart == oldEnd && newStart == newEnd {
+		return nil
+	}
+	return []TextEdit{edit(oldStart, oldEnd, newStart, newEnd)}
+}
+
+func sameTokens(a, b []*CSTToken) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// whitespaceRuns splits the leading trivia of a and b into the whitespace
+// runs between comments, including empty runs, so that they can be compared
+// pairwise. It reports false when the comments differ.
+func whitespaceRuns(a, b *CSTToken) (ar, br [][2]int, ok bool) {
+	runs := func(t *CSTToken) ([][2]int, []string) {
+		var ws [][2]int
+		var comments []string
+		start := t.LeadingOffset()
+		for _, tr := range t.Leading {
+			if tr.Type == TokenWhitespace {
+				continue
+			}
+			ws = append(ws, [2]int{start, tr.Offset})
+			comments = append(comments, tr.Text)
+			start = tr.Offset + len(tr.Text)
+		}
+		return append(ws, [2]int{start, t.Offset}), comments
+	}
+	ar, ac := runs(a)
+	br, bc := runs(b)
+	if len(ac) != len(bc) {
+		return nil, nil, false
+	}
+	for i := range ac {
+		if ac[i] != bc[i] {
+			return nil, nil, false
+		}
+	}
+	return ar, br, true
+}
@@ -0,0 +1,604 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// Document is a parsed source text that can be updated incrementally.
+//
+// Update re-parses only the definitions touched by an edit: it descends into
+// the innermost object block that contains the change and re-parses the
+// affected children of that block. Everything else is reused, with positions
+// shifted when the edit moved them. Documents are immutable; Update returns a
+// new one and never modifies nodes reachable from the old one.
+type Document struct {
+	text        string
+	config      *Configuration
+	errors      []error
+	lines       []int
+	recovered   bool // parsed with indentation recovery
+	incremental bool
+}
+
+// ParseDocument parses text from scratch.
+func ParseDocument(text string) *Document {
+	p := NewParser(text)
+	config, _ := p.Parse()
+	return &Document{
+		text:      text,
+		config:    config,
+		errors:    p.Errors(),
+		lines:     lineOffsets(text),
+		recovered: p.indentRecovery,
+	}
+}
+
+func (d *Document) Text() string           { return d.text }
+func (d *Document) Config() *Configuration { return d.config }
+func (d *Document) Errors() []error        { return d.errors }
+
+// Incremental reports whether the document was produced by a partial
+// re-parse.
+func (d *Document) Incremental() bool { return d.incremental }
+
+// Update returns the document for newText. The result is the same as
+// ParseDocument(newText); only the work done differs.
+func (d *Document) Update(newText string) *Document {
+	if newText == d.text {
+		return d
+	}
+	if nd := d.reparse(newText); nd != nil {
+		return nd
+	}
+	return ParseDocument(newText)
+}
+
+// reparse attempts a partial re-parse. It returns nil when the edit cannot
+// be confined to a run of definitions, in which case the caller falls back
+// to a full parse.
+func (d *Document) reparse(newText string) *Document {
+	if d.recovered {
+		// Indentation recovery depends on the whole file.
+		return nil
+	}
+	old := d.text
+
+	// Changed range: old[start:oldEnd] became newText[start:newEnd].
+	start := 0
+	for start < len(old) && start < len(newText) && old[start] == newText[start] {
+		start++
+	}
+	oldEnd, newEnd := len(old), len(newText)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == newText[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	// Descend into the innermost object block around the change.
+	type step struct {
+		defs  []Definition
+		index int
+	}
+	var path []step
+	defs := d.config.Definitions
+	lo, hi := 0, len(old)
+	for {
+		i := d.enclosingObject(defs, start, oldEnd)
+		if i < 0 {
+			break
+		}
+		path = append(path, step{defs, i})
+		sub := &defs[i].(*ObjectNode).Subnode
+		lo, hi = d.offset(sub.Position)+1, d.offset(sub.EndPosition)
+		defs = sub.Definitions
+	}
+
+	// Re-parse from one boundary before the change, so that a definition
+	// whose value may now continue into the change is included, up to the
+	// first boundary whose preceding line break is untouched.
+	var boundaries []int
+	for i, def := range defs {
+		if d.isBoundary(def) {
+			boundaries = append(boundaries, i)
+		}
+	}
+	k := sort.Search(len(boundaries), func(k int) bool {
+		return d.offset(defs[boundaries[k]].Pos()) > start
+	}) - 2
+	ia, rs := 0, lo
+	if k >= 0 {
+		ia = boundaries[k]
+		rs = d.offset(defs[ia].Pos())
+	}
+	ib, re := len(defs), hi
+	for _, i := range boundaries {
+		if i > ia && d.lines[defs[i].Pos().Line-1] > oldEnd {
+			ib, re = i, d.offset(defs[i].Pos())
+			break
+		}
+	}
+	if rs > start || re < oldEnd {
+		return nil
+	}
+
+	delta := newEnd - oldEnd
+	nre := re + delta
+	newLines := d.updateLines(newText, start, oldEnd, newEnd)
+	rsPos := d.position(rs)
+	rePos := d.position(re)
+	nrePos := positionOf(newLines, nre)
+	if pkg := d.config.Package; pkg != nil && d.inRange(pkg.Position, rsPos, rePos, re) {
+		return nil
+	}
+
+	p := newRegionParser(newText[:nre], rs, rsPos, nrePos)
+	region, ok := p.parseRegion()
+	if !ok {
+		return nil
+	}
+
+	s := shifter{
+		from:  rePos,
+		lines: nrePos.Line - rePos.Line,
+		cols:  nrePos.Column - rePos.Column,
+	}
+
+	errs := make([]error, 0, len(d.errors)+len(p.errors))
+	var after []error
+	for _, err := range d.errors {
+		e, ok := err.(*Error)
+		if !ok || (e.Position == rePos && re < len(old)) {
+			return nil
+		}
+		switch {
+		case before(e.Position, rsPos):
+			errs = append(errs, e)
+		case !d.inRange(e.Position, rsPos, rePos, re):
+			after = append(after, &Error{Position: s.pos(e.Position), Message: e.Message})
+		}
+	}
+	errs = append(errs, p.errors...)
+	errs = append(errs, after...)
+
+	// Rebuild the path to the re-parsed block.
+	newDefs := make([]Definition, 0, len(defs)-(ib-ia)+len(region))
+	newDefs = append(newDefs, defs[:ia]...)
+	newDefs = append(newDefs, region...)
+	newDefs = append(newDefs, s.definitions(defs[ib:])...)
+	if len(newDefs) == 0 {
+		newDefs = nil
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		st := path[i]
+		obj := *st.defs[st.index].(*ObjectNode)
+		obj.Subnode.Definitions = newDefs
+		obj.Subnode.EndPosition = s.pos(obj.Subnode.EndPosition)
+
+		newDefs = make([]Definition, 0, len(st.defs))
+		newDefs = append(newDefs, st.defs[:st.index]...)
+		newDefs = append(newDefs, &obj)
+		newDefs = append(newDefs, s.definitions(st.defs[st.index+1:])...)
+	}
+
+	config := &Configuration{Definitions: newDefs, Package: d.config.Package}
+	if pkg := config.Package; pkg != nil && !before(pkg.Position, rePos) {
+		config.Package = &Package{Position: s.pos(pkg.Position), URI: pkg.URI}
+	}
+	for _, c := range d.config.Comments {
+		if before(c.Position, rsPos) {
+			config.Comments = append(config.Comments, c)
+		}
+	}
+	config.Comments = append(config.Comments, p.comments...)
+	for _, c := range d.config.Comments {
+		if !before(c.Position, rsPos) && !d.inRange(c.Position, rsPos, rePos, re) {
+			c.Position = s.pos(c.Position)
+			config.Comments = append(config.Comments, c)
+		}
+	}
+	for _, pr := range d.config.Pragmas {
+		if before(pr.Position, rsPos) {
+			config.Pragmas = append(config.Pragmas, pr)
+		}
+	}
+	config.Pragmas = append(config.Pragmas, p.pragmas...)
+	for _, pr := range d.config.Pragmas {
+		if !before(pr.Position, rsPos) && !d.inRange(pr.Position, rsPos, rePos, re) {
+			pr.Position = s.pos(pr.Position)
+			config.Pragmas = append(config.Pragmas, pr)
+		}
+	}
+
+	return &Document{
+		text:        newText,
+		config:      config,
+		errors:      errs,
+		lines:       newLines,
+		incremental: true,
+	}
+}
+
+// updateLines returns the line table of newText, reusing the entries
+// outside the changed range.
+func (d *Document) updateLines(newText string, start, oldEnd, newEnd int) []int {
+	head := sort.SearchInts(d.lines, start+1) // lines starting at or before start
+	tail := sort.SearchInts(d.lines, oldEnd+1)
+	lines := make([]int, head, len(d.lines)+strings.Count(newText[start:newEnd], "\n"))
+	copy(lines, d.lines[:head])
+	for i := start; i < newEnd; i++ {
+		if newText[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	delta := newEnd - oldEnd
+	for _, l := range d.lines[tail:] {
+		lines = append(lines, l+delta)
+	}
+	return lines
+}
+
+// enclosingObject returns the index of the object whose braces strictly
+// contain old[start:end], or -1.
+func (d *Document) enclosingObject(defs []Definition, start, end int) int {
+	for i, def := range defs {
+		obj, ok := def.(*ObjectNode)
+		if !ok {
+			continue
+		}
+		open, close := d.offset(obj.Subnode.Position), d.offset(obj.Subnode.EndPosition)
+		if open < start && end <= close && close < len(d.text) && d.text[close] == '}' && d.text[open] == '{' {
+			// 'Name = {' is only a block because of its first tokens
+			// (see isSubnodeLookahead); they must not be part of the edit.
+			if c := d.text[d.offset(obj.Position)]; c == '+' || c == '$' {
+				return i
+			}
+			if defs := obj.Subnode.Definitions; len(defs) > 0 && d.offset(defs[0].End()) < start {
+				return i
+			}
+			return -1
+		}
+		if open >= start {
+			break
+		}
+	}
+	return -1
+}
+
+// isBoundary reports whether a region may start at def: it must begin its
+// own line with a token that cannot continue a preceding value.
+func (d *Document) isBoundary(def Definition) bool {
+	if _, ok := def.(*ErrorNode); ok {
+		return false
+	}
+	pos := def.Pos()
+	if pos.Line < 1 || pos.Line > len(d.lines) {
+		return false
+	}
+	lineStart := d.lines[pos.Line-1]
+	off := lineStart + pos.Column - 1
+	if off >= len(d.text) {
+		return false
+	}
+	for _, c := range []byte(d.text[lineStart:off]) {
+		if c != ' ' && c != '\t' && c != '\r' {
+			return false
+		}
+	}
+	c := d.text[off]
+	return c == '+' || c == '$' || c == '#' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// inRange reports whether pos lies in the old region [from, to). A region
+// that runs to the end of the text also owns positions at the end.
+func (d *Document) inRange(pos, from, to Position, end int) bool {
+	if before(pos, from) {
+		return false
+	}
+	return before(pos, to) || end == len(d.text)
+}
+
+func (d *Document) offset(pos Position) int {
+	return offsetOf(d.lines, len(d.text), pos)
+}
+
+func (d *Document) position(off int) Position {
+	return positionOf(d.lines, off)
+}
+
+func newRegionParser(text string, offset int, pos, limit Position) *Parser {
+	l := NewLexer(text)
+	l.start, l.pos = offset, offset
+	l.line, l.startLine = pos.Line, pos.Line
+	l.lineStart = offset - (pos.Column - 1)
+	l.startColumn = pos.Column
+	return &Parser{input: text, lexer: l, region: true, limit: limit}
+}
+
+// parseRegion parses definitions up to the end of the region. It fails when
+// the region is not self-contained.
+func (p *Parser) parseRegion() ([]Definition, bool) {
+	var defs []Definition
+	for {
+		tok := p.peek()
+		switch tok.Type {
+		case TokenEOF:
+			return defs, p.depth == 0 && !p.unclosed && !p.truncated
+		case TokenPackage, TokenRBrace, TokenElse, TokenEnd, TokenCase, TokenDefault, TokenFill:
+			return nil, false
+		}
+		def, ok := p.parseDefinition()
+		if ok {
+			defs = append(defs, def)
+		} else if p.peek() == tok {
+			p.next()
+		}
+		if p.truncated {
+			return nil, false
+		}
+	}
+}
+
+// shifter moves positions at or after from by the size of an edit.
+type shifter struct {
+	from  Position
+	lines int
+	cols  int // applies on the line of from only
+}
+
+func (s shifter) pos(p Position) Position {
+	if before(p, s.from) {
+		return p
+	}
+	if p.Line == s.from.Line {
+		p.Column += s.cols
+	}
+	p.Line += s.lines
+	return p
+}
+
+func (s shifter) token(t Token) Token {
+	t.Position = s.pos(t.Position)
+	return t
+}
+
+// unchanged reports whether nothing at or after pos moves.
+func (s shifter) unchanged(pos Position) bool {
+	return s.lines == 0 && (s.cols == 0 || pos.Line > s.from.Line)
+}
+
+func (s shifter) definitions(defs []Definition) []Definition {
+	if defs == nil {
+		return nil
+	}
+	out := make([]Definition, len(defs))
+	for i, def := range defs {
+		out[i] = s.definition(def)
+	}
+	return out
+}
+
+// definition returns def with shifted positions. Nodes are copied, never
+// modified; subtrees that do not move are shared.
+func (s shifter) definition(def Definition) Definition {
+	if def == nil || s.unchanged(def.Pos()) {
+		return def
+	}
+	switch n := def.(type) {
+	case *Field:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.Value = s.value(c.Value)
+		return &c
+	case *ObjectNode:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.Name = s.value(c.Name)
+		c.Subnode = s.subnode(c.Subnode)
+		return &c
+	case *VariableDefinition:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.DefaultValue = s.value(c.DefaultValue)
+		return &c
+	case *IfBlock:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Condition = s.value(c.Condition)
+		c.Then = s.definitions(c.Then)
+		c.Else = s.definitions(c.Else)
+		return &c
+	case *SwitchBlock:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Subject = s.value(c.Subject)
+		c.Cases = nil
+		for _, sc := range n.Cases {
+			sc.Position = s.pos(sc.Position)
+			sc.Values = s.values(sc.Values)
+			sc.Body = s.definitions(sc.Body)
+			c.Cases = append(c.Cases, sc)
+		}
+		c.Default = s.definitions(c.Default)
+		if n.DefaultPosition != nil {
+			pos := s.pos(*n.DefaultPosition)
+			c.DefaultPosition = &pos
+		}
+		return &c
+	case *ForeachBlock:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Iterable = s.value(c.Iterable)
+		c.Body = s.definitions(c.Body)
+		return &c
+	case *TemplateDefinition:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Parameters = nil
+		for _, param := range n.Parameters {
+			param.DefaultValue = s.value(param.DefaultValue)
+			c.Parameters = append(c.Parameters, param)
+		}
+		c.Body = s.definitions(c.Body)
+		if c.Base != "" {
+			c.BasePosition = s.pos(c.BasePosition)
+		}
+		return &c
+	case *TemplateInstantiation:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Arguments = nil
+		for _, arg := range n.Arguments {
+			arg.Position = s.pos(arg.Position)
+			arg.Value = s.value(arg.Value)
+			c.Arguments = append(c.Arguments, arg)
+		}
+		c.Blocks = nil
+		for _, b := range n.Blocks {
+			b.Position = s.pos(b.Position)
+			b.EndPosition = s.pos(b.EndPosition)
+			b.Body = s.definitions(b.Body)
+			c.Blocks = append(c.Blocks, b)
+		}
+		return &c
+	case *SlotBlock:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Body = s.definitions(c.Body)
+		return &c
+	case *SignalShorthand:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.NumElements = s.value(c.NumElements)
+		c.ExtraFields = s.subnode(c.ExtraFields)
+		return &c
+	case *ErrorNode:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		if c.Err != nil {
+			c.Err = &Error{Position: s.pos(c.Err.Position), Message: c.Err.Message}
+		}
+		return &c
+	}
+	return def
+}
+
+func (s shifter) subnode(sub Subnode) Subnode {
+	sub.Position = s.pos(sub.Position)
+	sub.EndPosition = s.pos(sub.EndPosition)
+	sub.Definitions = s.definitions(sub.Definitions)
+	return sub
+}
+
+func (s shifter) values(vals []Value) []Value {
+	if vals == nil {
+		return nil
+	}
+	out := make([]Value, len(vals))
+	for i, v := range vals {
+		out[i] = s.value(v)
+	}
+	return out
+}
+
+func (s shifter) value(v Value) Value {
+	if v == nil || s.unchanged(v.Pos()) {
+		return v
+	}
+	switch n := v.(type) {
+	case *StringValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *IntValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *FloatValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *BoolValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *ReferenceValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *VariableReferenceValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		return &c
+	case *ArrayValue:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Elements = s.values(c.Elements)
+		return &c
+	case *ConditionalArrayElements:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.EndPosition = s.pos(c.EndPosition)
+		c.Condition = s.value(c.Condition)
+		c.Then = s.values(c.Then)
+		c.Else = s.values(c.Else)
+		return &c
+	case *BinaryExpression:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.Left = s.value(c.Left)
+		c.Operator = s.token(c.Operator)
+		c.Right = s.value(c.Right)
+		return &c
+	case *UnaryExpression:
+		c := *n
+		c.Position = s.pos(c.Position)
+		c.Operator = s.token(c.Operator)
+		c.Right = s.value(c.Right)
+		return &c
+	}
+	return v
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// lineOffsets returns the byte offset of the start of each line.
+func lineOffsets(text string) []int {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+func offsetOf(lines []int, size int, pos Position) int {
+	if pos.Line < 1 {
+		return 0
+	}
+	if pos.Line > len(lines) {
+		return size
+	}
+	off := lines[pos.Line-1] + pos.Column - 1
+	if off > size {
+		return size
+	}
+	return off
+}
+
+func positionOf(lines []int, off int) Position {
+	line := sort.Search(len(lines), func(i int) bool { return lines[i] > off })
+	return Position{Line: line, Column: off - lines[line-1] + 1}
+}
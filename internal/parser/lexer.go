@@ -47,12 +47,19 @@ const (
 	TokenUse
 	TokenVar
 	TokenAs
+	TokenSwitch
+	TokenCase
+	TokenDefault
+	TokenSlot
+	TokenFill
+	TokenWhitespace // only produced as CST trivia
 )
 
 type Token struct {
 	Type     TokenType
 	Value    string
 	Position Position
+	Offset   int // byte offset of the token in the input
 }
 
 type Lexer struct {
@@ -122,6 +129,7 @@ func (l *Lexer) emit(t TokenType) Token {
 			Line:   l.startLine,
 			Column: l.startColumn,
 		},
+		Offset: l.start,
 	}
 	l.ignore()
 	return tok
@@ -217,6 +225,8 @@ func (l *Lexer) NextToken() Token {
 			return l.emit(TokenSymbol)
 		case '"':
 			return l.lexString()
+		case '`':
+			return l.lexRawString()
 		case '#':
 			return l.lexHashIdentifier()
 		case '@':
@@ -278,6 +288,13 @@ func (l *Lexer) lexObjectIdentifier() Token {
 func (l *Lexer) lexString() Token {
 	for {
 		r := l.next()
+		if r == '\\' {
+			// Escaped character (validated by UnquoteString).
+			if l.next() == -1 {
+				return l.emit(TokenError)
+			}
+			continue
+		}
 		if r == '"' {
 			return l.emit(TokenString)
 		}
@@ -287,6 +304,18 @@ func (l *Lexer) lexString() Token {
 	}
 }
 
+func (l *Lexer) lexRawString() Token {
+	for {
+		r := l.next()
+		if r == '`' {
+			return l.emit(TokenString)
+		}
+		if r == -1 {
+			return l.emit(TokenError)
+		}
+	}
+}
+
 func (l *Lexer) lexNumber() Token {
 	// Check for hex or binary prefix if we started with '0'
 	if l.input[l.start:l.pos] == "0" {
@@ -419,12 +448,22 @@ func (l *Lexer) lexHashIdentifier() Token {
 		return l.emit(TokenElse)
 	case "#end":
 		return l.emit(TokenEnd)
+	case "#switch":
+		return l.emit(TokenSwitch)
+	case "#case":
+		return l.emit(TokenCase)
+	case "#default":
+		return l.emit(TokenDefault)
 	case "#foreach":
 		return l.emit(TokenForeach)
 	case "#template":
 		return l.emit(TokenTemplate)
 	case "#use":
 		return l.emit(TokenUse)
+	case "#slot":
+		return l.emit(TokenSlot)
+	case "#fill":
+		return l.emit(TokenFill)
 	}
 	return l.emit(TokenIdentifier)
 }
@@ -7,30 +7,67 @@ import (
 )
 
 type Parser struct {
+	input    string
 	lexer    *Lexer
 	buf      []Token
 	comments []Comment
 	pragmas  []Pragma
 	errors   []error
+
+	// Recovery state
+	depth          int   // current '{' nesting
+	consumed       int   // number of tokens consumed
+	last           Token // last consumed token
+	unclosed       bool  // EOF reached inside a subnode
+	indentRecovery bool  // close subnodes on dedented object definitions
+
+	// Region parsing (see Document.Update)
+	region    bool
+	limit     Position // position of the end of the region
+	truncated bool     // the region ended inside a construct
+}
+
+// Error is a syntax error at a source position.
+type Error struct {
+	Position Position
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Position.Line, e.Position.Column, e.Message)
 }
 
 func NewParser(input string) *Parser {
 	return &Parser{
+		input: input,
 		lexer: NewLexer(input),
 	}
 }
 
 func (p *Parser) addError(pos Position, msg string) {
-	p.errors = append(p.errors, fmt.Errorf("%d:%d: %s", pos.Line, pos.Column, msg))
+	p.errors = append(p.errors, &Error{Position: pos, Message: msg})
+	if p.region && !before(pos, p.limit) {
+		p.truncated = true
+	}
 }
 
 func (p *Parser) next() Token {
+	var t Token
 	if len(p.buf) > 0 {
-		t := p.buf[0]
+		t = p.buf[0]
 		p.buf = p.buf[1:]
-		return t
+	} else {
+		t = p.fetchToken()
+	}
+	switch t.Type {
+	case TokenLBrace:
+		p.depth++
+	case TokenRBrace:
+		p.depth--
 	}
-	return p.fetchToken()
+	p.consumed++
+	p.last = t
+	return t
 }
 
 func (p *Parser) peek() Token {
@@ -47,6 +84,13 @@ func (p *Parser) peekN(n int) Token {
 func (p *Parser) fetchToken() Token {
 	for {
 		tok := p.lexer.NextToken()
+		if p.region && tok.Type != TokenEOF && tok.Offset+len(tok.Value) == len(p.input) {
+			switch tok.Type {
+			case TokenComment, TokenDocstring, TokenPragma, TokenString, TokenError:
+				// May extend past the region in the full text.
+				p.truncated = true
+			}
+		}
 		switch tok.Type {
 		case TokenComment:
 			p.comments = append(p.comments, Comment{Position: tok.Position, Text: tok.Value})
@@ -60,7 +104,30 @@ func (p *Parser) fetchToken() Token {
 	}
 }
 
+// Parse parses the whole input. Malformed definitions are replaced by
+// ErrorNode placeholders and parsing resumes at the next definition, so the
+// returned Configuration is usable even when an error is returned. The
+// returned error is the first one; Errors lists all of them.
 func (p *Parser) Parse() (*Configuration, error) {
+	config := p.parseConfiguration()
+	if p.unclosed {
+		// A '}' is missing somewhere. Parse again, closing open objects when
+		// an object definition is dedented to (or past) their own column, so
+		// that one missing brace does not swallow the rest of the file.
+		retry := NewParser(p.input)
+		retry.indentRecovery = true
+		config = retry.parseConfiguration()
+		*p = *retry
+	}
+
+	var err error
+	if len(p.errors) > 0 {
+		err = p.errors[0]
+	}
+	return config, err
+}
+
+func (p *Parser) parseConfiguration() *Configuration {
 	config := &Configuration{}
 	for {
 		tok := p.peek()
@@ -88,15 +155,78 @@ func (p *Parser) Parse() (*Configuration, error) {
 	}
 	config.Comments = p.comments
 	config.Pragmas = p.pragmas
+	return config
+}
 
-	var err error
-	if len(p.errors) > 0 {
-		err = p.errors[0]
+// parseDefinition parses one definition. On a syntax error it skips to the
+// next definition boundary and returns an ErrorNode covering the skipped
+// tokens, so callers always make progress.
+func (p *Parser) parseDefinition() (Definition, bool) {
+	start := p.peek()
+	depth := p.depth
+	consumed := p.consumed
+	errCount := len(p.errors)
+
+	def, ok := p.parseDefinitionBody()
+	if ok {
+		return def, true
 	}
-	return config, err
+	if len(p.errors) == errCount {
+		p.addError(start.Position, "invalid definition")
+	}
+	return p.synchronize(start, depth, consumed, p.errors[errCount].(*Error)), true
 }
 
-func (p *Parser) parseDefinition() (Definition, bool) {
+// synchronize implements panic-mode recovery. Tokens are skipped until the
+// parser is back at the nesting depth of the failed definition and the next
+// token either closes the enclosing block or starts a new definition on a
+// later line.
+func (p *Parser) synchronize(start Token, depth, consumed int, err *Error) *ErrorNode {
+	node := &ErrorNode{Position: start.Position, EndPosition: start.Position, Err: err}
+	for {
+		t := p.peek()
+		if t.Type == TokenEOF {
+			// In the full text the skip might have stopped elsewhere.
+			p.truncated = p.region
+			break
+		}
+		newLine := p.consumed > consumed && t.Position.Line > p.last.Position.Line
+		if p.depth <= depth {
+			if t.Type == TokenRBrace || t.Type == TokenEnd || t.Type == TokenElse ||
+				t.Type == TokenCase || t.Type == TokenDefault {
+				break
+			}
+			if newLine && p.startsDefinition(t) {
+				break
+			}
+		} else if newLine && t.Type == TokenObjectIdentifier && t.Position.Column <= start.Position.Column {
+			// A brace opened by the broken definition was never closed.
+			p.depth = depth
+			break
+		}
+		p.next()
+	}
+	if p.consumed == consumed && p.peek().Type != TokenEOF {
+		p.next()
+	}
+	if p.consumed > consumed {
+		node.EndPosition = p.last.Position
+	}
+	return node
+}
+
+// startsDefinition reports whether t can begin a definition.
+func (p *Parser) startsDefinition(t Token) bool {
+	switch t.Type {
+	case TokenObjectIdentifier, TokenLet, TokenVar, TokenIf, TokenSwitch, TokenForeach, TokenTemplate, TokenUse, TokenSlot, TokenPackage:
+		return true
+	case TokenIdentifier:
+		return strings.Contains(t.Value, "::") || p.peekN(1).Type == TokenEqual
+	}
+	return false
+}
+
+func (p *Parser) parseDefinitionBody() (Definition, bool) {
 	tok := p.peek()
 	switch tok.Type {
 	case TokenLet:
@@ -108,6 +238,9 @@ func (p *Parser) parseDefinition() (Definition, bool) {
 	case TokenIf:
 		p.next()
 		return p.parseIf(tok)
+	case TokenSwitch:
+		p.next()
+		return p.parseSwitch(tok)
 	case TokenForeach:
 		p.next()
 		return p.parseForeach(tok)
@@ -117,6 +250,9 @@ func (p *Parser) parseDefinition() (Definition, bool) {
 	case TokenUse:
 		p.next()
 		return p.parseUse(tok)
+	case TokenSlot:
+		p.next()
+		return p.parseSlot(tok)
 	case TokenIdentifier:
 		p.next()
 		name := tok.Value
@@ -131,7 +267,7 @@ func (p *Parser) parseDefinition() (Definition, bool) {
 			p.next() // consume =
 			
 			if p.peek().Type == TokenLBrace && p.isSubnodeLookahead() {
-				sub, ok := p.parseSubnode()
+				sub, ok := p.parseSubnode(tok.Position)
 				if !ok {
 					return nil, false
 				}
@@ -169,7 +305,7 @@ func (p *Parser) parseDefinition() (Definition, bool) {
 		}
 		p.next() // Consume =
 
-		sub, ok := p.parseSubnode()
+		sub, ok := p.parseSubnode(tok.Position)
 		if !ok {
 			return nil, false
 		}
@@ -194,7 +330,7 @@ func (p *Parser) parseDefinition() (Definition, bool) {
 		p.next() // consume =
 
 		if p.peek().Type == TokenLBrace && p.isSubnodeLookahead() {
-			sub, ok := p.parseSubnode()
+			sub, ok := p.parseSubnode(nameVal.Pos())
 			if !ok {
 				return nil, false
 			}
@@ -296,7 +432,7 @@ func (p *Parser) parseSignalShorthand(startTok Token, name string) (Definition,
 			p.addError(p.peek().Position, "expected '{' after '='")
 			return nil, false
 		}
-		sub, ok := p.parseSubnode()
+		sub, ok := p.parseSubnode(startTok.Position)
 		if !ok {
 			return nil, false
 		}
@@ -347,6 +483,81 @@ func (p *Parser) parseIf(startTok Token) (Definition, bool) {
 	}, true
 }
 
+func (p *Parser) parseSwitch(startTok Token) (Definition, bool) {
+	subject, ok := p.parseValue()
+	if !ok {
+		return nil, false
+	}
+	sw := &SwitchBlock{Position: startTok.Position, Subject: subject}
+
+	for {
+		t := p.peek()
+		switch t.Type {
+		case TokenCase:
+			p.next()
+			c := SwitchCase{Position: t.Position}
+			for {
+				val, ok := p.parseValue()
+				if !ok {
+					return nil, false
+				}
+				c.Values = append(c.Values, val)
+				if p.peek().Type != TokenComma {
+					break
+				}
+				p.next()
+			}
+			c.Body = p.parseCaseBody()
+			sw.Cases = append(sw.Cases, c)
+		case TokenDefault:
+			p.next()
+			if sw.DefaultPosition != nil {
+				p.addError(t.Position, "duplicate #default")
+			}
+			pos := t.Position
+			sw.DefaultPosition = &pos
+			sw.Default = p.parseCaseBody()
+		case TokenEnd:
+			sw.EndPosition = p.next().Position
+			return sw, true
+		case TokenEOF:
+			// Missing #end: keep what was parsed.
+			p.addError(t.Position, "expected #end")
+			sw.EndPosition = t.Position
+			return sw, true
+		case TokenElse:
+			p.addError(t.Position, "#else is not allowed in #switch")
+			p.next()
+		default:
+			// Definitions before the first #case are dropped.
+			p.addError(t.Position, "expected #case or #default")
+			p.parseCaseBody()
+		}
+	}
+}
+
+// parseCaseBody parses the body of a #case or #default up to the next
+// #case, #default, #else or #end, which is left to parseSwitch.
+func (p *Parser) parseCaseBody() []Definition {
+	var defs []Definition
+	for {
+		t := p.peek()
+		switch t.Type {
+		case TokenCase, TokenDefault, TokenElse, TokenEnd, TokenEOF:
+			return defs
+		case TokenRBrace:
+			p.next()
+			continue
+		}
+		def, ok := p.parseDefinition()
+		if ok {
+			defs = append(defs, def)
+		} else {
+			p.next()
+		}
+	}
+}
+
 // parseConditionalArrayElements parses a #if block that appears inside an array:
 //
 //	{ X  #if cond  Y  Z  #else  W  #end  V }
@@ -519,6 +730,17 @@ func (p *Parser) parseTemplate(startTok Token) (Definition, bool) {
 		}
 	}
 
+	// #template Name(...) extends Base, on the same line.
+	var base Token
+	if t := p.peek(); t.Type == TokenIdentifier && t.Value == "extends" && t.Position.Line == p.last.Position.Line {
+		p.next()
+		base = p.next()
+		if base.Type != TokenIdentifier {
+			p.addError(base.Position, "expected base template name")
+			return nil, false
+		}
+	}
+
 	if p.peek().Type == TokenLBrace {
 		p.next() // consume {
 	}
@@ -533,10 +755,35 @@ func (p *Parser) parseTemplate(startTok Token) (Definition, bool) {
 	}
 
 	return &TemplateDefinition{
+		Position:     startTok.Position,
+		EndPosition:  endTok.Position,
+		Name:         nameTok.Value,
+		Parameters:   params,
+		Body:         body,
+		Base:         base.Value,
+		BasePosition: base.Position,
+	}, true
+}
+
+func (p *Parser) parseSlot(startTok Token) (Definition, bool) {
+	nameTok := p.next()
+	if nameTok.Type != TokenIdentifier {
+		p.addError(nameTok.Position, "expected slot name")
+		return nil, false
+	}
+
+	body, endTok, ok := p.parseBlock()
+	if !ok {
+		return nil, false
+	}
+	if endTok.Type != TokenEnd {
+		p.addError(endTok.Position, "expected #end")
+	}
+
+	return &SlotBlock{
 		Position:    startTok.Position,
 		EndPosition: endTok.Position,
 		Name:        nameTok.Value,
-		Parameters:  params,
 		Body:        body,
 	}, true
 }
@@ -573,20 +820,56 @@ func (p *Parser) parseUse(startTok Token) (Definition, bool) {
 				return nil, false
 			}
 			val, _ := p.parseValue()
-			args = append(args, TemplateArgument{Name: argName.Value, Value: val})
+			args = append(args, TemplateArgument{Position: argName.Position, Name: argName.Value, Value: val})
 			if p.peek().Type == TokenComma {
 				p.next()
 			}
 		}
 	}
 
-	return &TemplateInstantiation{
+	inst := &TemplateInstantiation{
 		Position:    startTok.Position,
 		EndPosition: p.peek().Position, // Rough
 		Name:        instanceNameTok.Value,
 		Template:    templateTok.Value,
 		Arguments:   args,
-	}, true
+	}
+
+	// Block arguments: one or more '#fill NAME … #end', closed by '#end'.
+	if p.peek().Type != TokenFill {
+		return inst, true
+	}
+	for {
+		t := p.peek()
+		if t.Type == TokenEnd {
+			inst.EndPosition = p.next().Position
+			return inst, true
+		}
+		if t.Type != TokenFill {
+			p.addError(t.Position, "expected #fill or #end")
+			inst.EndPosition = t.Position
+			return inst, true
+		}
+		p.next()
+		nameTok := p.next()
+		if nameTok.Type != TokenIdentifier {
+			p.addError(nameTok.Position, "expected slot name")
+			return nil, false
+		}
+		body, endTok, ok := p.parseBlock()
+		if !ok {
+			return nil, false
+		}
+		if endTok.Type != TokenEnd {
+			p.addError(endTok.Position, "expected #end")
+		}
+		inst.Blocks = append(inst.Blocks, TemplateBlock{
+			Position:    t.Position,
+			EndPosition: endTok.Position,
+			Name:        nameTok.Value,
+			Body:        body,
+		})
+	}
 }
 
 func (p *Parser) parseBlock() ([]Definition, Token, bool) {
@@ -594,11 +877,17 @@ func (p *Parser) parseBlock() ([]Definition, Token, bool) {
 	for {
 		t := p.peek()
 		if t.Type == TokenEOF {
-			return defs, t, false
+			// Missing #end: keep what was parsed, the caller reports it.
+			return defs, t, true
 		}
 		if t.Type == TokenEnd || t.Type == TokenElse {
 			return defs, p.next(), true
 		}
+		if t.Type == TokenCase || t.Type == TokenDefault {
+			// Belongs to an enclosing #switch; the caller reports the
+			// missing #end.
+			return defs, t, true
+		}
 		if t.Type == TokenRBrace {
 			// If we are in a brace block, #end might be inside or after.
 			// Usually we expect #end to close the block.
@@ -655,7 +944,9 @@ func (p *Parser) isSubnodeLookahead() bool {
 	return false
 }
 
-func (p *Parser) parseSubnode() (Subnode, bool) {
+// parseSubnode parses a '{ … }' block of definitions. owner is the position
+// of the definition the block belongs to.
+func (p *Parser) parseSubnode(owner Position) (Subnode, bool) {
 	tok := p.next()
 	if tok.Type != TokenLBrace {
 		p.addError(tok.Position, "expected {")
@@ -671,9 +962,17 @@ func (p *Parser) parseSubnode() (Subnode, bool) {
 		}
 		if t.Type == TokenEOF {
 			p.addError(t.Position, "unexpected EOF, expected }")
+			p.unclosed = true
 			sub.EndPosition = t.Position
 			return sub, true
 		}
+		if p.indentRecovery && t.Type == TokenObjectIdentifier &&
+			t.Position.Line > tok.Position.Line && t.Position.Column <= owner.Column {
+			p.addError(tok.Position, "missing } for block opened here")
+			p.depth--
+			sub.EndPosition = p.last.Position
+			return sub, true
+		}
 		def, ok := p.parseDefinition()
 		if ok {
 			sub.Definitions = append(sub.Definitions, def)
@@ -686,6 +985,13 @@ func (p *Parser) parseSubnode() (Subnode, bool) {
 	return sub, true
 }
 
+// operator returns t for use in the AST. Nodes keep positions only: byte
+// offsets would go stale when Document.Update reuses them after an edit.
+func operator(t Token) Token {
+	t.Offset = 0
+	return t
+}
+
 func (p *Parser) parseValue() (Value, bool) {
 	return p.parseExpression(0)
 }
@@ -739,7 +1045,7 @@ func (p *Parser) parseExpression(minPrecedence int) (Value, bool) {
 		left = &BinaryExpression{
 			Position: left.Pos(),
 			Left:     left,
-			Operator: t,
+			Operator: operator(t),
 			Right:    right,
 		}
 	}
@@ -750,10 +1056,15 @@ func (p *Parser) parseAtom() (Value, bool) {
 	tok := p.next()
 	switch tok.Type {
 	case TokenString:
+		val, err := UnquoteString(tok.Value)
+		if err != nil {
+			p.addError(tok.Position, err.Error())
+		}
 		return &StringValue{
 			Position: tok.Position,
-			Value:    strings.Trim(tok.Value, "\""),
+			Value:    val,
 			Quoted:   true,
+			Raw:      tok.Value,
 		}, true
 
 	case TokenNumber:
@@ -779,7 +1090,7 @@ func (p *Parser) parseAtom() (Value, bool) {
 		if !ok {
 			return nil, false
 		}
-		return &UnaryExpression{Position: tok.Position, Operator: tok, Right: val}, true
+		return &UnaryExpression{Position: tok.Position, Operator: operator(tok), Right: val}, true
 	case TokenSymbol:
 		if tok.Value == "(" {
 			val, ok := p.parseExpression(0)
@@ -797,9 +1108,10 @@ func (p *Parser) parseAtom() (Value, bool) {
 			if !ok {
 				return nil, false
 			}
-			return &UnaryExpression{Position: tok.Position, Operator: tok, Right: val}, true
+			return &UnaryExpression{Position: tok.Position, Operator: operator(tok), Right: val}, true
 		}
-		fallthrough
+		p.addError(tok.Position, fmt.Sprintf("unexpected value token %v", tok.Value))
+		return nil, false
 	case TokenLBrace:
 		arr := &ArrayValue{Position: tok.Position}
 		for {
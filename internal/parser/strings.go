@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UnquoteString returns the value of a string literal as produced by the
+// lexer. Two forms are supported:
+//
+//	"text"  double-quoted, with escapes \" \\ \n \t \r \uXXXX and a
+//	        backslash-newline line continuation that drops the newline and
+//	        the indentation of the following line
+//	`text`  raw, may span several lines, no escapes
+//
+// On a malformed escape the partially decoded value is returned together
+// with an error.
+func UnquoteString(lit string) (string, error) {
+	if len(lit) >= 2 && lit[0] == '`' && lit[len(lit)-1] == '`' {
+		return lit[1 : len(lit)-1], nil
+	}
+	if len(lit) < 2 || lit[0] != '"' || lit[len(lit)-1] != '"' {
+		return strings.Trim(lit, "\""), fmt.Errorf("unterminated string literal")
+	}
+	body := lit[1 : len(lit)-1]
+	if !strings.ContainsRune(body, '\\') {
+		return body, nil
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(body) {
+			return sb.String(), fmt.Errorf("unterminated escape sequence")
+		}
+		switch body[i] {
+		case '"':
+			sb.WriteByte('"')
+		case '\\':
+			sb.WriteByte('\\')
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'u':
+			if i+5 > len(body) {
+				return sb.String(), fmt.Errorf("invalid escape sequence '\\u%s': expected 4 hex digits", body[i+1:])
+			}
+			code, err := strconv.ParseUint(body[i+1:i+5], 16, 32)
+			if err != nil {
+				return sb.String(), fmt.Errorf("invalid escape sequence '\\u%s': expected 4 hex digits", body[i+1:i+5])
+			}
+			sb.WriteRune(rune(code))
+			i += 4
+		case '\n', '\r':
+			// Line continuation: skip the line break and leading indentation.
+			if body[i] == '\r' && i+1 < len(body) && body[i+1] == '\n' {
+				i++
+			}
+			for i+1 < len(body) && (body[i+1] == ' ' || body[i+1] == '\t') {
+				i++
+			}
+		default:
+			r, _ := utf8.DecodeRuneInString(body[i:])
+			return sb.String(), fmt.Errorf("unknown escape sequence '\\%c'", r)
+		}
+	}
+	return sb.String(), nil
+}
+
+// QuoteString returns s as a double-quoted literal using only the escapes
+// understood by the MARTe configuration parser (\" \\ \n \t \r). Other
+// characters, including non-ASCII ones, are written as is.
+func QuoteString(s string) string {
+	if !strings.ContainsAny(s, "\"\\\n\t\r") {
+		return "\"" + s + "\""
+	}
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString("\\\"")
+		case '\\':
+			sb.WriteString("\\\\")
+		case '\n':
+			sb.WriteString("\\n")
+		case '\t':
+			sb.WriteString("\\t")
+		case '\r':
+			sb.WriteString("\\r")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+)
+
+// ClassInfo describes a class of the merged schema.
+type ClassInfo struct {
+	Name   string
+	Fields []FieldInfo
+	// Meta are the fields of #meta.
+	Meta []FieldInfo
+	// Open tells whether the class accepts fields it does not declare.
+	Open bool
+	// Sources are the layers defining the class.
+	Sources []Constraint
+}
+
+// FieldInfo describes a field of a class.
+type FieldInfo struct {
+	Name     string
+	Optional bool
+	// Type is the merged constraint.
+	Type string
+	// Enum lists the allowed values when the field is an enumeration.
+	Enum []string
+	// Default is the default value, if any.
+	Default string
+	// Constraints are the constraints set by each layer on the field.
+	Constraints []Constraint
+}
+
+// Constraint is the value given to a schema path by one layer.
+type Constraint struct {
+	Layer string
+	Line  int
+	Expr  string
+}
+
+// Explain describes class as the merged schema defines it; it returns nil
+// when the schema has no such class.
+func (s *Schema) Explain(class string) *ClassInfo {
+	if i := strings.LastIndex(class, "::"); i != -1 {
+		class = class[i+2:]
+	}
+	path := cue.MakePath(cue.Def("#Classes"), cue.Str(class))
+	val := s.Value.LookupPath(path)
+	if !val.Exists() {
+		return nil
+	}
+	info := &ClassInfo{
+		Name:    class,
+		Open:    val.Allows(cue.AnyString),
+		Sources: s.constraints(path),
+	}
+	info.Fields = s.fieldInfos(val, path)
+	metaPath := cue.MakePath(append(path.Selectors(), cue.Def("#meta"))...)
+	if meta := s.Value.LookupPath(metaPath); meta.Exists() {
+		info.Meta = s.fieldInfos(meta, metaPath)
+	}
+	return info
+}
+
+func (s *Schema) fieldInfos(val cue.Value, path cue.Path) []FieldInfo {
+	var fields []FieldInfo
+	iter, err := val.Fields(cue.Optional(true))
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		sel := iter.Selector()
+		if !sel.IsString() {
+			continue
+		}
+		f := iter.Value()
+		fieldSel := cue.Str(sel.Unquoted())
+		if iter.IsOptional() {
+			fieldSel = fieldSel.Optional()
+		}
+		info := FieldInfo{
+			Name:        sel.Unquoted(),
+			Optional:    iter.IsOptional(),
+			Type:        fmt.Sprint(f),
+			Constraints: s.constraints(cue.MakePath(append(path.Selectors(), fieldSel)...)),
+		}
+		if op, args := f.Expr(); op == cue.OrOp {
+			for _, a := range args {
+				if !a.IsConcrete() || a.IncompleteKind()&(cue.StructKind|cue.ListKind) != 0 {
+					info.Enum = nil
+					break
+				}
+				info.Enum = append(info.Enum, fmt.Sprint(a))
+			}
+		}
+		if d, ok := f.Default(); ok && d.IsConcrete() {
+			info.Default = fmt.Sprint(d)
+		}
+		fields = append(fields, info)
+	}
+	return fields
+}
+
+// constraints returns the value each layer gives to path, as written in the
+// layer.
+func (s *Schema) constraints(path cue.Path) []Constraint {
+	var cs []Constraint
+	for _, layer := range s.Layers {
+		if layer.Err != nil {
+			continue
+		}
+		// Fields matched by '...' alone have no position.
+		v := layer.Value.LookupPath(path)
+		if !v.Exists() || v.Pos().Line() == 0 {
+			continue
+		}
+		c := Constraint{Layer: layer.Name, Line: v.Pos().Line()}
+		node := v.Source()
+		if f, ok := node.(*ast.Field); ok {
+			node = f.Value
+		}
+		if node != nil {
+			if b, err := format.Node(node); err == nil {
+				c.Expr = string(b)
+			}
+		}
+		if c.Expr == "" {
+			c.Expr = fmt.Sprint(v)
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
@@ -0,0 +1,674 @@
+package schema
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/format"
+)
+
+// Extraction is the schema skeleton of the MARTe classes registered in a set
+// of C++ sources.
+type Extraction struct {
+	Roots   []string
+	Classes []*ExtractedClass
+}
+
+// ExtractedClass is a class registered with CLASS_REGISTER.
+type ExtractedClass struct {
+	Name string
+	File string
+	Line int
+	// Bases are the direct base classes, without namespace.
+	Bases []string
+	// MetaType is "gam" or "datasource", or empty when the class derives
+	// from neither GAM nor DataSourceI.
+	MetaType string
+	// Direction is IN, OUT or INOUT for DataSources whose brokers were found.
+	Direction string
+	Fields    []*ExtractedField
+	// InputSignals and OutputSignals report whether the class uses signals of
+	// that kind.
+	InputSignals, OutputSignals bool
+	// InputTypes and OutputTypes are the signal types accepted by
+	// GetSignalType checks.
+	InputTypes, OutputTypes []string
+	// Guesses explain the class-level entries that could only be guessed.
+	Guesses []string
+}
+
+// ExtractedField is a parameter read by the Initialise method of a class.
+type ExtractedField struct {
+	Name     string
+	Type     string
+	Optional bool
+	File     string
+	Line     int
+	// Guess explains why the field is a guess; empty when its type and
+	// presence were derived from the sources.
+	Guess string
+}
+
+// Guessed returns the number of entries of the extraction that could only
+// be guessed.
+func (e *Extraction) Guessed() int {
+	n := 0
+	for _, c := range e.Classes {
+		n += len(c.Guesses)
+		for _, f := range c.Fields {
+			if f.Guess != "" {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+var (
+	cppExtensions = map[string]bool{".h": true, ".hh": true, ".hpp": true, ".cpp": true, ".cc": true, ".cxx": true}
+
+	classRegisterRe = regexp.MustCompile(`\bCLASS_REGISTER\s*\(\s*(?:\w+::)*(\w+)\s*,`)
+	classDeclRe     = regexp.MustCompile(`\bclass\s+(?:\w+\s+)?(\w+)\s*(?:final\s*)?:\s*([^{;]+)\{`)
+	baseRe          = regexp.MustCompile(`(?:public|protected|private)?\s*(?:virtual\s+)?(?:\w+::)*(\w+)\s*(?:<[^>]*>)?\s*$`)
+	methodRe        = regexp.MustCompile(`(?m)^[\w:<>\s\*&]*?\b(\w+)::(~?\w+)\s*\(`)
+	readRe          = regexp.MustCompile(`\b\w+\s*(?:\.|->)\s*Read\s*\(\s*"([^"]+)"\s*,\s*([^;]+?)\)\s*[);&|]`)
+	moveRe          = regexp.MustCompile(`\.\s*(MoveRelative|MoveToChild|MoveAbsolute|MoveToAncestor|MoveToRoot)\s*\(`)
+	reportRe        = regexp.MustCompile(`REPORT_ERROR(?:_STATIC)?\s*\(\s*(?:\w+::)*(\w+)`)
+	signalTypeRe    = regexp.MustCompile(`GetSignalType\s*\(\s*(InputSignals|OutputSignals)\s*,[^)]*\)\s*(?:==|!=)\s*(?:\w+::)*(\w+)|(?:\w+::)*(\w+)\s*(?:==|!=)\s*GetSignalType\s*\(\s*(InputSignals|OutputSignals)\b`)
+	inputUseRe      = regexp.MustCompile(`\b(?:InputSignals|GetNumberOfInputSignals|GetInputSignalsMemory|GetInputSignalMemory)\b`)
+	outputUseRe     = regexp.MustCompile(`\b(?:OutputSignals|GetNumberOfOutputSignals|GetOutputSignalsMemory|GetOutputSignalMemory)\b`)
+	inputBrokerRe   = regexp.MustCompile(`\w*InputBroker\b|\bReadOnly\b`)
+	outputBrokerRe  = regexp.MustCompile(`\w*OutputBroker\b|\bWriteOnly\b`)
+	declRe          = regexp.MustCompile(`((?:\w+::)*\w+(?:\s*<[^<>;()]*>)?)\s*([*&]?)\s*\b(\w+)\s*(\[[^\]]*\])?\s*[;=,)]`)
+)
+
+// metaTypeBases are the MARTe base classes that give a class its MetaType.
+var metaTypeBases = map[string]string{
+	"GAM":                                 "gam",
+	"StatefulGAM":                         "gam",
+	"MessageGAM":                          "gam",
+	"DataSourceI":                         "datasource",
+	"MemoryDataSourceI":                   "datasource",
+	"CircularBufferThreadInputDataSource": "datasource",
+}
+
+// neutralBases are MARTe base classes of objects that are neither GAMs nor
+// DataSources.
+var neutralBases = map[string]bool{
+	"Object": true, "ReferenceContainer": true, "MessageI": true, "StatefulI": true,
+	"EmbeddedServiceMethodBinderI": true, "ExecutableI": true, "StateMachine": true,
+}
+
+// typeDescriptors maps the MARTe TypeDescriptor constants to signal types.
+var typeDescriptors = map[string]string{
+	"SignedInteger8Bit": "int8", "SignedInteger16Bit": "int16", "SignedInteger32Bit": "int32", "SignedInteger64Bit": "int64",
+	"UnsignedInteger8Bit": "uint8", "UnsignedInteger16Bit": "uint16", "UnsignedInteger32Bit": "uint32", "UnsignedInteger64Bit": "uint64",
+	"Float32Bit": "float32", "Float64Bit": "float64", "Character8Bit": "char8",
+}
+
+type cppFile struct {
+	path string
+	text string
+}
+
+// line returns the 1-based line of offset off.
+func (f *cppFile) line(off int) int {
+	return strings.Count(f.text[:off], "\n") + 1
+}
+
+// section is the body of a method definition of a class.
+type section struct {
+	file       *cppFile
+	class      string
+	method     string
+	start, end int
+}
+
+// ExtractSchema scans the C++ sources under roots for classes registered
+// with CLASS_REGISTER and derives their schema: the parameters read by
+// Initialise, the signal types checked with GetSignalType and, for
+// DataSources, the direction from the brokers they provide.
+func ExtractSchema(roots []string) (*Extraction, error) {
+	var files []*cppFile
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !cppExtensions[strings.ToLower(filepath.Ext(path))] {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files = append(files, &cppFile{path: path, text: stripComments(string(data))})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	classes := make(map[string]*ExtractedClass)
+	bases := make(map[string][]string)
+	for _, f := range files {
+		for _, m := range classRegisterRe.FindAllStringSubmatchIndex(f.text, -1) {
+			name := f.text[m[2]:m[3]]
+			if classes[name] == nil {
+				classes[name] = &ExtractedClass{Name: name, File: f.path, Line: f.line(m[0])}
+			}
+		}
+		for _, m := range classDeclRe.FindAllStringSubmatch(f.text, -1) {
+			for _, b := range splitTopLevel(m[2]) {
+				if bm := baseRe.FindStringSubmatch(b); bm != nil {
+					bases[m[1]] = append(bases[m[1]], bm[1])
+				}
+			}
+		}
+	}
+
+	sections := methodSections(files, bases, classes)
+	// Unregistered base classes are extracted too, for their parameters to
+	// be inherited.
+	all := make(map[string]*ExtractedClass)
+	for name := range bases {
+		all[name] = &ExtractedClass{Name: name}
+	}
+	for name, c := range classes {
+		all[name] = c
+	}
+	for _, c := range all {
+		c.Bases = bases[c.Name]
+		extractClass(c, sections[c.Name], files)
+	}
+	e := &Extraction{Roots: roots}
+	for _, c := range classes {
+		e.Classes = append(e.Classes, c)
+	}
+	for _, c := range e.Classes {
+		c.MetaType = resolveMetaType(c.Name, bases, map[string]bool{})
+		inheritFields(c, all, bases, map[string]bool{c.Name: true})
+		if c.MetaType == "" && !derivesFrom(c.Name, bases, neutralBases, map[string]bool{}) {
+			c.Guesses = append(c.Guesses, fmt.Sprintf("base classes %s unknown; set #meta: MetaType", describeBases(c.Bases)))
+		}
+		if c.MetaType == "datasource" && c.Direction == "" {
+			c.Guesses = append(c.Guesses, "no input or output broker found; set #meta: direction")
+		}
+	}
+	sort.Slice(e.Classes, func(i, j int) bool { return e.Classes[i].Name < e.Classes[j].Name })
+	return e, nil
+}
+
+// methodSections splits the sources into the bodies of the methods of the
+// known classes.
+func methodSections(files []*cppFile, bases map[string][]string, classes map[string]*ExtractedClass) map[string][]section {
+	sections := make(map[string][]section)
+	for _, f := range files {
+		var starts []section
+		for _, m := range methodRe.FindAllStringSubmatchIndex(f.text, -1) {
+			class := f.text[m[2]:m[3]]
+			if classes[class] == nil && bases[class] == nil {
+				continue
+			}
+			// A definition is followed by its body, not by ';'.
+			close := matchingParen(f.text, m[1]-1)
+			if close == -1 {
+				continue
+			}
+			rest := strings.TrimSpace(f.text[close+1:])
+			if rest == "" || rest[0] == ';' || rest[0] == ',' || rest[0] == ')' {
+				continue
+			}
+			starts = append(starts, section{file: f, class: class, method: f.text[m[4]:m[5]], start: m[0]})
+		}
+		for i := range starts {
+			starts[i].end = len(f.text)
+			if i+1 < len(starts) {
+				starts[i].end = starts[i+1].start
+			}
+			sections[starts[i].class] = append(sections[starts[i].class], starts[i])
+		}
+	}
+	return sections
+}
+
+func extractClass(c *ExtractedClass, sections []section, files []*cppFile) {
+	inputBroker, outputBroker := false, false
+	seen := make(map[string]bool)
+	for _, s := range sections {
+		body := s.file.text[s.start:s.end]
+		if s.method == "Initialise" {
+			for _, f := range readFields(c.Name, s, files) {
+				if !seen[f.Name] {
+					seen[f.Name] = true
+					c.Fields = append(c.Fields, f)
+				}
+			}
+		}
+		for _, m := range signalTypeRe.FindAllStringSubmatch(body, -1) {
+			kind, constant := m[1], m[2]
+			if kind == "" {
+				kind, constant = m[4], m[3]
+			}
+			t, ok := typeDescriptors[constant]
+			if !ok {
+				continue
+			}
+			if kind == "InputSignals" {
+				c.InputTypes = appendUnique(c.InputTypes, t)
+			} else {
+				c.OutputTypes = appendUnique(c.OutputTypes, t)
+			}
+		}
+		c.InputSignals = c.InputSignals || inputUseRe.MatchString(body)
+		c.OutputSignals = c.OutputSignals || outputUseRe.MatchString(body)
+		inputBroker = inputBroker || inputBrokerRe.MatchString(body)
+		outputBroker = outputBroker || outputBrokerRe.MatchString(body)
+	}
+	switch {
+	case inputBroker && outputBroker:
+		c.Direction = "INOUT"
+	case inputBroker:
+		c.Direction = "IN"
+	case outputBroker:
+		c.Direction = "OUT"
+	}
+}
+
+// readFields returns the parameters read from the configuration of the
+// object itself, skipping the reads that follow a move into a child node.
+func readFields(class string, s section, files []*cppFile) []*ExtractedField {
+	body := s.file.text[s.start:s.end]
+	moves := moveRe.FindAllStringSubmatchIndex(body, -1)
+	reads := readRe.FindAllStringSubmatchIndex(body, -1)
+	var fields []*ExtractedField
+	depth, mi := 0, 0
+	for ri, m := range reads {
+		for mi < len(moves) && moves[mi][0] < m[0] {
+			switch body[moves[mi][2]:moves[mi][3]] {
+			case "MoveRelative", "MoveToChild", "MoveAbsolute":
+				depth++
+			case "MoveToAncestor":
+				if depth > 0 {
+					depth--
+				}
+			case "MoveToRoot":
+				depth = 0
+			}
+			mi++
+		}
+		if depth > 0 {
+			continue
+		}
+		name := body[m[2]:m[3]]
+		variable := body[m[4]:m[5]]
+		f := &ExtractedField{Name: name, File: s.file.path, Line: s.file.line(s.start + m[0])}
+
+		var guesses []string
+		t, known := cueType(class, variable, s.file, files)
+		f.Type = t
+		if !known {
+			guesses = append(guesses, fmt.Sprintf("type of '%s' unknown", strings.TrimSpace(variable)))
+		}
+
+		// A read whose result is ignored keeps the default value; otherwise
+		// the error reported when it fails tells whether the parameter is
+		// mandatory.
+		stmt := strings.LastIndexAny(body[:m[0]], ";{}")
+		if strings.TrimSpace(body[stmt+1:m[0]]) == "" {
+			f.Optional = true
+			f.Guess = strings.Join(guesses, "; ")
+			fields = append(fields, f)
+			continue
+		}
+		end := len(body)
+		if ri+1 < len(reads) {
+			end = reads[ri+1][0]
+		}
+		if end-m[1] > 400 {
+			end = m[1] + 400
+		}
+		switch level := reportLevel(body[m[1]:end]); level {
+		case "InitialisationError", "ParametersError", "FatalError":
+		case "":
+			f.Optional = true
+			guesses = append(guesses, "no error reported when missing")
+		default:
+			f.Optional = true
+		}
+		f.Guess = strings.Join(guesses, "; ")
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func reportLevel(text string) string {
+	if m := reportRe.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// cueType returns the CUE type of the C++ variable a parameter is read into,
+// looking for its declaration in the file of the read and then in the
+// headers declaring the class.
+func cueType(class, variable string, file *cppFile, files []*cppFile) (string, bool) {
+	variable = strings.TrimSpace(variable)
+	variable = strings.TrimPrefix(variable, "this->")
+	variable = strings.TrimLeft(variable, "&*( ")
+	if i := strings.IndexAny(variable, "[."); i != -1 {
+		variable = variable[:i]
+	}
+	variable = strings.TrimSpace(variable)
+	if !regexp.MustCompile(`^\w+$`).MatchString(variable) {
+		return "_", false
+	}
+	candidates := []*cppFile{file}
+	for _, f := range files {
+		if f != file && regexp.MustCompile(`\bclass\s+(?:\w+\s+)?`+class+`\b`).MatchString(f.text) {
+			candidates = append(candidates, f)
+		}
+	}
+	for _, f := range candidates {
+		for _, m := range declRe.FindAllStringSubmatch(f.text, -1) {
+			if m[3] != variable {
+				continue
+			}
+			if t, ok := cppToCUE(m[1], m[2], m[4]); ok {
+				return t, true
+			}
+		}
+	}
+	return "_", false
+}
+
+// cppToCUE maps a C++ declaration to the CUE type of the configuration value
+// it is read from.
+func cppToCUE(typ, ptr, array string) (string, bool) {
+	typ = strings.Join(strings.Fields(typ), "")
+	if i := strings.LastIndex(typ, "::"); i != -1 && !strings.Contains(typ[i:], ">") {
+		typ = typ[i+2:]
+	}
+	elem := ""
+	switch {
+	case strings.HasPrefix(typ, "Vector<") || strings.HasPrefix(typ, "MARTe::Vector<"),
+		strings.HasPrefix(typ, "Matrix<") || strings.HasPrefix(typ, "MARTe::Matrix<"):
+		inner := typ[strings.Index(typ, "<")+1 : strings.LastIndex(typ, ">")]
+		if i := strings.LastIndex(inner, "::"); i != -1 {
+			inner = inner[i+2:]
+		}
+		t, ok := scalarCUE(inner)
+		if !ok {
+			return "", false
+		}
+		return listOf(t), true
+	default:
+		t, ok := scalarCUE(typ)
+		if !ok {
+			return "", false
+		}
+		elem = t
+	}
+	if elem == "string" && ptr == "*" {
+		return "string", true
+	}
+	if ptr == "*" {
+		return "", false
+	}
+	if array != "" {
+		if elem == "string" && strings.HasPrefix(typ, "char") {
+			return "string", true
+		}
+		return listOf(elem), true
+	}
+	return elem, true
+}
+
+func listOf(elem string) string {
+	if strings.Contains(elem, "|") {
+		elem = "(" + elem + ")"
+	}
+	return "[..." + elem + "]"
+}
+
+func scalarCUE(typ string) (string, bool) {
+	switch typ {
+	case "float32", "float64", "float", "double":
+		return "float | int", true
+	case "int8", "int16", "int32", "int64", "uint8", "uint16", "uint32", "uint64",
+		"int", "long", "short", "unsigned", "uint", "size_t":
+		return "int", true
+	case "StreamString", "string", "std::string", "char8", "char":
+		return "string", true
+	}
+	return "", false
+}
+
+func resolveMetaType(class string, bases map[string][]string, visiting map[string]bool) string {
+	if t, ok := metaTypeBases[class]; ok {
+		return t
+	}
+	if visiting[class] {
+		return ""
+	}
+	visiting[class] = true
+	for _, b := range bases[class] {
+		if t := resolveMetaType(b, bases, visiting); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+func derivesFrom(class string, bases map[string][]string, set map[string]bool, visiting map[string]bool) bool {
+	if set[class] {
+		return true
+	}
+	if visiting[class] {
+		return false
+	}
+	visiting[class] = true
+	for _, b := range bases[class] {
+		if derivesFrom(b, bases, set, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// inheritFields adds the parameters, signal types and direction of the
+// registered base classes of c.
+func inheritFields(c *ExtractedClass, classes map[string]*ExtractedClass, bases map[string][]string, visiting map[string]bool) {
+	for _, b := range bases[c.Name] {
+		base := classes[b]
+		if base == nil || visiting[b] {
+			continue
+		}
+		visiting[b] = true
+		inheritFields(base, classes, bases, visiting)
+		names := make(map[string]bool)
+		for _, f := range c.Fields {
+			names[f.Name] = true
+		}
+		for _, f := range base.Fields {
+			if !names[f.Name] {
+				c.Fields = append(c.Fields, f)
+			}
+		}
+		for _, t := range base.InputTypes {
+			c.InputTypes = appendUnique(c.InputTypes, t)
+		}
+		for _, t := range base.OutputTypes {
+			c.OutputTypes = appendUnique(c.OutputTypes, t)
+		}
+		c.InputSignals = c.InputSignals || base.InputSignals
+		c.OutputSignals = c.OutputSignals || base.OutputSignals
+		if c.Direction == "" {
+			c.Direction = base.Direction
+		}
+	}
+}
+
+func describeBases(bases []string) string {
+	if len(bases) == 0 {
+		return "(none)"
+	}
+	return strings.Join(bases, ", ")
+}
+
+// CUE renders the extraction as a .marte_schema.cue file.
+func (e *Extraction) CUE() string {
+	var b strings.Builder
+	b.WriteString("package schema\n\n")
+	fmt.Fprintf(&b, "// Generated by 'mdt schema extract' from %s.\n", strings.Join(e.Roots, ", "))
+	b.WriteString("// Entries marked \"guessed\" could not be derived from the sources alone;\n")
+	b.WriteString("// check them before relying on the schema.\n\n")
+	b.WriteString("#Classes: {\n")
+	for i, c := range e.Classes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "// %s:%d\n", filepath.Base(c.File), c.Line)
+		for _, g := range c.Guesses {
+			fmt.Fprintf(&b, "// guessed: %s\n", g)
+		}
+		fmt.Fprintf(&b, "%s: {\n", c.Name)
+		for _, f := range c.Fields {
+			opt := ""
+			if f.Optional {
+				opt = "?"
+			}
+			fmt.Fprintf(&b, "%s%s: %s", cueLabel(f.Name), opt, f.Type)
+			if f.Guess != "" {
+				fmt.Fprintf(&b, " // guessed: %s", f.Guess)
+			}
+			b.WriteString("\n")
+		}
+		if c.MetaType != "datasource" {
+			writeSignals(&b, "InputSignals", c.InputSignals, c.InputTypes)
+			writeSignals(&b, "OutputSignals", c.OutputSignals, c.OutputTypes)
+		}
+		if c.MetaType != "" {
+			fmt.Fprintf(&b, "#meta: MetaType: %q\n", c.MetaType)
+		}
+		if c.MetaType == "datasource" && c.Direction != "" {
+			fmt.Fprintf(&b, "#meta: direction: %q\n", c.Direction)
+		}
+		b.WriteString("...\n}\n")
+	}
+	b.WriteString("}\n")
+
+	out, err := format.Source([]byte(b.String()))
+	if err != nil {
+		return b.String()
+	}
+	return string(out)
+}
+
+func writeSignals(b *strings.Builder, kind string, used bool, types []string) {
+	if len(types) == 0 {
+		if used {
+			fmt.Fprintf(b, "%s: {...}\n", kind)
+		}
+		return
+	}
+	quoted := make([]string, len(types))
+	for i, t := range types {
+		quoted[i] = fmt.Sprintf("%q", t)
+	}
+	fmt.Fprintf(b, "%s: {[_]: {Type: %s, ...}} // guessed: from GetSignalType checks, which may apply to some signals only\n",
+		kind, strings.Join(quoted, " | "))
+}
+
+func cueLabel(name string) string {
+	if regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`).MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// stripComments blanks the C++ comments of text, keeping string literals and
+// line numbers.
+func stripComments(text string) string {
+	out := []byte(text)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"' || out[i] == '\'':
+			quote := out[i]
+			for i++; i < len(out) && out[i] != quote && out[i] != '\n'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			for ; i < len(out) && !(out[i] == '*' && i+1 < len(out) && out[i+1] == '/'); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i+1 < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		}
+	}
+	return string(out)
+}
+
+// matchingParen returns the offset of the parenthesis closing the one at
+// open, or -1.
+func matchingParen(text string, open int) int {
+	depth := 0
+	for i := open; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits a base-class list on the commas outside template
+// arguments.
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range list {
+		switch r {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, list[start:])
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -30,9 +30,16 @@ import "list"
 			...
 			#meta: MetaType: "scheduler"
 		}
+		#meta: functions: ["PrepareNextState", "StartNextStateExecution", "StopCurrentStateExecution"]
 		...
 	}
 	Message: {
+		Destination!: string
+		Function!:    string
+		Mode?:        "ExpectsReply" | "ExpectsIndirectReply"
+		...
+	}
+	ConfigurationDatabase: {
 		...
 	}
 	StateMachineEvent: {
@@ -87,6 +94,9 @@ import "list"
 	ReferenceContainer: {
 		...
 	}
+	IntrospectionStructure: {
+		...
+	}
 	ConstantGAM: {
 		...
 		#meta: MetaType: "gam"
@@ -126,12 +136,14 @@ import "list"
 		#meta: multithreaded: bool | *false
 		#meta: direction:     "IN"
 		#meta: MetaType:      "datasource"
+		#meta: Signals:       [_]: #EPICSCASignal
 		...
 	}
 	EPICSCAOutput: {
 		#meta: multithreaded: bool | *false
 		#meta: direction:     "OUT"
 		#meta: MetaType:      "datasource"
+		#meta: Signals:       [_]: #EPICSCASignal
 		...
 	}
 	EPICSPVAInput: {
@@ -223,6 +235,12 @@ import "list"
 		InputSignals: {...}
 		OutputSignals: {...}
 		#meta: MetaType: "gam"
+		#meta: InputSignals: [_]: Type:  #NumericType
+		#meta: OutputSignals: [_]: Type: #NumericType
+		#meta: SignalPairs: {
+			Input: _
+			Output: NumberOfElements: Input.NumberOfElements
+		}
 		...
 	}
 	DoubleHandshakeGAM: {
@@ -322,6 +340,11 @@ import "list"
 		#meta: multithreaded: bool | *false
 		#meta: direction:     "IN"
 		#meta: MetaType:      "datasource"
+		#meta: Signals: {
+			Counter?: Type: "uint32" | "uint64"
+			Time?:    Type: "uint32" | "uint64"
+			...
+		}
 		...
 	}
 	LinkDataSource: {
@@ -430,7 +453,12 @@ import "list"
 		#meta: MetaType:      "datasource"
 		...
 	}
-	RealTimeThreadSynchronisation: {...}
+	RealTimeThreadSynchronisation: {
+		#meta: direction:     "INOUT"
+		#meta: multithreaded: bool | *true
+		#meta: MetaType:      "datasource"
+		...
+	}
 	UARTDataSource: {
 		#meta: multithreaded: bool | *false
 		#meta: direction:     "INOUT"
@@ -440,7 +468,12 @@ import "list"
 	BaseLib2Wrapper: {...}
 	EPICSCAClient: {...}
 	EPICSPVA: {...}
-	MemoryGate: {...}
+	MemoryGate: {
+		#meta: direction:     "INOUT"
+		#meta: multithreaded: bool | *true
+		#meta: MetaType:      "datasource"
+		...
+	}
 	OPCUA: {...}
 	SysLogger: {...}
 	GAMDataSource: {
@@ -451,11 +484,130 @@ import "list"
 	}
 }
 
+// Signal rules. A class declares, in its #meta, the signals it accepts:
+// Signals for the signals of a DataSource, InputSignals and OutputSignals for
+// those of a GAM, keyed by signal name and closed unless they end with '...'.
+// Each signal is checked with its Type, NumberOfElements and
+// NumberOfDimensions, resolved through its DataSource. SignalPairs checks
+// each input of a GAM, as Input, with the output at the same position, as
+// Output.
+#NumericType: "uint8" | "int8" | "uint16" | "int16" | "uint32" | "int32" | "uint64" | "int64" | "float32" | "float64"
+
+// Channel Access carries strings as DBR_STRING, 40 characters long.
+#EPICSCASignal: {
+	Type?: string
+	if Type == "char8" {
+		NumberOfElements: 40
+	}
+	...
+}
+
+// User-defined signal types. A struct lists its members in order; an enum
+// gives the integer type that stores it and its labels.
+#Types: [string]: {
+	Fields?: [string]: {
+		Type!:            string
+		NumberOfElements: int | *1
+	}
+	Type?:   "uint8" | "int8" | "uint16" | "int16" | "uint32" | "int32" | "uint64" | "int64"
+	Values?: [...string]
+}
+
+// Naming and style rules. Each entry of naming is a regular expression the
+// names of that kind of object must match; maxLength bounds every name and
+// plusDefinitions asks for '+' rather than '$' outside Functions and Data.
+// No rule applies unless a project sets it.
+#Lint: {
+	naming?: [#LintKind]: string
+	maxLength?:       int & >0
+	plusDefinitions?: bool
+}
+
+#LintKind: "gam" | "datasource" | "signal" | "state" | "thread" | "variable" | "template"
+
+// MARTe release the project targets. The bundled schema of that release
+// (see mdt check --marte-version) is layered over this one.
+#Target: version?: string
+
+// Classes and class parameters missing from the targeted release.
+#Release: {
+	name?: string
+	unavailable: {
+		classes: [...string]
+		fields: [string]: [...string]
+	}
+}
+
+// Project rules over the whole application. The validator fills graph with
+// the export below; violations lists the paths of the offending objects
+// (e.g. "App.Functions.Copy.InputSignals.Time"), optionally with a message
+// of their own.
+#Rules: [string]: {
+	graph:   #Graph
+	message: string
+	level:   *"error" | "warning"
+	violations: [...(string | {path: string, message?: string})]
+	...
+}
+
+// Normalized export of the applications, keyed by object path.
+#Graph: {
+	gams: [string]: {
+		path:  string
+		name:  string
+		class: string
+		threads: [...string]
+		inputs: [string]:  #GraphGAMSignal
+		outputs: [string]: #GraphGAMSignal
+	}
+	datasources: [string]: {
+		path:  string
+		name:  string
+		class: string
+		signals: [string]: {
+			path:     string
+			name:     string
+			type?:    string
+			elements: int
+			producers: [...#GraphEndpoint]
+			consumers: [...#GraphEndpoint]
+		}
+	}
+	threads: [string]: {
+		path:  string
+		name:  string
+		state: string
+		gams: [...string]
+	}
+	states: [string]: {
+		path: string
+		name: string
+		threads: [...string]
+	}
+}
+
+#GraphGAMSignal: {
+	path:        string
+	name:        string
+	datasource:  string
+	signal:      string
+	type?:       string
+	elements:    int
+}
+
+#GraphEndpoint: {
+	gam:    string
+	signal: string
+	threads: [...string]
+}
+
 #Meta: {
 	direction?:     "IN" | "OUT" | "INOUT"
 	multithreaded?: bool
 	MetaType?:      string
 	type?:          string // Keep for backward compatibility
+	functions?:     [...string] // Functions that Messages may call on the object
+	cost_ns?:       number | string // Execution time model, e.g. "2000 + 5*elements"
 	Parent?: {
 		Name?:     string
 		Class?:    string
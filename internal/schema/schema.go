@@ -1,10 +1,12 @@
 package schema
 
 import (
-	_ "embed"
+	"embed"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/cuecontext"
@@ -13,9 +15,49 @@ import (
 //go:embed marte.cue
 var defaultSchemaCUE []byte
 
+// versionFS holds the schema layers of the supported MARTe releases, one
+// file per version.
+//
+//go:embed versions/*.cue
+var versionFS embed.FS
+
 type Schema struct {
 	Context *cue.Context
 	Value   cue.Value
+	// Version is the MARTe release targeted, whether or not it is bundled.
+	Version string
+	// Layers are the schema files found by LoadFullSchema, in merge order.
+	Layers []Layer
+}
+
+// Layer is a schema file merged into the full schema.
+type Layer struct {
+	// Name is the path of the file; bundled files are named after their
+	// path in the mdt sources.
+	Name  string
+	Value cue.Value
+	// Err is the reason the layer was left out of the merge.
+	Err error
+}
+
+// BuiltinLayer is the name of the layer of the embedded schema.
+const BuiltinLayer = "internal/schema/marte.cue"
+
+// Versions lists the MARTe releases with a bundled schema.
+func Versions() []string {
+	entries, _ := versionFS.ReadDir("versions")
+	var versions []string
+	for _, e := range entries {
+		versions = append(versions, strings.TrimSuffix(e.Name(), ".cue"))
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// HasVersion tells whether a schema is bundled for a MARTe release.
+func HasVersion(version string) bool {
+	_, err := versionFS.ReadFile("versions/" + version + ".cue")
+	return err == nil
 }
 
 func NewSchema() *Schema {
@@ -32,18 +74,61 @@ func LoadSchema(ctx *cue.Context, path string) (cue.Value, error) {
 	if err != nil {
 		return cue.Value{}, err
 	}
-	return ctx.CompileBytes(content), nil
+	return ctx.CompileBytes(content, cue.Filename(path)), nil
 }
 
-func LoadFullSchema(projectRoot string) *Schema {
+// Option configures LoadFullSchema.
+type Option func(*loadOptions)
+
+type loadOptions struct {
+	version string
+}
+
+// WithVersion selects the release schema layered by LoadFullSchema,
+// overriding the #Target of the project schema. An empty version keeps the
+// #Target.
+func WithVersion(version string) Option {
+	return func(o *loadOptions) {
+		o.version = version
+	}
+}
+
+func LoadFullSchema(projectRoot string, opts ...Option) *Schema {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	ctx := cuecontext.New()
 	baseVal := ctx.CompileBytes(defaultSchemaCUE)
 	if baseVal.Err() != nil {
 		// Fallback or panic? Panic is appropriate for embedded schema failure
 		panic(fmt.Sprintf("Embedded schema invalid: %v", baseVal.Err()))
 	}
+	layers := []Layer{{Name: BuiltinLayer, Value: baseVal}}
 
-	// 1. System Paths
+	// The project schema is read first for the release it targets, but
+	// layered last so that it can override the release.
+	var project *Layer
+	if projectRoot != "" {
+		project = loadLayer(ctx, filepath.Join(projectRoot, ".marte_schema.cue"))
+	}
+	version := o.version
+	if version == "" && project != nil && project.Err == nil {
+		version, _ = project.Value.LookupPath(cue.ParsePath("#Target.version")).String()
+	}
+
+	// 1. Release
+	if content, err := versionFS.ReadFile("versions/" + version + ".cue"); err == nil && version != "" {
+		name := "internal/schema/versions/" + version + ".cue"
+		val := ctx.CompileBytes(content, cue.Filename(name))
+		if val.Err() != nil {
+			panic(fmt.Sprintf("Embedded schema %s invalid: %v", version, val.Err()))
+		}
+		layers = append(layers, Layer{Name: name, Value: val})
+	}
+
+	// 2. System Paths
 	sysPaths := []string{
 		"/usr/share/mdt/marte_schema.cue",
 	}
@@ -54,21 +139,54 @@ func LoadFullSchema(projectRoot string) *Schema {
 	}
 
 	for _, path := range sysPaths {
-		if val, err := LoadSchema(ctx, path); err == nil && val.Err() == nil {
-			baseVal = baseVal.Unify(val)
+		if layer := loadLayer(ctx, path); layer != nil {
+			layers = append(layers, *layer)
 		}
 	}
 
-	// 2. Project Path
-	if projectRoot != "" {
-		projectSchemaPath := filepath.Join(projectRoot, ".marte_schema.cue")
-		if val, err := LoadSchema(ctx, projectSchemaPath); err == nil && val.Err() == nil {
-			baseVal = baseVal.Unify(val)
+	// 3. Project Path
+	if project != nil {
+		layers = append(layers, *project)
+	}
+
+	for _, layer := range layers[1:] {
+		if layer.Err == nil {
+			baseVal = baseVal.Unify(layer.Value)
 		}
 	}
 
 	return &Schema{
 		Context: ctx,
 		Value:   baseVal,
+		Version: version,
+		Layers:  layers,
+	}
+}
+
+// loadLayer compiles the schema file at path; it returns nil when there is
+// no such file.
+func loadLayer(ctx *cue.Context, path string) *Layer {
+	val, err := LoadSchema(ctx, path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err == nil {
+		err = val.Err()
+	}
+	return &Layer{Name: path, Value: val, Err: err}
+}
+
+// Errors returns the errors of the layers left out of the merge and the
+// conflicts between the merged layers.
+func (s *Schema) Errors() []error {
+	var errs []error
+	for _, layer := range s.Layers {
+		if layer.Err != nil {
+			errs = append(errs, layer.Err)
+		}
+	}
+	if err := s.Value.Validate(); err != nil {
+		errs = append(errs, err)
 	}
+	return errs
 }
@@ -0,0 +1,20 @@
+// MARTe2 / MARTe2-components v1.3.
+//
+// Differences with the built-in schema, taken from the component sources at
+// the v1.3.0 tag of MARTe2-components
+// (https://github.com/aneto0/MARTe2-components):
+//   - the multi-thread bridges (RealTimeThreadAsyncBridge and its MemoryGate)
+//     and MathExpressionGAM came in later releases;
+//   - LinuxTimer reads neither a TimeProvider object nor Phase;
+//   - UDPSender always runs in the real-time thread, so it has neither
+//     ExecutionMode nor the parameters of its independent thread.
+#Release: {
+	name: "MARTe2-components v1.3"
+	unavailable: {
+		classes: ["RealTimeThreadAsyncBridge", "MemoryGate", "MathExpressionGAM"]
+		fields: {
+			LinuxTimer: ["TimeProvider", "Phase"]
+			UDPSender: ["ExecutionMode", "NumberOfPreTriggers", "NumberOfPostTriggers", "CPUMask", "StackSize"]
+		}
+	}
+}
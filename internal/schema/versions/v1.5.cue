@@ -0,0 +1,10 @@
+// MARTe2 / MARTe2-components v1.5.
+//
+// Differences with the built-in schema, taken from the component sources at
+// the v1.5.0 tag of MARTe2-components
+// (https://github.com/aneto0/MARTe2-components): LinuxTimer already takes
+// its clock from a TimeProvider but does not read Phase yet.
+#Release: {
+	name: "MARTe2-components v1.5"
+	unavailable: fields: LinuxTimer: ["Phase"]
+}
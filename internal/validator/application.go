@@ -0,0 +1,139 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// syncFields are the input signal annotations that make a GAM wait on its
+// DataSource, synchronising the thread that runs it.
+var syncFields = []string{"Frequency", "Trigger"}
+
+// syncInput is an input signal that synchronises a thread.
+type syncInput struct {
+	gam    *index.ProjectNode
+	signal *index.ProjectNode
+	field  index.EvaluatedField
+}
+
+// CheckRealTimeApplications checks the Scheduler of every RealTimeApplication,
+// the synchronisation of the threads of each of its states and the signals
+// they exchange.
+func (v *Validator) CheckRealTimeApplications(ctx context.Context) {
+	var apps []*index.ProjectNode
+	v.Tree.Walk(func(n *index.ProjectNode) {
+		if v.isActive(n) && v.getNodeClass(n) == "RealTimeApplication" {
+			apps = append(apps, n)
+		}
+	})
+	for _, app := range apps {
+		if ctx.Err() != nil {
+			return
+		}
+		v.checkScheduler(app)
+		v.checkThreadSync(app)
+		v.checkCrossThreadFlow(app)
+	}
+}
+
+// checkScheduler reports a Scheduler whose TimingDataSource does not name a
+// TimingDataSource object.
+func (v *Validator) checkScheduler(app *index.ProjectNode) {
+	sched := app.Children["Scheduler"]
+	if sched == nil {
+		return
+	}
+	fs := sched.Fields["TimingDataSource"]
+	if len(fs) == 0 {
+		// The schema reports the missing field.
+		return
+	}
+	f := fs[len(fs)-1]
+	name, pos := v.nameValue(f, sched)
+	if name == "" {
+		return
+	}
+	target := v.resolveReference(name, sched, nil)
+	if target == nil {
+		// Bare names are reported as unknown references already.
+		if _, isRef := f.Value.(*parser.ReferenceValue); !isRef {
+			v.report(sched, "invalid_timing_datasource", LevelError,
+				fmt.Sprintf("TimingDataSource '%s' of '%s' does not exist", name, app.Name),
+				pos, f.File)
+		}
+		return
+	}
+	if cls := v.getNodeClass(target); cls != "TimingDataSource" {
+		if cls == "" {
+			cls = "an object without Class"
+		}
+		v.report(sched, "invalid_timing_datasource", LevelError,
+			fmt.Sprintf("TimingDataSource '%s' of '%s' must be a TimingDataSource, not %s", name, app.Name, cls),
+			pos, f.File)
+	}
+}
+
+// checkThreadSync reports threads without a synchronising input, threads
+// with more than one, and threads whose first GAM does not synchronise.
+// MARTe refuses to start an application with two synchronising inputs in
+// the same thread.
+func (v *Validator) checkThreadSync(app *index.ProjectNode) {
+	reported := make(map[*index.ProjectNode]bool)
+	for _, state := range v.Tree.ApplicationStates(app) {
+		for _, thread := range v.Tree.StateThreads(state) {
+			gams := v.getThreadGAMs(thread)
+			if len(gams) == 0 {
+				continue
+			}
+			var syncs []syncInput
+			for _, gam := range gams {
+				syncs = append(syncs, v.gamSyncInputs(gam)...)
+			}
+			if len(syncs) == 0 {
+				v.report(thread, "missing_thread_sync", LevelWarning,
+					fmt.Sprintf("Thread '%s' of state '%s' has no synchronising input (an input signal with Frequency or Trigger)", thread.Name, state.Name),
+					v.getNodePosition(thread), v.getNodeFile(thread))
+				continue
+			}
+			first := syncs[0]
+			for _, s := range syncs[1:] {
+				if reported[s.signal] {
+					continue
+				}
+				reported[s.signal] = true
+				v.report(s.signal, "multiple_thread_sync", LevelError,
+					fmt.Sprintf("Signal '%s' of GAM '%s' is a second synchronising input of thread '%s' in state '%s' (first: '%s' of GAM '%s')",
+						s.signal.Name, s.gam.Name, thread.Name, state.Name, first.signal.Name, first.gam.Name),
+					s.field.Raw.Position, s.field.File)
+			}
+			if first.gam != gams[0] {
+				v.report(thread, "late_thread_sync", LevelWarning,
+					fmt.Sprintf("Thread '%s' of state '%s' synchronises on GAM '%s' but starts with GAM '%s'", thread.Name, state.Name, first.gam.Name, gams[0].Name),
+					v.getNodePosition(thread), v.getNodeFile(thread))
+			}
+		}
+	}
+}
+
+// gamSyncInputs returns the input signals of gam annotated with Frequency or
+// Trigger, in declaration order.
+func (v *Validator) gamSyncInputs(gam *index.ProjectNode) []syncInput {
+	inputs := gam.Children["InputSignals"]
+	if inputs == nil {
+		return nil
+	}
+	var syncs []syncInput
+	for _, sig := range v.Tree.OrderedChildren(inputs) {
+		fields := v.getFields(sig)
+		for _, name := range syncFields {
+			if fs := fields[name]; len(fs) > 0 {
+				syncs = append(syncs, syncInput{gam: gam, signal: sig, field: fs[0]})
+				break
+			}
+		}
+	}
+	return syncs
+}
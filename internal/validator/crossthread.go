@@ -0,0 +1,200 @@
+package validator
+
+import (
+	"fmt"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+)
+
+// bridgeClasses are the DataSources that pass signals between the threads of
+// a state.
+var bridgeClasses = []string{"RealTimeThreadAsyncBridge", "RealTimeThreadSynchronisation", "MemoryGate"}
+
+// syncBridgeClass is the bridge whose readers wait for its writer: a reader
+// thread runs once every Samples cycles of the writer thread.
+const syncBridgeClass = "RealTimeThreadSynchronisation"
+
+// flowEndpoint is a GAM signal that writes to or reads from a bridge.
+type flowEndpoint struct {
+	thread  *index.ProjectNode
+	gam     *index.ProjectNode
+	signal  *index.ProjectNode
+	samples int64
+}
+
+// flowIssue identifies a reported signal so that signals used in several
+// states are reported once.
+type flowIssue struct {
+	signal *index.ProjectNode
+	tag    string
+}
+
+// flowReporter reports a diagnostic on a bridge signal.
+type flowReporter func(sig *index.ProjectNode, tag string, level DiagnosticLevel, msg string)
+
+// signalFlow is the producers and consumers of one signal of a bridge.
+type signalFlow struct {
+	ds      *index.ProjectNode
+	class   string
+	name    string
+	writers []flowEndpoint
+	readers []flowEndpoint
+}
+
+// checkCrossThreadFlow builds the producer/consumer graph of the bridge
+// signals of each state and checks that every signal has exactly one
+// writer, that readers and writer agree on its size and that the threads at
+// both ends run at compatible rates.
+func (v *Validator) checkCrossThreadFlow(app *index.ProjectNode) {
+	reported := make(map[flowIssue]bool)
+	var report flowReporter = func(sig *index.ProjectNode, tag string, level DiagnosticLevel, msg string) {
+		if !reported[flowIssue{sig, tag}] {
+			reported[flowIssue{sig, tag}] = true
+			v.report(sig, tag, level, msg, v.getNodePosition(sig), v.getNodeFile(sig))
+		}
+	}
+	for _, state := range v.Tree.ApplicationStates(app) {
+		flows := v.stateFlows(state)
+		if len(flows) == 0 {
+			continue
+		}
+
+		// Threads synchronised by a RealTimeThreadSynchronisation run at
+		// the rate of its writer divided by the samples of the first
+		// signal they read from it.
+		freqs := make(map[*index.ProjectNode]float64)
+		for _, thread := range v.Tree.StateThreads(state) {
+			freqs[thread] = v.threadFrequency(thread)
+		}
+		synced := make(map[*index.ProjectNode]bool)
+		for _, flow := range flows {
+			if flow.class != syncBridgeClass || len(flow.writers) == 0 {
+				continue
+			}
+			w := flow.writers[0]
+			for _, r := range flow.readers {
+				if r.thread != w.thread && !synced[r.thread] && freqs[w.thread] > 0 {
+					synced[r.thread] = true
+					freqs[r.thread] = freqs[w.thread] / float64(r.samples)
+				}
+			}
+		}
+
+		firstRead := make(map[*index.ProjectNode]map[*index.ProjectNode]flowEndpoint) // DS -> thread -> first reader
+		for _, flow := range flows {
+			v.checkSignalFlow(flow, state, freqs, report)
+			if flow.class != syncBridgeClass {
+				continue
+			}
+			if firstRead[flow.ds] == nil {
+				firstRead[flow.ds] = make(map[*index.ProjectNode]flowEndpoint)
+			}
+			for _, r := range flow.readers {
+				first, ok := firstRead[flow.ds][r.thread]
+				if !ok {
+					firstRead[flow.ds][r.thread] = r
+					continue
+				}
+				if r.samples != first.samples {
+					report(r.signal, "rate_mismatch", LevelWarning,
+						fmt.Sprintf("Thread '%s' reads signal '%s' of %s '%s' with %d samples per cycle but signal '%s' with %d",
+							r.thread.Name, r.signal.Name, flow.class, flow.ds.Name, r.samples, first.signal.Name, first.samples))
+				}
+			}
+		}
+	}
+}
+
+// stateFlows collects the bridge signals written and read by the GAMs of the
+// threads of state, in declaration order.
+func (v *Validator) stateFlows(state *index.ProjectNode) []*signalFlow {
+	var flows []*signalFlow
+	byKey := make(map[*index.ProjectNode]map[string]*signalFlow)
+	for _, thread := range v.Tree.StateThreads(state) {
+		for _, gam := range v.getThreadGAMs(thread) {
+			for _, dir := range []string{"InputSignals", "OutputSignals"} {
+				container := gam.Children[dir]
+				if container == nil {
+					continue
+				}
+				for _, sig := range v.Tree.OrderedChildren(container) {
+					ds, name := v.Tree.GetSignalInfo(sig)
+					if ds == nil || !v.isActive(ds) {
+						continue
+					}
+					cls := v.getNodeClass(ds)
+					if !containsString(bridgeClasses, cls) {
+						continue
+					}
+					name = index.NormalizeName(name)
+					if byKey[ds] == nil {
+						byKey[ds] = make(map[string]*signalFlow)
+					}
+					flow := byKey[ds][name]
+					if flow == nil {
+						flow = &signalFlow{ds: ds, class: cls, name: name}
+						byKey[ds][name] = flow
+						flows = append(flows, flow)
+					}
+					end := flowEndpoint{thread: thread, gam: gam, signal: sig, samples: v.signalSamples(sig)}
+					if dir == "InputSignals" {
+						flow.readers = append(flow.readers, end)
+					} else {
+						flow.writers = append(flow.writers, end)
+					}
+				}
+			}
+		}
+	}
+	return flows
+}
+
+func (v *Validator) checkSignalFlow(flow *signalFlow, state *index.ProjectNode, freqs map[*index.ProjectNode]float64, report flowReporter) {
+	if len(flow.writers) == 0 {
+		for _, r := range flow.readers {
+			report(r.signal, "missing_producer", LevelError,
+				fmt.Sprintf("Signal '%s' of %s '%s' is read by GAM '%s' in thread '%s' of state '%s' but no thread writes it",
+					flow.name, flow.class, flow.ds.Name, r.gam.Name, r.thread.Name, state.Name))
+		}
+		return
+	}
+	w := flow.writers[0]
+	for _, other := range flow.writers[1:] {
+		report(other.signal, "multiple_writers", LevelError,
+			fmt.Sprintf("Signal '%s' of %s '%s' is written by GAM '%s' in thread '%s' and by GAM '%s' in thread '%s' of state '%s'; it allows one writer",
+				flow.name, flow.class, flow.ds.Name, other.gam.Name, other.thread.Name, w.gam.Name, w.thread.Name, state.Name))
+	}
+
+	// Sizes are compared per sample; Ranges read part of a signal on purpose.
+	wSize := v.getSignalByteSize(w.signal) / w.samples
+	for _, r := range flow.readers {
+		if _, ranged := v.getFields(r.signal)["Ranges"]; !ranged && wSize > 0 {
+			if rSize := v.getSignalByteSize(r.signal) / r.samples; rSize > 0 && rSize != wSize {
+				report(r.signal, "bridge_size_mismatch", LevelError,
+					fmt.Sprintf("Signal '%s' of %s '%s' is %d bytes in GAM '%s' but %d bytes in GAM '%s' that writes it",
+						flow.name, flow.class, flow.ds.Name, rSize, r.gam.Name, wSize, w.gam.Name))
+			}
+		}
+
+		// An asynchronous reader running faster than the writer reads the
+		// same values more than once.
+		if flow.class == syncBridgeClass || r.thread == w.thread {
+			continue
+		}
+		if rf, wf := freqs[r.thread], freqs[w.thread]; rf > 0 && wf > 0 && rf > wf {
+			report(r.signal, "rate_mismatch", LevelWarning,
+				fmt.Sprintf("Thread '%s' reads signal '%s' of %s '%s' at %g Hz but thread '%s' writes it at %g Hz",
+					r.thread.Name, flow.name, flow.class, flow.ds.Name, rf, w.thread.Name, wf))
+		}
+	}
+}
+
+// signalSamples returns the Samples of a GAM signal, 1 by default.
+func (v *Validator) signalSamples(sig *index.ProjectNode) int64 {
+	if fs := v.getFields(sig)["Samples"]; len(fs) > 0 {
+		if n, ok := toInt64(v.ValueToInterface(fs[0].Value, sig)); ok && n > 0 {
+			return n
+		}
+	}
+	return 1
+}
@@ -0,0 +1,174 @@
+package validator
+
+import (
+	"sort"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+)
+
+// MemoryUsage is the memory attributed to one DataSource, GAM, thread or
+// state.
+type MemoryUsage struct {
+	Kind    string // "DataSource", "GAM", "Thread" or "State"
+	Name    string // qualified with the application and state for threads and states
+	Class   string
+	Node    *index.ProjectNode
+	Signals int   // number of signals counted
+	Buffers int64 // NumberOfBuffers multiplier of a DataSource; 1 otherwise
+	Bytes   int64
+}
+
+// Footprint aggregates the memory used by the signals of a project.
+type Footprint struct {
+	DataSources []MemoryUsage
+	GAMs        []MemoryUsage
+	Threads     []MemoryUsage
+	States      []MemoryUsage
+	Total       int64 // all DataSources and GAMs
+}
+
+// Usage returns the memory attributed to node.
+func (f *Footprint) Usage(node *index.ProjectNode) (MemoryUsage, bool) {
+	for _, list := range [][]MemoryUsage{f.DataSources, f.GAMs, f.Threads, f.States} {
+		for _, u := range list {
+			if u.Node == node {
+				return u, true
+			}
+		}
+	}
+	return MemoryUsage{}, false
+}
+
+// Footprint computes the memory of every active DataSource and GAM from the
+// byte size of their signals. A DataSource holds each signal once, whether
+// it is declared under Signals or only used by GAMs, times its
+// NumberOfBuffers. A GAM holds a copy of each of its input and output
+// signals. Threads add up their GAMs; states add up the GAMs of their
+// threads and the DataSources those GAMs use.
+func (v *Validator) Footprint() *Footprint {
+	f := &Footprint{}
+	dsUsage := make(map[*index.ProjectNode]*MemoryUsage)
+	gamUsage := make(map[*index.ProjectNode]*MemoryUsage)
+	gamSources := make(map[*index.ProjectNode][]*index.ProjectNode)
+	seenSignals := make(map[*index.ProjectNode]map[string]bool)
+
+	var dataSources, gams []*index.ProjectNode
+	v.Tree.Walk(func(n *index.ProjectNode) {
+		if !v.isActive(n) || v.getNodeClass(n) == "" {
+			return
+		}
+		if v.Tree.IsDataSource(n) {
+			dataSources = append(dataSources, n)
+		} else if v.Tree.IsGAM(n) && (n.Children["InputSignals"] != nil || n.Children["OutputSignals"] != nil) {
+			gams = append(gams, n)
+		}
+	})
+	byName := func(nodes []*index.ProjectNode) {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	}
+	byName(dataSources)
+	byName(gams)
+
+	for _, ds := range dataSources {
+		u := &MemoryUsage{Kind: "DataSource", Name: ds.Name, Class: v.getNodeClass(ds), Node: ds, Buffers: 1}
+		if fs := v.getFields(ds)["NumberOfBuffers"]; len(fs) > 0 {
+			if n, ok := toInt64(v.ValueToInterface(fs[0].Value, ds)); ok && n > 0 {
+				u.Buffers = n
+			}
+		}
+		seenSignals[ds] = make(map[string]bool)
+		if signals := ds.Children["Signals"]; signals != nil {
+			for _, sig := range signals.Children {
+				seenSignals[ds][sig.Name] = true
+				u.Signals++
+				u.Bytes += v.getSignalByteSize(sig)
+			}
+		}
+		dsUsage[ds] = u
+	}
+
+	for _, gam := range gams {
+		u := &MemoryUsage{Kind: "GAM", Name: gam.Name, Class: v.getNodeClass(gam), Node: gam, Buffers: 1}
+		for _, dir := range []string{"InputSignals", "OutputSignals"} {
+			container := gam.Children[dir]
+			if container == nil {
+				continue
+			}
+			for _, sig := range container.Children {
+				size := v.getSignalByteSize(sig)
+				u.Signals++
+				u.Bytes += size
+				ds, name := v.Tree.GetSignalInfo(sig)
+				dsu := dsUsage[ds]
+				if dsu == nil {
+					continue
+				}
+				if !containsNode(gamSources[gam], ds) {
+					gamSources[gam] = append(gamSources[gam], ds)
+				}
+				// Implicit signals take memory in the DataSource too.
+				if name = index.NormalizeName(name); !seenSignals[ds][name] {
+					seenSignals[ds][name] = true
+					dsu.Signals++
+					dsu.Bytes += size
+				}
+			}
+		}
+		gamUsage[gam] = u
+	}
+
+	for _, ds := range dataSources {
+		u := dsUsage[ds]
+		u.Bytes *= u.Buffers
+		f.DataSources = append(f.DataSources, *u)
+		f.Total += u.Bytes
+	}
+	for _, gam := range gams {
+		f.GAMs = append(f.GAMs, *gamUsage[gam])
+		f.Total += gamUsage[gam].Bytes
+	}
+
+	v.Tree.Walk(func(app *index.ProjectNode) {
+		if !v.isActive(app) || v.getNodeClass(app) != "RealTimeApplication" {
+			return
+		}
+		for _, state := range v.Tree.ApplicationStates(app) {
+			su := MemoryUsage{Kind: "State", Name: app.Name + "." + state.Name, Class: v.getNodeClass(state), Node: state, Buffers: 1}
+			var stateGAMs, stateSources []*index.ProjectNode
+			for _, thread := range v.Tree.StateThreads(state) {
+				tu := MemoryUsage{Kind: "Thread", Name: su.Name + "." + thread.Name, Class: v.getNodeClass(thread), Node: thread, Buffers: 1}
+				for _, gam := range v.getThreadGAMs(thread) {
+					gu := gamUsage[gam]
+					if gu == nil {
+						continue
+					}
+					tu.Signals += gu.Signals
+					tu.Bytes += gu.Bytes
+					if !containsNode(stateGAMs, gam) {
+						stateGAMs = append(stateGAMs, gam)
+						su.Signals += gu.Signals
+						su.Bytes += gu.Bytes
+					}
+					for _, ds := range gamSources[gam] {
+						if !containsNode(stateSources, ds) {
+							stateSources = append(stateSources, ds)
+							su.Bytes += dsUsage[ds].Bytes
+						}
+					}
+				}
+				f.Threads = append(f.Threads, tu)
+			}
+			f.States = append(f.States, su)
+		}
+	})
+	return f
+}
+
+func containsNode(nodes []*index.ProjectNode, n *index.ProjectNode) bool {
+	for _, m := range nodes {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// lintKindNames names the kinds of object of the #Lint naming rules in
+// messages.
+var lintKindNames = map[string]string{
+	"gam":        "GAM",
+	"datasource": "DataSource",
+	"signal":     "Signal",
+	"state":      "State",
+	"thread":     "Thread",
+	"variable":   "Variable",
+	"template":   "Template",
+}
+
+// lintRules are the naming and style rules of the #Lint section of the
+// schema.
+type lintRules struct {
+	naming          map[string]*regexp.Regexp
+	maxLength       int
+	plusDefinitions bool
+}
+
+// lintRules reads the #Lint section of the schema. Patterns that are not
+// valid regular expressions are reported and ignored; nil means no rule is
+// set.
+func (v *Validator) lintRules() *lintRules {
+	if v.Schema == nil {
+		return nil
+	}
+	lint := v.Schema.Value.LookupPath(cue.ParsePath("#Lint"))
+	if lint.Err() != nil {
+		return nil
+	}
+	rules := &lintRules{naming: make(map[string]*regexp.Regexp)}
+	if iter, err := lint.LookupPath(cue.ParsePath("naming")).Fields(); err == nil {
+		for iter.Next() {
+			pattern, err := iter.Value().String()
+			if err != nil {
+				continue
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				pos, file := schemaPosition(iter.Value())
+				v.report(nil, "invalid_rule", LevelError,
+					fmt.Sprintf("Naming rule '%s' is not a valid regular expression: %v", iter.Selector().Unquoted(), err), pos, file)
+				continue
+			}
+			rules.naming[iter.Selector().Unquoted()] = re
+		}
+	}
+	if n, err := lint.LookupPath(cue.ParsePath("maxLength")).Int64(); err == nil {
+		rules.maxLength = int(n)
+	}
+	rules.plusDefinitions, _ = lint.LookupPath(cue.ParsePath("plusDefinitions")).Bool()
+	if len(rules.naming) == 0 && rules.maxLength == 0 && !rules.plusDefinitions {
+		return nil
+	}
+	return rules
+}
+
+// CheckNaming reports the names of GAMs, DataSources, signals, states,
+// threads, variables and templates that break the naming rules of the
+// schema, and objects defined with '$' outside Functions and Data when the
+// rules ask for '+'.
+func (v *Validator) CheckNaming(ctx context.Context) {
+	rules := v.lintRules()
+	if rules == nil {
+		return
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if ctx.Err() != nil || !v.isActive(node) {
+			return
+		}
+		if kind := v.lintKind(node); kind != "" {
+			v.checkName(rules, kind, node.Name, node, v.getNodePosition(node), v.getNodeFile(node))
+		}
+		if rules.plusDefinitions && strings.HasPrefix(node.RealName, "$") && !inFunctionsOrData(node) {
+			v.report(node, "definition_prefix", LevelWarning,
+				fmt.Sprintf("Object '%s' is defined with '$' outside Functions and Data; define it as '+%s'", node.RealName, node.Name),
+				v.getNodePosition(node), v.getNodeFile(node))
+		}
+		for _, frag := range node.Fragments {
+			v.muActive.Lock()
+			active := v.ActiveFragments[frag]
+			v.muActive.Unlock()
+			if !active {
+				continue
+			}
+			for _, def := range frag.Definitions {
+				if vdef, ok := def.(*parser.VariableDefinition); ok {
+					v.checkName(rules, "variable", vdef.Name, node, vdef.Position, frag.File)
+				}
+			}
+		}
+	})
+
+	names := make([]string, 0, len(v.Tree.Templates))
+	for name := range v.Tree.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.checkName(rules, "template", name, nil, v.Tree.Templates[name].Position, v.Tree.TemplateFiles[name])
+	}
+}
+
+// checkName reports a name of the given kind that does not match its rule,
+// with a rename suggestion when one can be derived from the rule, or that
+// is longer than maxLength.
+func (v *Validator) checkName(rules *lintRules, kind, name string, node *index.ProjectNode, pos parser.Position, file string) {
+	label := lintKindNames[kind]
+	if re := rules.naming[kind]; re != nil && !re.MatchString(name) {
+		msg := fmt.Sprintf("%s name '%s' does not match the naming rule '%s'", label, name, re.String())
+		if suggestion := suggestName(name, re, rules.maxLength); suggestion != "" {
+			msg += fmt.Sprintf("; rename to '%s'", suggestion)
+		}
+		v.report(node, "naming_convention", LevelWarning, msg, pos, file)
+	}
+	if rules.maxLength > 0 && len(name) > rules.maxLength {
+		v.report(node, "name_length", LevelWarning,
+			fmt.Sprintf("%s name '%s' is %d characters long; names are limited to %d", label, name, len(name), rules.maxLength),
+			pos, file)
+	}
+}
+
+// lintKind returns the kind of object of node for the naming rules, or ""
+// for objects the rules do not cover.
+func (v *Validator) lintKind(node *index.ProjectNode) string {
+	switch {
+	case v.Tree.IsGAM(node):
+		return "gam"
+	case v.Tree.IsSignal(node):
+		return "signal"
+	case node.Parent != nil && (node.Parent.Name == "InputSignals" || node.Parent.Name == "OutputSignals") &&
+		node.Parent.Parent != nil && v.Tree.IsGAM(node.Parent.Parent):
+		return "signal"
+	case strings.HasPrefix(node.RealName, "+") || strings.HasPrefix(node.RealName, "$"):
+		if v.Tree.IsDataSource(node) {
+			return "datasource"
+		}
+	default:
+		return ""
+	}
+	switch v.getNodeClass(node) {
+	case "RealTimeState":
+		return "state"
+	case "RealTimeThread":
+		return "thread"
+	}
+	if node.Parent != nil && v.getNodeClass(node.Parent) == "StateMachine" {
+		return "state"
+	}
+	return ""
+}
+
+// inFunctionsOrData tells whether node is declared inside the Functions or
+// Data container of an application.
+func inFunctionsOrData(node *index.ProjectNode) bool {
+	for p := node.Parent; p != nil; p = p.Parent {
+		if p.Name == "Functions" || p.Name == "Data" {
+			return true
+		}
+	}
+	return false
+}
+
+// suggestName returns a name close to name that matches re: the name, its
+// CamelCase and lowerCamelCase spellings, each with the literal prefix and
+// suffix of re added, are tried in turn. It returns "" when none matches.
+func suggestName(name string, re *regexp.Regexp, maxLength int) string {
+	prefix, suffix := literalAffixes(re)
+	bases := []string{name}
+	if camel := camelCase(name); camel != "" {
+		runes := []rune(camel)
+		runes[0] = unicode.ToLower(runes[0])
+		bases = append(bases, camel, string(runes))
+	}
+	for _, base := range bases {
+		if base == "" {
+			continue
+		}
+		for _, p := range []string{"", prefix} {
+			for _, s := range []string{"", suffix} {
+				candidate := base
+				if !strings.HasPrefix(candidate, p) {
+					candidate = p + candidate
+				}
+				if !strings.HasSuffix(candidate, s) {
+					candidate += s
+				}
+				if candidate != name && re.MatchString(candidate) && (maxLength == 0 || len(candidate) <= maxLength) {
+					return candidate
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// literalAffixes returns the literal text an anchored pattern requires at
+// the start and at the end of a match.
+func literalAffixes(re *regexp.Regexp) (prefix, suffix string) {
+	tree, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", ""
+	}
+	tree = tree.Simplify()
+	if tree.Op != syntax.OpConcat || len(tree.Sub) < 2 {
+		return "", ""
+	}
+	subs := tree.Sub
+	literal := func(s *syntax.Regexp) string {
+		if s.Op == syntax.OpLiteral && s.Flags&syntax.FoldCase == 0 {
+			return string(s.Rune)
+		}
+		return ""
+	}
+	if subs[0].Op == syntax.OpBeginText || subs[0].Op == syntax.OpBeginLine {
+		prefix = literal(subs[1])
+	}
+	if last := len(subs) - 1; subs[last].Op == syntax.OpEndText || subs[last].Op == syntax.OpEndLine {
+		suffix = literal(subs[last-1])
+	}
+	return prefix, suffix
+}
+
+// camelCase joins the words of a name separated by '_', '-' or spaces,
+// starting each with an upper case letter. Words written in capitals are
+// lowered first.
+func camelCase(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || r == ' '
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if strings.ToUpper(w) == w {
+			w = strings.ToLower(w)
+		}
+		runes := []rune(w)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
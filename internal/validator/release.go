@@ -0,0 +1,105 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+)
+
+// CheckRelease reports an unknown #Target version and the objects using a
+// class, or a class parameter, that the targeted MARTe release does not
+// provide according to the #Release section of the schema.
+func (v *Validator) CheckRelease(ctx context.Context) {
+	if v.Schema == nil {
+		return
+	}
+	if v.Schema.Version != "" && !schema.HasVersion(v.Schema.Version) {
+		pos, file := schemaPosition(v.Schema.Value.LookupPath(cue.ParsePath("#Target.version")))
+		v.report(nil, "unknown_marte_version", LevelError,
+			fmt.Sprintf("Unknown MARTe version '%s' (bundled: %s)", v.Schema.Version, strings.Join(schema.Versions(), ", ")),
+			pos, file)
+	}
+
+	release := v.Schema.Value.LookupPath(cue.ParsePath("#Release"))
+	name, _ := release.LookupPath(cue.ParsePath("name")).String()
+	if name == "" {
+		name = "the targeted MARTe release"
+	}
+	classes := make(map[string]bool)
+	if list, err := release.LookupPath(cue.ParsePath("unavailable.classes")).List(); err == nil {
+		for list.Next() {
+			if s, err := list.Value().String(); err == nil {
+				classes[s] = true
+			}
+		}
+	}
+	fields := make(map[string][]string)
+	if iter, err := release.LookupPath(cue.ParsePath("unavailable.fields")).Fields(); err == nil {
+		for iter.Next() {
+			list, err := iter.Value().List()
+			if err != nil {
+				continue
+			}
+			for list.Next() {
+				if s, err := list.Value().String(); err == nil {
+					fields[iter.Selector().Unquoted()] = append(fields[iter.Selector().Unquoted()], s)
+				}
+			}
+		}
+	}
+	if len(classes) == 0 && len(fields) == 0 {
+		return
+	}
+
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if ctx.Err() != nil || !v.isActive(node) {
+			return
+		}
+		class := v.getNodeClass(node)
+		if i := strings.LastIndex(class, "::"); i != -1 {
+			class = class[i+2:]
+		}
+		if class == "" {
+			return
+		}
+		if classes[class] {
+			v.report(node, "unavailable_class", LevelError,
+				fmt.Sprintf("Class '%s' is not available in %s", class, name),
+				v.getNodePosition(node), v.getNodeFile(node))
+			return
+		}
+		missing := fields[class]
+		if len(missing) == 0 {
+			return
+		}
+		nodeFields := v.getFields(node)
+		names := make([]string, 0, len(nodeFields))
+		for field := range nodeFields {
+			names = append(names, field)
+		}
+		sort.Strings(names)
+		for _, field := range names {
+			if !containsString(missing, field) {
+				continue
+			}
+			for _, f := range nodeFields[field] {
+				v.report(node, "unavailable_field", LevelError,
+					fmt.Sprintf("Field '%s' of class '%s' is not available in %s", field, class, name),
+					f.Raw.Position, f.File)
+			}
+		}
+		for _, child := range v.Tree.OrderedChildren(node) {
+			if containsString(missing, child.Name) {
+				v.report(child, "unavailable_field", LevelError,
+					fmt.Sprintf("Field '%s' of class '%s' is not available in %s", child.Name, class, name),
+					v.getNodePosition(child), v.getNodeFile(child))
+			}
+		}
+	})
+}
@@ -0,0 +1,363 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// Graph is the normalized export of the applications of a project that the
+// rules of the #Rules section of the schema are evaluated against. Objects
+// are keyed by their path from the root of the project.
+type Graph struct {
+	GAMs        map[string]*GraphGAM        `json:"gams"`
+	DataSources map[string]*GraphDataSource `json:"datasources"`
+	Threads     map[string]*GraphThread     `json:"threads"`
+	States      map[string]*GraphState      `json:"states"`
+}
+
+// GraphGAM is a GAM with its signals and the threads that run it.
+type GraphGAM struct {
+	Path    string                     `json:"path"`
+	Name    string                     `json:"name"`
+	Class   string                     `json:"class"`
+	Threads []string                   `json:"threads"`
+	Inputs  map[string]*GraphGAMSignal `json:"inputs"`
+	Outputs map[string]*GraphGAMSignal `json:"outputs"`
+}
+
+// GraphGAMSignal is an input or output signal of a GAM and the DataSource
+// signal it is connected to.
+type GraphGAMSignal struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	DataSource string `json:"datasource"`
+	Signal     string `json:"signal"`
+	Type       string `json:"type,omitempty"`
+	Elements   int64  `json:"elements"`
+}
+
+// GraphDataSource is a DataSource with its signals, declared or implicit.
+type GraphDataSource struct {
+	Path    string                  `json:"path"`
+	Name    string                  `json:"name"`
+	Class   string                  `json:"class"`
+	Signals map[string]*GraphSignal `json:"signals"`
+}
+
+// GraphSignal is a signal of a DataSource with the GAMs writing and reading
+// it.
+type GraphSignal struct {
+	Path      string          `json:"path"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type,omitempty"`
+	Elements  int64           `json:"elements"`
+	Producers []GraphEndpoint `json:"producers"`
+	Consumers []GraphEndpoint `json:"consumers"`
+}
+
+// GraphEndpoint is a GAM signal writing or reading a DataSource signal.
+type GraphEndpoint struct {
+	GAM     string   `json:"gam"`
+	Signal  string   `json:"signal"`
+	Threads []string `json:"threads"`
+}
+
+// GraphThread is a RealTimeThread and the GAMs it runs in order.
+type GraphThread struct {
+	Path  string   `json:"path"`
+	Name  string   `json:"name"`
+	State string   `json:"state"`
+	GAMs  []string `json:"gams"`
+}
+
+// GraphState is a RealTimeState and its threads.
+type GraphState struct {
+	Path    string   `json:"path"`
+	Name    string   `json:"name"`
+	Threads []string `json:"threads"`
+}
+
+// nodePath returns the dotted path of node from the root of the project.
+func nodePath(node *index.ProjectNode) string {
+	var parts []string
+	for n := node; n != nil && n.Parent != nil; n = n.Parent {
+		parts = append(parts, n.Name)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, ".")
+}
+
+// RuleGraph exports the active GAMs, DataSources, threads and states of the
+// project with the producers and consumers of every DataSource signal.
+func (v *Validator) RuleGraph() *Graph {
+	g := &Graph{
+		GAMs:        make(map[string]*GraphGAM),
+		DataSources: make(map[string]*GraphDataSource),
+		Threads:     make(map[string]*GraphThread),
+		States:      make(map[string]*GraphState),
+	}
+
+	var gams []*index.ProjectNode
+	v.Tree.Walk(func(n *index.ProjectNode) {
+		if !v.isActive(n) || v.getNodeClass(n) == "" {
+			return
+		}
+		if v.Tree.IsDataSource(n) {
+			path := nodePath(n)
+			ds := &GraphDataSource{Path: path, Name: n.Name, Class: v.getNodeClass(n), Signals: make(map[string]*GraphSignal)}
+			if signals := n.Children["Signals"]; signals != nil {
+				for _, sig := range v.Tree.OrderedChildren(signals) {
+					ds.Signals[sig.Name] = &GraphSignal{
+						Path:      nodePath(sig),
+						Name:      sig.Name,
+						Type:      v.signalField(sig, nil, "Type"),
+						Elements:  v.signalElements(sig, nil),
+						Producers: []GraphEndpoint{},
+						Consumers: []GraphEndpoint{},
+					}
+				}
+			}
+			g.DataSources[path] = ds
+		} else if v.Tree.IsGAM(n) {
+			gams = append(gams, n)
+		}
+	})
+
+	gamThreads := make(map[*index.ProjectNode][]string)
+	v.Tree.Walk(func(app *index.ProjectNode) {
+		if !v.isActive(app) || v.getNodeClass(app) != "RealTimeApplication" {
+			return
+		}
+		for _, state := range v.Tree.ApplicationStates(app) {
+			s := &GraphState{Path: nodePath(state), Name: state.Name, Threads: []string{}}
+			for _, thread := range v.Tree.StateThreads(state) {
+				t := &GraphThread{Path: nodePath(thread), Name: thread.Name, State: s.Path, GAMs: []string{}}
+				for _, gam := range v.getThreadGAMs(thread) {
+					t.GAMs = append(t.GAMs, nodePath(gam))
+					if !containsString(gamThreads[gam], t.Path) {
+						gamThreads[gam] = append(gamThreads[gam], t.Path)
+					}
+				}
+				s.Threads = append(s.Threads, t.Path)
+				g.Threads[t.Path] = t
+			}
+			g.States[s.Path] = s
+		}
+	})
+
+	for _, n := range gams {
+		gam := &GraphGAM{
+			Path:    nodePath(n),
+			Name:    n.Name,
+			Class:   v.getNodeClass(n),
+			Threads: append([]string{}, gamThreads[n]...),
+			Inputs:  make(map[string]*GraphGAMSignal),
+			Outputs: make(map[string]*GraphGAMSignal),
+		}
+		for _, dir := range []string{"InputSignals", "OutputSignals"} {
+			container := n.Children[dir]
+			if container == nil {
+				continue
+			}
+			for _, sig := range v.Tree.OrderedChildren(container) {
+				dsNode, name := v.Tree.GetSignalInfo(sig)
+				name = index.NormalizeName(name)
+				s := &GraphGAMSignal{Path: nodePath(sig), Name: sig.Name, Signal: name}
+				var dsSig *index.ProjectNode
+				if dsNode != nil {
+					s.DataSource = nodePath(dsNode)
+					if signals := dsNode.Children["Signals"]; signals != nil {
+						dsSig = signals.Children[name]
+					}
+				}
+				s.Type = v.signalField(sig, dsSig, "Type")
+				s.Elements = v.signalElements(sig, dsSig)
+				if dir == "InputSignals" {
+					gam.Inputs[sig.Name] = s
+				} else {
+					gam.Outputs[sig.Name] = s
+				}
+
+				ds := g.DataSources[s.DataSource]
+				if ds == nil {
+					continue
+				}
+				target := ds.Signals[name]
+				if target == nil {
+					// Implicit signals exist in the DataSource through their use.
+					target = &GraphSignal{
+						Path:      ds.Path + ".Signals." + name,
+						Name:      name,
+						Type:      s.Type,
+						Elements:  s.Elements,
+						Producers: []GraphEndpoint{},
+						Consumers: []GraphEndpoint{},
+					}
+					ds.Signals[name] = target
+				}
+				end := GraphEndpoint{GAM: gam.Path, Signal: s.Path, Threads: gam.Threads}
+				if dir == "InputSignals" {
+					target.Consumers = append(target.Consumers, end)
+				} else {
+					target.Producers = append(target.Producers, end)
+				}
+			}
+		}
+		g.GAMs[gam.Path] = gam
+	}
+	return g
+}
+
+// signalField returns a field of a GAM signal as text, falling back to the
+// DataSource signal it uses.
+func (v *Validator) signalField(sig, dsSig *index.ProjectNode, name string) string {
+	for _, n := range []*index.ProjectNode{sig, dsSig} {
+		if n == nil {
+			continue
+		}
+		if fs := v.getFields(n)[name]; len(fs) > 0 {
+			return fmt.Sprint(v.ValueToInterface(fs[0].Value, n))
+		}
+	}
+	return ""
+}
+
+// signalElements returns the NumberOfElements of a GAM signal, falling
+// back to the DataSource signal it uses, 1 by default.
+func (v *Validator) signalElements(sig, dsSig *index.ProjectNode) int64 {
+	for _, n := range []*index.ProjectNode{sig, dsSig} {
+		if n == nil {
+			continue
+		}
+		if fs := v.getFields(n)["NumberOfElements"]; len(fs) > 0 {
+			if e, ok := toInt64(v.ValueToInterface(fs[0].Value, n)); ok {
+				return e
+			}
+		}
+	}
+	return 1
+}
+
+// CheckRules evaluates the rules of the #Rules section of the schema against
+// the export of the project and reports each violation at the object it
+// names, with the rule name as tag. A rule that cannot be evaluated is
+// reported at its definition.
+func (v *Validator) CheckRules(ctx context.Context) {
+	if v.Schema == nil {
+		return
+	}
+	rules, err := v.Schema.Value.LookupPath(cue.ParsePath("#Rules")).Fields()
+	if err != nil {
+		return
+	}
+	var graph cue.Value
+	var nodes map[string]*index.ProjectNode
+	for rules.Next() {
+		if ctx.Err() != nil {
+			return
+		}
+		name := rules.Selector().Unquoted()
+		if nodes == nil {
+			graph = v.Schema.Context.Encode(v.RuleGraph())
+			nodes = make(map[string]*index.ProjectNode)
+			v.Tree.Walk(func(n *index.ProjectNode) {
+				if v.isActive(n) {
+					nodes[nodePath(n)] = n
+				}
+			})
+		}
+		def := rules.Value()
+		rule := def.FillPath(cue.ParsePath("graph"), graph)
+		message, _ := rule.LookupPath(cue.ParsePath("message")).String()
+		level := LevelError
+		if l, _ := rule.LookupPath(cue.ParsePath("level")).Default(); l.Kind() == cue.StringKind {
+			if s, _ := l.String(); s == "warning" {
+				level = LevelWarning
+			}
+		}
+		violations, err := rule.LookupPath(cue.ParsePath("violations")).List()
+		if err != nil {
+			v.reportRuleError(name, def, err)
+			continue
+		}
+		type violation struct{ path, msg string }
+		var found []violation
+		seen := make(map[violation]bool)
+		for violations.Next() {
+			el := violations.Value()
+			vi := violation{msg: message}
+			if s, err := el.String(); err == nil {
+				vi.path = s
+			} else if s, err := el.LookupPath(cue.ParsePath("path")).String(); err == nil {
+				vi.path = s
+				if m, err := el.LookupPath(cue.ParsePath("message")).String(); err == nil {
+					vi.msg = m
+				}
+			} else {
+				v.reportRuleError(name, def, err)
+				continue
+			}
+			if !seen[vi] {
+				seen[vi] = true
+				found = append(found, vi)
+			}
+		}
+		sort.SliceStable(found, func(i, j int) bool { return found[i].path < found[j].path })
+		for _, vi := range found {
+			node := ruleNode(nodes, vi.path)
+			if node == nil {
+				v.reportRuleError(name, def, fmt.Errorf("no object at path '%s'", vi.path))
+				continue
+			}
+			v.report(node, name, level, vi.msg, v.getNodePosition(node), v.getNodeFile(node))
+		}
+	}
+}
+
+// ruleNode returns the object at path, or its closest existing ancestor
+// for the implicit signals of a DataSource.
+func ruleNode(nodes map[string]*index.ProjectNode, path string) *index.ProjectNode {
+	for p := path; p != ""; {
+		if n := nodes[p]; n != nil {
+			return n
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return nil
+}
+
+// reportRuleError reports a rule that cannot be evaluated where it is
+// written.
+func (v *Validator) reportRuleError(name string, rule cue.Value, err error) {
+	pos, file := schemaPosition(rule)
+	v.report(nil, "invalid_rule", LevelError,
+		fmt.Sprintf("Rule '%s' cannot be evaluated: %v", name, err), pos, file)
+}
+
+// schemaPosition returns where a value of the schema is written in a
+// schema file. Values are unified with the built-in schema, which has no
+// file name, so the position of the conjunct coming from a file is used.
+func schemaPosition(val cue.Value) (parser.Position, string) {
+	pos := val.Pos()
+	_, conjuncts := val.Expr()
+	for _, c := range conjuncts {
+		if c.Pos().Filename() != "" {
+			pos = c.Pos()
+			break
+		}
+	}
+	return parser.Position{Line: pos.Line(), Column: pos.Column()}, pos.Filename()
+}
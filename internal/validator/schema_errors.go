@@ -0,0 +1,248 @@
+package validator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cueerrors "cuelang.org/go/cue/errors"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// schemaErrorGroup gathers the CUE errors about one path of an object; an
+// enumeration mismatch, for instance, yields one error per member.
+type schemaErrorGroup struct {
+	path   []string
+	errors []cueerrors.Error
+}
+
+// reportCUEError reports the errors of the validation of node against the
+// schema of class. Each error is reported at the field it is about, in the
+// fragment defining it, with a message in MARTe terms and a rule ID of its
+// own:
+//
+//	schema_missing_field  a mandatory field is missing
+//	schema_unknown_field  a field the class does not accept
+//	schema_type_mismatch  a value of the wrong type
+//	schema_invalid_enum   a value outside an enumeration
+//	schema_invalid_value  a value other than the one required
+//	schema_out_of_range   a value outside the bounds of the field
+//	schema_validation     any other schema violation
+func (v *Validator) reportCUEError(err error, node *index.ProjectNode, class string, data map[string]interface{}) {
+	var groups []*schemaErrorGroup
+	byPath := make(map[string]*schemaErrorGroup)
+	for _, e := range cueerrors.Errors(err) {
+		path := e.Path()
+		if len(path) > 0 && strings.HasPrefix(path[0], "#") {
+			path = path[1:]
+		}
+		key := strings.Join(path, ".")
+		g := byPath[key]
+		if g == nil {
+			g = &schemaErrorGroup{path: path}
+			byPath[key] = g
+			groups = append(groups, g)
+		}
+		g.errors = append(g.errors, e)
+	}
+
+	for _, g := range groups {
+		target, field := v.schemaErrorTarget(node, g.path)
+		pos, file := v.getNodePosition(target), v.getNodeFile(target)
+		var raw parser.Value
+		if field != nil {
+			pos, file, raw = field.Raw.Position, field.File, field.Value
+		}
+		given, present := lookupData(data, g.path)
+		tag, msg := describeSchemaError(class, g, given, present, raw)
+		v.report(target, tag, LevelError, msg, pos, file)
+	}
+}
+
+// schemaErrorTarget returns the object holding the field a schema error path
+// names, and the definition of the field when it is set. Paths into nested
+// objects (signals) follow the children of node; a path that cannot be
+// followed is reported at the closest object.
+func (v *Validator) schemaErrorTarget(node *index.ProjectNode, path []string) (*index.ProjectNode, *index.EvaluatedField) {
+	cur := node
+	for _, name := range path {
+		if defs := v.getFields(cur)[name]; len(defs) > 0 {
+			// The last definition is the one validated.
+			return cur, &defs[len(defs)-1]
+		}
+		child, ok := cur.Children[name]
+		if !ok {
+			break
+		}
+		cur = child
+	}
+	return cur, nil
+}
+
+// lookupData returns the value validated at path.
+func lookupData(data interface{}, path []string) (interface{}, bool) {
+	cur := data
+	for _, p := range path {
+		switch t := cur.(type) {
+		case map[string]interface{}:
+			next, ok := t[p]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			cur = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+func describeSchemaError(class string, g *schemaErrorGroup, given interface{}, present bool, raw parser.Value) (string, string) {
+	name := strings.Join(g.path, ".")
+	subject := fmt.Sprintf("field '%s' of class '%s'", name, class)
+	givenText := ""
+	if raw != nil {
+		givenText = valueText(raw)
+	}
+	if givenText == "" && present {
+		givenText = dataText(given)
+	}
+
+	var expected, types, bounds []string
+	for _, e := range g.errors {
+		format, args := e.Msg()
+		switch {
+		case format == "field not allowed":
+			return "schema_unknown_field", fmt.Sprintf("Field '%s' is not allowed in class '%s'", name, class)
+		case strings.HasPrefix(format, "incomplete value") && !present:
+			return "schema_missing_field", fmt.Sprintf("Missing mandatory %s", subject)
+		case strings.HasPrefix(format, "invalid value") && strings.Contains(format, "out of bound") && len(args) == 2:
+			bounds = appendUnique(bounds, fmt.Sprint(args[1]))
+		case strings.HasPrefix(format, "conflicting values") && len(args) >= 2:
+			a, b := fmt.Sprint(args[0]), fmt.Sprint(args[1])
+			other := b
+			if present && b == dataText(given) {
+				other = a
+			}
+			if !present {
+				// A value computed by the schema, such as the size of an
+				// IOGAM, rather than one of the configuration.
+				return "schema_invalid_value", fmt.Sprintf("Field '%s' of class '%s' has conflicting values %s and %s", name, class, a, b)
+			}
+			if strings.Contains(format, "mismatched types") && isCUEType(other) {
+				types = appendUnique(types, kindText(other))
+			} else {
+				expected = appendUnique(expected, other)
+			}
+		}
+	}
+
+	// float | int accepts any number.
+	if containsString(types, "a number") {
+		types = removeString(types, "an integer")
+	}
+
+	switch {
+	case len(bounds) > 0:
+		return "schema_out_of_range", fmt.Sprintf("Value %s of %s is out of range: expected %s", givenText, subject, strings.Join(bounds, " and "))
+	case len(types) > 0 && len(expected) == 0:
+		return "schema_type_mismatch", fmt.Sprintf("Field '%s' of class '%s' must be %s, got %s %s", name, class, strings.Join(types, " or "), kindText(dataText(given)), givenText)
+	case len(expected)+len(types) > 1:
+		sort.Strings(expected)
+		return "schema_invalid_enum", fmt.Sprintf("Invalid value %s for %s: expected one of %s", givenText, subject, strings.Join(append(expected, types...), ", "))
+	case len(expected) == 1:
+		return "schema_invalid_value", fmt.Sprintf("Invalid value %s for %s: expected %s", givenText, subject, expected[0])
+	}
+
+	msgs := make([]string, 0, len(g.errors))
+	for _, e := range g.errors {
+		format, args := e.Msg()
+		msgs = append(msgs, fmt.Sprintf(format, args...))
+	}
+	if name != "" {
+		name += ": "
+	}
+	return "schema_validation", fmt.Sprintf("Schema Validation Error: %s%s", name, strings.Join(msgs, "; "))
+}
+
+// isCUEType tells whether a CUE expression printed in an error is a type
+// rather than a value.
+func isCUEType(cue string) bool {
+	switch cue {
+	case "string", "int", "float", "number", "bool", "bytes":
+		return true
+	}
+	return strings.HasPrefix(cue, "[") || strings.HasPrefix(cue, "{")
+}
+
+// kindText names, in MARTe terms, the kind of a CUE type or value.
+func kindText(cue string) string {
+	switch {
+	case cue == "string" || strings.HasPrefix(cue, "\""):
+		return "a string"
+	case cue == "int":
+		return "an integer"
+	case cue == "float" || cue == "number":
+		return "a number"
+	case cue == "bool" || cue == "true" || cue == "false":
+		return "a boolean"
+	case strings.HasPrefix(cue, "["):
+		return "an array"
+	case strings.HasPrefix(cue, "{"):
+		return "an object"
+	case strings.ContainsAny(cue, ".eE") && !strings.HasPrefix(cue, "0x"):
+		return "a number"
+	case cue != "":
+		if _, err := strconv.ParseInt(cue, 0, 64); err == nil {
+			return "an integer"
+		}
+	}
+	return "a value"
+}
+
+// dataText renders a validated value the way CUE prints it in errors.
+func dataText(val interface{}) string {
+	switch t := val.(type) {
+	case string:
+		return strconv.Quote(t)
+	case []interface{}:
+		parts := make([]string, len(t))
+		for i, e := range t {
+			parts[i] = dataText(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]interface{}:
+		return "{...}"
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(val)
+}
+
+func removeString(list []string, s string) []string {
+	var out []string
+	for _, x := range list {
+		if x != s {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, x := range list {
+		if x == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,315 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	cueerrors "cuelang.org/go/cue/errors"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+)
+
+// signalProperties are the properties of a signal the signal rules of a class
+// constrain.
+var signalProperties = []string{"Type", "NumberOfElements", "NumberOfDimensions"}
+
+// signalRecord is a signal as checked against the signal rules of a class:
+// its resolved properties and the definitions setting them.
+type signalRecord struct {
+	node   *index.ProjectNode
+	name   string
+	props  map[string]interface{}
+	fields map[string]index.EvaluatedField
+}
+
+// CheckSignalRules checks signals against the rules the schema declares in
+// the #meta of their class: Signals for the signals of a DataSource,
+// InputSignals and OutputSignals for those of a GAM, and SignalPairs for each
+// input of a GAM together with the output at the same position. The signals
+// a GAM defines implicitly in a DataSource are checked against the rules of
+// the DataSource.
+func (v *Validator) CheckSignalRules(ctx context.Context) {
+	if v.Schema == nil {
+		return
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if ctx.Err() != nil || !v.isActive(node) {
+			return
+		}
+		class := v.getNodeClass(node)
+		if i := strings.LastIndex(class, "::"); i != -1 {
+			class = class[i+2:]
+		}
+		if class == "" {
+			return
+		}
+		owner := fmt.Sprintf("'%s' (class '%s')", node.RealName, class)
+		if v.Tree.IsDataSource(node) {
+			if rules, ok := v.signalRules(class, "Signals"); ok {
+				v.checkSignalSet(rules, v.signalRecords(node, "Signals"), "Signal", "DataSource "+owner)
+			}
+		}
+		if !v.Tree.IsGAM(node) {
+			return
+		}
+		inputs := v.signalRecords(node, "InputSignals")
+		outputs := v.signalRecords(node, "OutputSignals")
+		if rules, ok := v.signalRules(class, "InputSignals"); ok {
+			v.checkSignalSet(rules, inputs, "Input signal", "GAM "+owner)
+		}
+		if rules, ok := v.signalRules(class, "OutputSignals"); ok {
+			v.checkSignalSet(rules, outputs, "Output signal", "GAM "+owner)
+		}
+		if rules, ok := v.signalRules(class, "SignalPairs"); ok {
+			v.checkSignalPairs(node, rules, inputs, outputs, "GAM "+owner)
+		}
+		v.checkImplicitSignals(append(inputs, outputs...))
+	})
+}
+
+// signalRules returns the rules a class declares in #meta.<section>.
+func (v *Validator) signalRules(class, section string) (cue.Value, bool) {
+	path := cue.MakePath(cue.Def("#Classes"), cue.Str(class), cue.Def("#meta"), cue.Str(section))
+	rules := v.Schema.Value.LookupPath(path)
+	return rules, rules.Exists() && rules.Err() == nil
+}
+
+// signalRecords returns the signals of the container of node, in order.
+func (v *Validator) signalRecords(node *index.ProjectNode, container string) []signalRecord {
+	c, ok := node.Children[container]
+	if !ok {
+		return nil
+	}
+	var records []signalRecord
+	for _, sig := range v.Tree.OrderedChildren(c) {
+		if v.isActive(sig) {
+			records = append(records, v.signalRecord(sig, sig.RealName))
+		}
+	}
+	return records
+}
+
+// signalRecord resolves the properties of a signal, falling back to the
+// DataSource definition for GAM signals. A signal without NumberOfElements
+// is a scalar, and one without NumberOfDimensions is a vector when it has
+// several elements.
+func (v *Validator) signalRecord(sig *index.ProjectNode, name string) signalRecord {
+	fields := v.getFields(sig)
+	r := signalRecord{node: sig, name: name, props: make(map[string]interface{}), fields: make(map[string]index.EvaluatedField)}
+	for _, prop := range signalProperties {
+		if fs := fields[prop]; len(fs) > 0 {
+			r.fields[prop] = fs[0]
+		} else if sig.Target != nil {
+			if fs := v.getFields(sig.Target)[prop]; len(fs) > 0 {
+				r.fields[prop] = fs[0]
+			}
+		}
+	}
+	if t := v.signalType(sig, fields); t != "" {
+		r.props["Type"] = t
+	}
+	elements, ok := v.signalIntProperty(sig, fields, "NumberOfElements")
+	if !ok {
+		elements = 1
+	}
+	dimensions, ok := v.signalIntProperty(sig, fields, "NumberOfDimensions")
+	if !ok {
+		dimensions = 0
+		if elements > 1 {
+			dimensions = 1
+		}
+	}
+	r.props["NumberOfElements"] = elements
+	r.props["NumberOfDimensions"] = dimensions
+	return r
+}
+
+// checkImplicitSignals checks the signals a GAM defines implicitly in a
+// DataSource against the Signals rules of the DataSource; signals the
+// DataSource defines are checked with it.
+func (v *Validator) checkImplicitSignals(records []signalRecord) {
+	for _, r := range records {
+		if r.node.Target != nil {
+			continue
+		}
+		dsFields := v.getFields(r.node)["DataSource"]
+		if len(dsFields) == 0 {
+			continue
+		}
+		dsName := v.getFieldValue(dsFields[0], r.node)
+		ds := v.resolveReference(dsName, r.node, v.Tree.IsDataSource)
+		if ds == nil {
+			continue
+		}
+		class := v.getNodeClass(ds)
+		if i := strings.LastIndex(class, "::"); i != -1 {
+			class = class[i+2:]
+		}
+		rules, ok := v.signalRules(class, "Signals")
+		if !ok {
+			continue
+		}
+		if alias := v.getFields(r.node)["Alias"]; len(alias) > 0 {
+			r.name = v.getFieldValue(alias[0], r.node)
+		}
+		v.checkSignalSet(rules, []signalRecord{r}, "Signal", fmt.Sprintf("DataSource '%s' (class '%s')", ds.RealName, class))
+	}
+}
+
+// checkSignalSet checks records, keyed by name, against rules.
+func (v *Validator) checkSignalSet(rules cue.Value, records []signalRecord, kind, owner string) {
+	if len(records) == 0 {
+		return
+	}
+	data := make(map[string]interface{})
+	byName := make(map[string]signalRecord)
+	for _, r := range records {
+		data[r.name] = r.props
+		byName[r.name] = r
+	}
+	err := rules.Unify(v.Schema.Context.Encode(data)).Validate(cue.Concrete(true))
+	for _, g := range signalRuleErrors(rules, err) {
+		r, ok := byName[g.path[0]]
+		if !ok {
+			continue
+		}
+		if len(g.path) == 1 {
+			if g.notAllowed {
+				v.report(r.node, "signal_not_allowed", LevelError,
+					fmt.Sprintf("%s '%s' is not allowed in %s", kind, r.name, owner),
+					v.getNodePosition(r.node), v.getNodeFile(r.node))
+			}
+			continue
+		}
+		v.reportSignalRule(r, g, "signal_incompatible",
+			fmt.Sprintf("%s '%s' of %s", kind, r.name, owner), "")
+	}
+}
+
+// checkSignalPairs checks each input signal of a GAM together with the output
+// signal declared at the same position. A GAM without inputs or without
+// outputs has no pairs to check.
+func (v *Validator) checkSignalPairs(gam *index.ProjectNode, rules cue.Value, inputs, outputs []signalRecord, owner string) {
+	if len(inputs) == 0 || len(outputs) == 0 {
+		return
+	}
+	if len(inputs) != len(outputs) {
+		v.report(gam, "signal_pair_mismatch", LevelError,
+			fmt.Sprintf("%s pairs its input and output signals but has %d input and %d output signals", owner, len(inputs), len(outputs)),
+			v.getNodePosition(gam), v.getNodeFile(gam))
+	}
+	for i := 0; i < len(inputs) && i < len(outputs); i++ {
+		in, out := inputs[i], outputs[i]
+		data := map[string]interface{}{"Input": in.props, "Output": out.props}
+		err := rules.Unify(v.Schema.Context.Encode(data)).Validate(cue.Concrete(true))
+		for _, g := range signalRuleErrors(rules, err) {
+			if len(g.path) < 2 {
+				continue
+			}
+			r, kind, other := in, "Input", out
+			if g.path[0] == "Output" {
+				r, kind, other = out, "Output", in
+			}
+			v.reportSignalRule(r, g, "signal_pair_mismatch",
+				fmt.Sprintf("%s signal '%s' of %s", kind, r.name, owner),
+				fmt.Sprintf(" paired with '%s'", other.name))
+		}
+	}
+}
+
+// reportSignalRule reports a property of r breaking a signal rule, at the
+// definition of the property.
+func (v *Validator) reportSignalRule(r signalRecord, g *signalRuleError, tag, subject, suffix string) {
+	prop := g.path[1]
+	given := dataText(r.props[prop])
+	if s, ok := r.props[prop].(string); ok {
+		given = s
+	}
+	msg := fmt.Sprintf("%s cannot have %s %s%s", subject, prop, given, suffix)
+	if expected := g.expected(r.props[prop]); expected != "" {
+		msg += ": expected " + expected
+	}
+	pos, file := v.getNodePosition(r.node), v.getNodeFile(r.node)
+	if f, ok := r.fields[prop]; ok {
+		pos, file = f.Raw.Position, f.File
+	}
+	v.report(r.node, tag, LevelError, msg, pos, file)
+}
+
+// signalRuleError gathers the CUE errors about one path below a rule.
+type signalRuleError struct {
+	path       []string
+	notAllowed bool
+	errors     []cueerrors.Error
+}
+
+// signalRuleErrors groups the errors of a rule check by path, relative to
+// rules. Incomplete values, such as a rule on the Type of a signal whose type
+// is unknown, are left to the other checks, and so are unknown properties,
+// which signal rules need not declare.
+func signalRuleErrors(rules cue.Value, err error) []*signalRuleError {
+	prefix := len(rules.Path().Selectors())
+	var groups []*signalRuleError
+	byPath := make(map[string]*signalRuleError)
+	for _, e := range cueerrors.Errors(err) {
+		path := e.Path()
+		if len(path) <= prefix {
+			continue
+		}
+		path = path[prefix:]
+		format, _ := e.Msg()
+		if strings.HasPrefix(format, "incomplete value") || strings.HasSuffix(format, "errors in empty disjunction:") {
+			continue
+		}
+		notAllowed := format == "field not allowed"
+		if notAllowed && len(path) > 1 {
+			continue
+		}
+		key := strings.Join(path, ".")
+		g := byPath[key]
+		if g == nil {
+			g = &signalRuleError{path: path}
+			byPath[key] = g
+			groups = append(groups, g)
+		}
+		g.notAllowed = g.notAllowed || notAllowed
+		g.errors = append(g.errors, e)
+	}
+	return groups
+}
+
+// expected describes what the rule expects instead of given.
+func (g *signalRuleError) expected(given interface{}) string {
+	givenText := dataText(given)
+	var values, bounds, others []string
+	for _, e := range g.errors {
+		format, args := e.Msg()
+		switch {
+		case strings.HasPrefix(format, "conflicting values") && len(args) >= 2:
+			other := fmt.Sprint(args[1])
+			if other == givenText {
+				other = fmt.Sprint(args[0])
+			}
+			if s, err := strconv.Unquote(other); err == nil {
+				other = s
+			}
+			values = appendUnique(values, other)
+		case strings.Contains(format, "out of bound") && len(args) == 2:
+			bounds = appendUnique(bounds, fmt.Sprint(args[1]))
+		default:
+			others = appendUnique(others, fmt.Sprintf(format, args...))
+		}
+	}
+	switch {
+	case len(bounds) > 0:
+		return strings.Join(bounds, " and ")
+	case len(values) == 1:
+		return values[0]
+	case len(values) > 1:
+		return "one of " + strings.Join(values, ", ")
+	}
+	return strings.Join(others, "; ")
+}
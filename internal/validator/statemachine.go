@@ -0,0 +1,294 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// initialState is the state a MARTe StateMachine starts in.
+const initialState = "INITIAL"
+
+// CheckStateMachines checks the transitions of every StateMachine and the
+// Destination and Function of every Message.
+func (v *Validator) CheckStateMachines(ctx context.Context) {
+	var machines, messages []*index.ProjectNode
+	v.Tree.Walk(func(n *index.ProjectNode) {
+		if !v.isActive(n) {
+			return
+		}
+		switch v.getNodeClass(n) {
+		case "StateMachine":
+			machines = append(machines, n)
+		case "Message":
+			messages = append(messages, n)
+		}
+	})
+	for _, sm := range machines {
+		if ctx.Err() != nil {
+			return
+		}
+		v.checkStateMachine(sm)
+	}
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			return
+		}
+		v.checkMessage(msg)
+	}
+}
+
+// checkStateMachine reports NextState and NextStateError values that are not
+// states of the machine, states that cannot be reached from INITIAL and
+// states without any event to leave them.
+func (v *Validator) checkStateMachine(sm *index.ProjectNode) {
+	states := v.machineStates(sm)
+	if len(states) == 0 {
+		return
+	}
+	byName := make(map[string]*index.ProjectNode)
+	for _, s := range states {
+		byName[s.Name] = s
+	}
+
+	transitions := make(map[*index.ProjectNode][]*index.ProjectNode)
+	for _, state := range states {
+		events := v.stateEvents(state)
+		if len(events) == 0 {
+			v.report(state, "dead_end_state", LevelWarning,
+				fmt.Sprintf("State '%s' of StateMachine '%s' has no StateMachineEvent and can never be left", state.Name, sm.Name),
+				v.getNodePosition(state), v.getNodeFile(state))
+		}
+		for _, ev := range events {
+			for _, field := range []string{"NextState", "NextStateError"} {
+				fs := ev.Fields[field]
+				if len(fs) == 0 {
+					continue
+				}
+				f := fs[len(fs)-1]
+				name, pos := v.nameValue(f, ev)
+				if name == "" {
+					continue
+				}
+				target := byName[name]
+				if target == nil {
+					v.report(ev, "unknown_next_state", LevelError,
+						fmt.Sprintf("%s '%s' of event '%s' is not a state of StateMachine '%s'", field, name, ev.Name, sm.Name),
+						pos, f.File)
+					continue
+				}
+				transitions[state] = append(transitions[state], target)
+			}
+		}
+	}
+
+	initial := byName[initialState]
+	if initial == nil {
+		v.report(sm, "missing_initial_state", LevelWarning,
+			fmt.Sprintf("StateMachine '%s' has no %s state; assuming '%s' is the initial state", sm.Name, initialState, states[0].Name),
+			v.getNodePosition(sm), v.getNodeFile(sm))
+		initial = states[0]
+	}
+	reached := map[*index.ProjectNode]bool{initial: true}
+	queue := []*index.ProjectNode{initial}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, next := range transitions[s] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	for _, state := range states {
+		if !reached[state] {
+			v.report(state, "unreachable_state", LevelWarning,
+				fmt.Sprintf("State '%s' of StateMachine '%s' is not reachable from '%s'", state.Name, sm.Name, initial.Name),
+				v.getNodePosition(state), v.getNodeFile(state))
+		}
+	}
+}
+
+// checkMessage reports a Destination that does not exist and a Function the
+// destination does not provide. Messages sent to a StateMachine call one of
+// its events; other classes list their functions in '#meta.functions'.
+func (v *Validator) checkMessage(msg *index.ProjectNode) {
+	fs := msg.Fields["Destination"]
+	if len(fs) == 0 {
+		return
+	}
+	f := fs[len(fs)-1]
+	dest, pos := v.nameValue(f, msg)
+	if dest == "" {
+		return
+	}
+	target := v.resolveReference(dest, msg, nil)
+	if target == nil {
+		// Bare names are reported as unknown references already.
+		if _, isRef := f.Value.(*parser.ReferenceValue); !isRef {
+			v.report(msg, "unknown_message_destination", LevelError,
+				fmt.Sprintf("Destination '%s' of Message '%s' does not exist", dest, msg.Name),
+				pos, f.File)
+		}
+		return
+	}
+
+	fnFields := msg.Fields["Function"]
+	if len(fnFields) == 0 {
+		return
+	}
+	fnField := fnFields[len(fnFields)-1]
+	fn, fnPos := v.nameValue(fnField, msg)
+	if fn == "" {
+		return
+	}
+	cls := v.getNodeClass(target)
+	if cls == "StateMachine" {
+		events := v.machineEvents(target)
+		if !containsString(events, fn) {
+			v.report(msg, "unknown_message_function", LevelError,
+				fmt.Sprintf("Function '%s' of Message '%s' is not an event of StateMachine '%s' (events: %s)", fn, msg.Name, target.Name, strings.Join(events, ", ")),
+				fnPos, fnField.File)
+		}
+		return
+	}
+	if functions, ok := v.classFunctions(cls); ok && !containsString(functions, fn) {
+		v.report(msg, "unknown_message_function", LevelError,
+			fmt.Sprintf("Function '%s' of Message '%s' is not provided by %s '%s' (functions: %s)", fn, msg.Name, cls, target.Name, strings.Join(functions, ", ")),
+			fnPos, fnField.File)
+		return
+	}
+	if cls == "RealTimeApplication" && fn == "PrepareNextState" {
+		v.checkPrepareNextState(msg, target)
+	}
+}
+
+// checkPrepareNextState reports a PrepareNextState message whose first
+// parameter does not name one of the States of the application.
+func (v *Validator) checkPrepareNextState(msg, app *index.ProjectNode) {
+	params := msg.Children["Parameters"]
+	if params == nil {
+		v.report(msg, "missing_next_state", LevelError,
+			fmt.Sprintf("Message '%s' calls PrepareNextState without Parameters naming the next state", msg.Name),
+			v.getNodePosition(msg), v.getNodeFile(msg))
+		return
+	}
+	fs := params.Fields["param1"]
+	if len(fs) == 0 {
+		v.report(msg, "missing_next_state", LevelError,
+			fmt.Sprintf("Parameters of Message '%s' must set param1 to the next state of '%s'", msg.Name, app.Name),
+			v.getNodePosition(params), v.getNodeFile(params))
+		return
+	}
+	f := fs[len(fs)-1]
+	name, pos := v.nameValue(f, params)
+	if name == "" {
+		return
+	}
+	var target *index.ProjectNode
+	if states := app.Children["States"]; states != nil {
+		target = states.Children[name]
+	}
+	if target == nil {
+		v.report(params, "unknown_next_state", LevelError,
+			fmt.Sprintf("PrepareNextState '%s' of Message '%s' is not a state of '%s'", name, msg.Name, app.Name),
+			pos, f.File)
+		return
+	}
+}
+
+// isSymbolField reports fields whose bare identifiers are names rather than
+// references to objects in scope: states, events and message modes.
+// CheckStateMachines resolves the ones that name something.
+func (v *Validator) isSymbolField(f index.EvaluatedField, node *index.ProjectNode) bool {
+	switch f.Raw.Name {
+	case "NextState", "NextStateError":
+		return v.getNodeClass(node) == "StateMachineEvent"
+	case "Function", "Mode":
+		return v.getNodeClass(node) == "Message"
+	case "param1":
+		return node.Name == "Parameters" && node.Parent != nil && v.getNodeClass(node.Parent) == "Message"
+	}
+	return false
+}
+
+// machineStates returns the states of a StateMachine in declaration order.
+func (v *Validator) machineStates(sm *index.ProjectNode) []*index.ProjectNode {
+	var states []*index.ProjectNode
+	for _, child := range v.Tree.OrderedChildren(sm) {
+		if strings.HasPrefix(child.RealName, "+") && v.isActive(child) {
+			states = append(states, child)
+		}
+	}
+	return states
+}
+
+func (v *Validator) stateEvents(state *index.ProjectNode) []*index.ProjectNode {
+	var events []*index.ProjectNode
+	for _, child := range v.Tree.OrderedChildren(state) {
+		if v.getNodeClass(child) == "StateMachineEvent" && v.isActive(child) {
+			events = append(events, child)
+		}
+	}
+	return events
+}
+
+// machineEvents returns the names of the events of every state of sm.
+func (v *Validator) machineEvents(sm *index.ProjectNode) []string {
+	var names []string
+	for _, state := range v.machineStates(sm) {
+		for _, ev := range v.stateEvents(state) {
+			if !containsString(names, ev.Name) {
+				names = append(names, ev.Name)
+			}
+		}
+	}
+	return names
+}
+
+// classFunctions returns the functions a class declares in its
+// '#meta.functions' schema entry.
+func (v *Validator) classFunctions(cls string) ([]string, bool) {
+	if v.Schema == nil || cls == "" {
+		return nil, false
+	}
+	val := v.Schema.Value.LookupPath(cue.ParsePath(fmt.Sprintf("#Classes.%s.#meta.functions", cls)))
+	iter, err := val.List()
+	if err != nil {
+		return nil, false
+	}
+	var functions []string
+	for iter.Next() {
+		if s, err := iter.Value().String(); err == nil {
+			functions = append(functions, s)
+		}
+	}
+	return functions, true
+}
+
+// nameValue returns the name held by a field and the position of the name.
+func (v *Validator) nameValue(f index.EvaluatedField, node *index.ProjectNode) (string, parser.Position) {
+	switch t := f.Value.(type) {
+	case *parser.StringValue:
+		pos := t.Position
+		if t.Quoted {
+			pos.Column++
+		}
+		return t.Value, pos
+	case *parser.ReferenceValue:
+		return t.Value, t.Position
+	}
+	return v.getFieldValue(f, node), f.Raw.Position
+}
+
+func (v *Validator) isActive(node *index.ProjectNode) bool {
+	v.muActive.Lock()
+	defer v.muActive.Unlock()
+	return v.ActiveNodes[node]
+}
@@ -0,0 +1,183 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// InactiveRange is a source range that is never used with the current
+// variable values, such as an unselected #switch branch.
+type InactiveRange struct {
+	File  string
+	Start parser.Position
+	End   parser.Position
+}
+
+// CheckSwitchBlocks reports duplicate #case values and, when the switched
+// variable is constrained to an enumeration (e.g. `"A" | "B"`), case values
+// outside it and members not handled by a #switch without #default.
+// Branches not selected by the current variable values are recorded in
+// InactiveRanges. A #switch nested in an #if, #foreach, #slot, #fill or
+// another #switch is found in the conditional fragment the index records for
+// that body, so scanning the fragments reaches every #switch exactly once.
+func (v *Validator) CheckSwitchBlocks(ctx context.Context) {
+	evalCtx := &index.EvaluationContext{Variables: v.Variables, Tree: v.Tree}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if ctx.Err() != nil {
+			return
+		}
+		for _, frag := range node.Fragments {
+			for _, def := range frag.Definitions {
+				if sw, ok := def.(*parser.SwitchBlock); ok {
+					v.checkSwitch(node, frag, sw, evalCtx)
+				}
+			}
+		}
+	})
+}
+
+func (v *Validator) checkSwitch(node *index.ProjectNode, frag *index.Fragment, sw *parser.SwitchBlock, evalCtx *index.EvaluationContext) {
+	branches := sw.Branches()
+
+	members, subject := v.switchEnum(node, sw)
+	handled := map[string]bool{}
+	seen := map[string]bool{}
+	for _, b := range branches {
+		for _, val := range b.Values {
+			key, ok := switchKey(v.Tree.EvaluateValue(val, evalCtx))
+			if !ok {
+				continue
+			}
+			if seen[key] {
+				v.report(node, "switch_duplicate_case", LevelWarning,
+					fmt.Sprintf("Duplicate #case value %s", key), val.Pos(), frag.File)
+			}
+			seen[key] = true
+			if members == nil {
+				continue
+			}
+			if !containsString(members, key) {
+				v.report(node, "switch_unknown_case", LevelWarning,
+					fmt.Sprintf("#case value %s is not a possible value of '%s' (%s)", key, subject, strings.Join(members, " | ")),
+					val.Pos(), frag.File)
+			}
+			handled[key] = true
+		}
+	}
+
+	if members != nil && sw.DefaultPosition == nil {
+		var missing []string
+		for _, m := range members {
+			if !handled[m] {
+				missing = append(missing, m)
+			}
+		}
+		if len(missing) > 0 {
+			v.report(node, "switch_not_exhaustive", LevelWarning,
+				fmt.Sprintf("#switch on '%s' does not handle %s; add the missing #case or a #default", subject, strings.Join(missing, ", ")),
+				sw.Position, frag.File)
+		}
+	}
+
+	// Dim the branches that are not selected, as long as the subject
+	// has a known value here.
+	v.muActive.Lock()
+	active := v.ActiveFragments[frag]
+	v.muActive.Unlock()
+	if !active {
+		return
+	}
+	if _, ok := switchKey(v.Tree.EvaluateValue(sw.Subject, evalCtx)); !ok {
+		return
+	}
+	selected := v.Tree.SelectSwitchBranch(sw, evalCtx)
+	for _, b := range branches {
+		if selected != nil && selected.ID == b.ID {
+			continue
+		}
+		v.mu.Lock()
+		v.InactiveRanges = append(v.InactiveRanges, InactiveRange{File: frag.File, Start: b.Position, End: b.End})
+		v.mu.Unlock()
+	}
+}
+
+// switchEnum returns the members of the enumeration constraining the
+// variable switched on by sw, or nil if the subject is not such a variable.
+func (v *Validator) switchEnum(node *index.ProjectNode, sw *parser.SwitchBlock) ([]string, string) {
+	ref, ok := sw.Subject.(*parser.VariableReferenceValue)
+	if !ok || v.Schema == nil {
+		return nil, ""
+	}
+	name := strings.TrimPrefix(ref.Name, "@")
+	info := v.Tree.ResolveVariable(node, name)
+	if info == nil || info.Def == nil {
+		return nil, ref.Name
+	}
+	typeVal := v.Schema.Context.CompileString(info.Def.TypeExpr)
+	if typeVal.Err() != nil {
+		return nil, ref.Name
+	}
+
+	alternatives := []cue.Value{typeVal}
+	if op, args := typeVal.Expr(); op == cue.OrOp {
+		alternatives = args
+	}
+	var members []string
+	for _, alt := range alternatives {
+		if !alt.IsConcrete() {
+			return nil, ref.Name
+		}
+		var key string
+		switch alt.Kind() {
+		case cue.StringKind:
+			s, _ := alt.String()
+			key = strconv.Quote(s)
+		case cue.IntKind:
+			n, _ := alt.Int64()
+			key = strconv.FormatInt(n, 10)
+		case cue.BoolKind:
+			b, _ := alt.Bool()
+			key = strconv.FormatBool(b)
+		default:
+			return nil, ref.Name
+		}
+		if !containsString(members, key) {
+			members = append(members, key)
+		}
+	}
+	return members, ref.Name
+}
+
+// switchKey renders a concrete #switch or #case value the way enumeration
+// members are rendered. Bare identifiers count as strings.
+func switchKey(val parser.Value) (string, bool) {
+	switch t := val.(type) {
+	case *parser.StringValue:
+		return strconv.Quote(t.Value), true
+	case *parser.ReferenceValue:
+		return strconv.Quote(t.Value), true
+	case *parser.IntValue:
+		return strconv.FormatInt(t.Value, 10), true
+	case *parser.FloatValue:
+		return strconv.FormatFloat(t.Value, 'g', -1, 64), true
+	case *parser.BoolValue:
+		return strconv.FormatBool(t.Value), true
+	}
+	return "", false
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// CheckTemplates reports broken 'extends' clauses and follows every #use
+// outside a template through the templates it expands, so that a nested
+// #use receiving a bad value is reported at the argument the value came from.
+func (v *Validator) CheckTemplates(ctx context.Context) {
+	names := make([]string, 0, len(v.Tree.Templates))
+	for name := range v.Tree.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	nested := map[*parser.TemplateInstantiation]bool{}
+	for _, name := range names {
+		tdef := v.Tree.Templates[name]
+		walkTemplateUses(tdef.Body, func(inst *parser.TemplateInstantiation) {
+			nested[inst] = true
+		})
+		if tdef.Base == "" {
+			continue
+		}
+		if _, err := v.Tree.TemplateChain(name); err != nil {
+			v.report(nil, "invalid_template_base", LevelError,
+				fmt.Sprintf("Invalid template inheritance: %v", err),
+				tdef.BasePosition, v.Tree.TemplateFiles[name])
+		}
+	}
+
+	globals := &index.EvaluationContext{Variables: v.Variables, Tree: v.Tree}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if ctx.Err() != nil {
+			return
+		}
+		for _, frag := range node.Fragments {
+			for _, def := range frag.Definitions {
+				inst, ok := def.(*parser.TemplateInstantiation)
+				if !ok || nested[inst] {
+					continue
+				}
+				v.checkExpansion(inst, frag.File, globals)
+			}
+		}
+	})
+}
+
+// templateFrame is one step of an expansion chain: the values of the
+// parameters of an expanded template and, for those passed down from the
+// root #use, the argument they originate from.
+type templateFrame struct {
+	values  map[string]parser.Value
+	origins map[string]*parser.TemplateArgument
+	chain   []string // "Template 'Instance'" for each #use, root first
+	names   []string // templates being expanded
+}
+
+func (v *Validator) checkExpansion(root *parser.TemplateInstantiation, file string, globals *index.EvaluationContext) {
+	chain, err := v.Tree.TemplateChain(root.Template)
+	if err != nil {
+		return
+	}
+	frame := &templateFrame{
+		values:  map[string]parser.Value{},
+		origins: map[string]*parser.TemplateArgument{},
+		chain:   []string{fmt.Sprintf("%s '%s'", root.Template, root.Name)},
+		names:   []string{root.Template},
+	}
+	for i := range root.Arguments {
+		arg := &root.Arguments[i]
+		frame.values[arg.Name] = v.Tree.EvaluateValue(arg.Value, globals)
+		frame.origins[arg.Name] = arg
+	}
+	for _, p := range index.TemplateParameters(chain) {
+		if _, ok := frame.values[p.Name]; !ok && p.DefaultValue != nil {
+			frame.values[p.Name] = v.Tree.EvaluateValue(p.DefaultValue, globals)
+		}
+	}
+	v.checkFrame(root, file, chain, frame, globals)
+}
+
+// checkFrame checks the #use blocks in the body of an expanded template.
+// The #fill blocks of the expanding #use are not part of the template and
+// are checked where they are written.
+func (v *Validator) checkFrame(root *parser.TemplateInstantiation, file string, chain []*parser.TemplateDefinition, frame *templateFrame, globals *index.EvaluationContext) {
+	if len(frame.chain) > index.MaxTemplateDepth {
+		return
+	}
+	evalCtx := &index.EvaluationContext{Variables: frame.values, Parent: globals, Tree: v.Tree}
+	expanding := map[string]bool{}
+	for _, tdef := range chain {
+		expanding[tdef.Name] = true
+	}
+	walkTemplateUses(index.TemplateBody(chain, nil), func(inst *parser.TemplateInstantiation) {
+		if expanding[inst.Template] || containsString(frame.names, inst.Template) {
+			v.report(nil, "recursive_template", LevelError,
+				fmt.Sprintf("Template '%s' is used recursively (via %s)", inst.Template, strings.Join(frame.chain, " → ")),
+				root.Position, file)
+			return
+		}
+		nestedChain, err := v.Tree.TemplateChain(inst.Template)
+		if err != nil {
+			return
+		}
+		params := index.TemplateParameters(nestedChain)
+		next := &templateFrame{
+			values:  map[string]parser.Value{},
+			origins: map[string]*parser.TemplateArgument{},
+			chain:   append(append([]string(nil), frame.chain...), fmt.Sprintf("%s '%s'", inst.Template, inst.Name)),
+			names:   append(append([]string(nil), frame.names...), inst.Template),
+		}
+		for i := range inst.Arguments {
+			arg := &inst.Arguments[i]
+			val := v.Tree.EvaluateValue(arg.Value, evalCtx)
+			next.values[arg.Name] = val
+
+			// Arguments that do not depend on parameters are checked
+			// statically at the nested #use itself.
+			var origin *parser.TemplateArgument
+			fromParam := false
+			for _, name := range variableNames(arg.Value) {
+				if _, ok := frame.values[name]; ok {
+					fromParam = true
+					if origin == nil {
+						origin = frame.origins[name]
+					}
+				}
+			}
+			if !fromParam {
+				continue
+			}
+			next.origins[arg.Name] = origin
+			for _, p := range params {
+				if p.Name != arg.Name {
+					continue
+				}
+				mismatch := v.templateArgMismatch(p, val)
+				if mismatch == nil {
+					break
+				}
+				msg := fmt.Sprintf("Argument '%s' of template '%s' does not match '%s': %v (via %s)",
+					arg.Name, inst.Template, p.TypeExpr, mismatch, strings.Join(next.chain, " → "))
+				pos := root.Position
+				if origin != nil {
+					pos = origin.Position
+				}
+				v.report(nil, "template_arg_mismatch", LevelError, msg, pos, file)
+			}
+		}
+		for _, p := range params {
+			if _, ok := next.values[p.Name]; !ok && p.DefaultValue != nil {
+				next.values[p.Name] = v.Tree.EvaluateValue(p.DefaultValue, globals)
+			}
+		}
+		v.checkFrame(root, file, nestedChain, next, globals)
+	})
+}
+
+// checkTemplateFills reports #fill blocks naming a slot the template does
+// not have, and slots filled more than once.
+func (v *Validator) checkTemplateFills(inst *parser.TemplateInstantiation, chain []*parser.TemplateDefinition, file string) {
+	if len(inst.Blocks) == 0 {
+		return
+	}
+	slots := index.TemplateSlots(index.TemplateBody(chain, nil))
+	seen := map[string]bool{}
+	for _, b := range inst.Blocks {
+		if seen[b.Name] {
+			v.report(nil, "duplicate_template_fill", LevelError,
+				fmt.Sprintf("Slot '%s' is filled more than once", b.Name),
+				b.Position, file)
+			continue
+		}
+		seen[b.Name] = true
+		if slots[b.Name] == nil {
+			v.report(nil, "unknown_template_slot", LevelError,
+				fmt.Sprintf("Template '%s' has no slot named '%s'", inst.Template, b.Name),
+				b.Position, file)
+		}
+	}
+}
+
+// templateArgMismatch checks a concrete argument value against the type of
+// a template parameter. Values that are not known statically pass.
+func (v *Validator) templateArgMismatch(param parser.TemplateParameter, val parser.Value) error {
+	if v.Schema == nil || param.TypeExpr == "" || !isConcreteValue(val) {
+		return nil
+	}
+	typeVal := v.Schema.Context.CompileString(param.TypeExpr)
+	if typeVal.Err() != nil {
+		return nil
+	}
+	res := typeVal.Unify(v.Schema.Context.Encode(v.ValueToInterface(val, nil)))
+	return res.Validate(cue.Concrete(true))
+}
+
+func isConcreteValue(val parser.Value) bool {
+	switch t := val.(type) {
+	case *parser.StringValue, *parser.IntValue, *parser.FloatValue, *parser.BoolValue:
+		return true
+	case *parser.ArrayValue:
+		for _, e := range t.Elements {
+			if !isConcreteValue(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// variableNames returns the names of the variables referenced by val.
+func variableNames(val parser.Value) []string {
+	var names []string
+	var walk func(parser.Value)
+	walk = func(val parser.Value) {
+		switch t := val.(type) {
+		case *parser.VariableReferenceValue:
+			names = append(names, strings.TrimLeft(t.Name, "@$"))
+		case *parser.ArrayValue:
+			for _, e := range t.Elements {
+				walk(e)
+			}
+		case *parser.BinaryExpression:
+			walk(t.Left)
+			walk(t.Right)
+		case *parser.UnaryExpression:
+			walk(t.Right)
+		case *parser.ConditionalArrayElements:
+			walk(t.Condition)
+			for _, e := range t.Then {
+				walk(e)
+			}
+			for _, e := range t.Else {
+				walk(e)
+			}
+		}
+	}
+	walk(val)
+	return names
+}
+
+// walkTemplateUses calls fn for every #use in defs, including those nested in
+// objects, conditional blocks, slots and #fill blocks.
+func walkTemplateUses(defs []parser.Definition, fn func(*parser.TemplateInstantiation)) {
+	for _, def := range defs {
+		switch d := def.(type) {
+		case *parser.TemplateInstantiation:
+			fn(d)
+			for _, b := range d.Blocks {
+				walkTemplateUses(b.Body, fn)
+			}
+		case *parser.SlotBlock:
+			walkTemplateUses(d.Body, fn)
+		case *parser.ObjectNode:
+			walkTemplateUses(d.Subnode.Definitions, fn)
+		case *parser.IfBlock:
+			walkTemplateUses(d.Then, fn)
+			walkTemplateUses(d.Else, fn)
+		case *parser.SwitchBlock:
+			for _, b := range d.Branches() {
+				walkTemplateUses(b.Body, fn)
+			}
+		case *parser.ForeachBlock:
+			walkTemplateUses(d.Body, fn)
+		}
+	}
+}
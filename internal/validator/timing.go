@@ -0,0 +1,203 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// DefaultTimingBudget is the fraction of its period a thread may use before
+// CheckTiming warns.
+const DefaultTimingBudget = 0.8
+
+// GAMTiming is the estimated cost of one GAM of a thread.
+type GAMTiming struct {
+	Name     string
+	Class    string
+	CostNs   float64
+	Modelled bool // false when the class declares no '#meta.cost_ns'
+}
+
+// ThreadTiming is the estimated load of a RealTimeThread in one state.
+type ThreadTiming struct {
+	Application string
+	State       string
+	Thread      string
+	Node        *index.ProjectNode
+	Frequency   float64 // Hz of the synchronising input; 0 if unknown
+	CPUs        int64   // affinity mask; 0 if not set
+	CostNs      float64
+	GAMs        []GAMTiming
+}
+
+// PeriodNs returns the cycle time of the thread, or 0 if its frequency is
+// unknown.
+func (t ThreadTiming) PeriodNs() float64 {
+	if t.Frequency <= 0 {
+		return 0
+	}
+	return 1e9 / t.Frequency
+}
+
+// Load returns the estimated fraction of the period the thread uses.
+func (t ThreadTiming) Load() float64 {
+	if p := t.PeriodNs(); p > 0 {
+		return t.CostNs / p
+	}
+	return 0
+}
+
+// CheckTiming estimates the load of every thread from the frequency of its
+// synchronising input and the '#meta.cost_ns' of the classes of its GAMs.
+// It warns about threads whose load exceeds budget and about threads of
+// the same state whose CPUs masks overlap.
+func (v *Validator) CheckTiming(ctx context.Context, budget float64) []ThreadTiming {
+	var apps []*index.ProjectNode
+	v.Tree.Walk(func(n *index.ProjectNode) {
+		if v.isActive(n) && v.getNodeClass(n) == "RealTimeApplication" {
+			apps = append(apps, n)
+		}
+	})
+	var timings []ThreadTiming
+	for _, app := range apps {
+		for _, state := range v.Tree.ApplicationStates(app) {
+			if ctx.Err() != nil {
+				return timings
+			}
+			var stateTimings []ThreadTiming
+			for _, thread := range v.Tree.StateThreads(state) {
+				t := v.threadTiming(thread)
+				t.Application, t.State = app.Name, state.Name
+				if load := t.Load(); load > budget {
+					v.report(thread, "thread_overload", LevelWarning,
+						fmt.Sprintf("Thread '%s' of state '%s' is estimated at %.0f ns per cycle, %.0f%% of its %.0f ns period (budget %.0f%%)",
+							thread.Name, state.Name, t.CostNs, load*100, t.PeriodNs(), budget*100),
+						v.getNodePosition(thread), v.getNodeFile(thread))
+				}
+				for _, other := range stateTimings {
+					if t.CPUs&other.CPUs != 0 {
+						v.report(thread, "shared_cpus", LevelWarning,
+							fmt.Sprintf("Threads '%s' and '%s' of state '%s' share CPUs 0x%x", other.Thread, t.Thread, state.Name, t.CPUs&other.CPUs),
+							v.getNodePosition(thread), v.getNodeFile(thread))
+					}
+				}
+				stateTimings = append(stateTimings, t)
+			}
+			timings = append(timings, stateTimings...)
+		}
+	}
+	v.sortDiagnostics()
+	return timings
+}
+
+func (v *Validator) threadTiming(thread *index.ProjectNode) ThreadTiming {
+	t := ThreadTiming{Thread: thread.Name, Node: thread}
+	if fs := v.getFields(thread)["CPUs"]; len(fs) > 0 {
+		if mask, ok := v.ValueToInterface(fs[0].Value, thread).(int64); ok {
+			t.CPUs = mask
+		}
+	}
+	t.Frequency = v.threadFrequency(thread)
+	for _, gam := range v.getThreadGAMs(thread) {
+		g := GAMTiming{Name: gam.Name, Class: v.getNodeClass(gam)}
+		g.CostNs, g.Modelled = v.gamCost(gam, g.Class)
+		t.CostNs += g.CostNs
+		t.GAMs = append(t.GAMs, g)
+	}
+	return t
+}
+
+// threadFrequency returns the Frequency of the first synchronising input of
+// thread that has one, or 0.
+func (v *Validator) threadFrequency(thread *index.ProjectNode) float64 {
+	for _, gam := range v.getThreadGAMs(thread) {
+		if syncs := v.gamSyncInputs(gam); len(syncs) > 0 {
+			if fs := v.getFields(syncs[0].signal)["Frequency"]; len(fs) > 0 {
+				return toFloat(v.ValueToInterface(fs[0].Value, syncs[0].signal))
+			}
+		}
+	}
+	return 0
+}
+
+// gamCost evaluates the '#meta.cost_ns' model of the class of gam. The model
+// is a number or an expression over the signals of the GAM: 'elements' (all
+// elements of its input and output signals), 'bytes', 'inputs' and
+// 'outputs' (the number of signals).
+func (v *Validator) gamCost(gam *index.ProjectNode, cls string) (float64, bool) {
+	if v.Schema == nil || cls == "" {
+		return 0, false
+	}
+	model := v.Schema.Value.LookupPath(cue.ParsePath(fmt.Sprintf("#Classes.%s.#meta.cost_ns", cls)))
+	if !model.Exists() {
+		return 0, false
+	}
+	if f, err := model.Float64(); err == nil {
+		return f, true
+	}
+	expr, err := model.String()
+	if err != nil {
+		return 0, false
+	}
+	config, err := parser.NewParser("cost_ns = " + expr).Parse()
+	if err != nil || len(config.Definitions) == 0 {
+		return 0, false
+	}
+	f, ok := config.Definitions[0].(*parser.Field)
+	if !ok {
+		return 0, false
+	}
+	vars := v.gamCostVariables(gam)
+	return toFloat(v.evaluateCost(f.Value, vars)), true
+}
+
+func (v *Validator) gamCostVariables(gam *index.ProjectNode) map[string]interface{} {
+	var elements, bytes, inputs, outputs int64
+	for _, dir := range []string{"InputSignals", "OutputSignals"} {
+		container := gam.Children[dir]
+		if container == nil {
+			continue
+		}
+		for _, sig := range container.Children {
+			if dir == "InputSignals" {
+				inputs++
+			} else {
+				outputs++
+			}
+			size := v.getSignalByteSize(sig)
+			bytes += size
+			if typeSize := v.getTypeSize(v.signalType(sig, v.getFields(sig))); typeSize > 0 && size > 0 {
+				elements += size / int64(typeSize)
+			} else {
+				elements++
+			}
+		}
+	}
+	return map[string]interface{}{"elements": elements, "bytes": bytes, "inputs": inputs, "outputs": outputs}
+}
+
+func (v *Validator) evaluateCost(val parser.Value, vars map[string]interface{}) interface{} {
+	switch t := val.(type) {
+	case *parser.ReferenceValue:
+		return vars[t.Value]
+	case *parser.BinaryExpression:
+		return v.evaluateBinary(v.evaluateCost(t.Left, vars), t.Operator.Type, v.evaluateCost(t.Right, vars))
+	case *parser.UnaryExpression:
+		return v.evaluateUnary(t.Operator.Type, v.evaluateCost(t.Right, vars))
+	}
+	return v.ValueToInterface(val, nil)
+}
+
+func toFloat(val interface{}) float64 {
+	switch n := val.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
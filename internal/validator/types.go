@@ -0,0 +1,149 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+)
+
+// AttachSchema registers the types declared in the schema in tree, so that
+// sizes, hovers and expansions see them. It is the only place a tree learns
+// the schema types.
+func AttachSchema(tree *index.ProjectTree, s *schema.Schema) {
+	tree.SetSchemaTypes(SchemaTypes(s))
+}
+
+// SchemaTypes reads the user-defined types of the #Types section of the
+// schema. An enum gives its storage Type and its Values; a struct lists
+// its members under Fields.
+func SchemaTypes(s *schema.Schema) map[string]*index.TypeDef {
+	if s == nil {
+		return nil
+	}
+	typesVal := s.Value.LookupPath(cue.ParsePath("#Types"))
+	if typesVal.Err() != nil {
+		return nil
+	}
+	types := make(map[string]*index.TypeDef)
+	iter, err := typesVal.Fields()
+	if err != nil {
+		return nil
+	}
+	for iter.Next() {
+		name := iter.Selector().Unquoted()
+		val := iter.Value()
+		t := &index.TypeDef{Name: name}
+		if base, err := val.LookupPath(cue.ParsePath("Type")).String(); err == nil {
+			t.Base = base
+			values, err := val.LookupPath(cue.ParsePath("Values")).List()
+			if err == nil {
+				for values.Next() {
+					if label, err := values.Value().String(); err == nil {
+						t.Values = append(t.Values, label)
+					}
+				}
+			}
+		} else if members, err := val.LookupPath(cue.ParsePath("Fields")).Fields(); err == nil {
+			for members.Next() {
+				m := index.TypeMember{Name: members.Selector().Unquoted(), NumberOfElements: 1}
+				m.Type, _ = members.Value().LookupPath(cue.ParsePath("Type")).String()
+				n, _ := members.Value().LookupPath(cue.ParsePath("NumberOfElements")).Default()
+				if i, err := n.Int64(); err == nil {
+					m.NumberOfElements = i
+				}
+				t.Members = append(t.Members, m)
+			}
+		}
+		types[name] = t
+	}
+	return types
+}
+
+func (v *Validator) isValidType(t string) bool {
+	return isValidType(t) || v.Tree.LookupType(t) != nil
+}
+
+// CheckTypes reports struct members without a Type and structs that
+// contain themselves.
+func (v *Validator) CheckTypes(ctx context.Context) {
+	for _, t := range v.Tree.UserTypes() {
+		if ctx.Err() != nil {
+			return
+		}
+		if t.IsEnum() {
+			continue
+		}
+		for _, m := range t.Members {
+			if m.Type == "" && m.Node != nil {
+				v.report(m.Node, "missing_member_type", LevelError,
+					fmt.Sprintf("Member '%s' of type '%s' must define Type", m.Name, t.Name),
+					v.getNodePosition(m.Node), v.getNodeFile(m.Node))
+			}
+		}
+		if cycle := v.typeCycle(t.Name, nil); cycle != nil && t.Node != nil {
+			v.report(t.Node, "recursive_type", LevelError,
+				fmt.Sprintf("Type '%s' contains itself (%s)", t.Name, strings.Join(cycle, " → ")),
+				v.getNodePosition(t.Node), v.getNodeFile(t.Node))
+		}
+	}
+}
+
+// typeCycle returns the chain of member types leading from name back to
+// the first type of path, or nil.
+func (v *Validator) typeCycle(name string, path []string) []string {
+	if len(path) > 0 && name == path[0] {
+		return append(path, name)
+	}
+	if containsString(path, name) {
+		return nil
+	}
+	t := v.Tree.LookupType(name)
+	if t == nil {
+		return nil
+	}
+	path = append(path, name)
+	for _, m := range t.Members {
+		if cycle := v.typeCycle(m.Type, path); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// checkEnumElement reports a value that is neither a label nor an integer
+// of the enum.
+func (v *Validator) checkEnumElement(node *index.ProjectNode, name string, f index.EvaluatedField, e parser.Value, t *index.TypeDef) bool {
+	switch val := e.(type) {
+	case *parser.IntValue:
+		if inTypeRange(e, t.Base) {
+			return true
+		}
+		pos := elementPosition(f, e)
+		v.report(node, "signal_value_range", LevelError,
+			fmt.Sprintf("%s of signal '%s' contains '%s' which is out of range for enum %s stored as %s (%s)", name, node.RealName, valueText(e), t.Name, t.Base, index.TypeRange(t.Base)),
+			pos, f.File)
+		return false
+	case *parser.StringValue:
+		if containsString(t.Values, val.Value) {
+			return true
+		}
+	case *parser.ReferenceValue:
+		if containsString(t.Values, val.Value) {
+			return true
+		}
+	case *parser.FloatValue, *parser.BoolValue:
+	default:
+		return true
+	}
+	pos := elementPosition(f, e)
+	v.report(node, "signal_value_type", LevelError,
+		fmt.Sprintf("%s of signal '%s' contains '%s' which is not a value of enum %s (%s)", name, node.RealName, valueText(e), t.Name, strings.Join(t.Values, ", ")),
+		pos, f.File)
+	return false
+}
@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 
 	"cuelang.org/go/cue"
-	"cuelang.org/go/cue/errors"
 
 	"github.com/marte-community/marte-dev-tools/internal/index"
 	"github.com/marte-community/marte-dev-tools/internal/parser"
@@ -28,6 +28,9 @@ type Diagnostic struct {
 	Message  string
 	Position parser.Position
 	File     string
+	// Rule is the tag of the check reporting the diagnostic, as used by
+	// ignore pragmas.
+	Rule string
 }
 
 type Validator struct {
@@ -40,13 +43,17 @@ type Validator struct {
 	mu              sync.Mutex
 	ActiveNodes     map[*index.ProjectNode]bool
 	ActiveFragments map[*index.Fragment]bool
+	InactiveRanges  []InactiveRange
 	muActive        sync.Mutex
+	// branches records the #if branches taken while collecting active
+	// nodes, when CheckVariants asks for it.
+	branches map[*parser.IfBlock]*branchOutcome
 }
 
-func NewValidator(tree *index.ProjectTree, projectRoot string, overrides map[string]string) *Validator {
+func NewValidator(tree *index.ProjectTree, projectRoot string, overrides map[string]string, opts ...schema.Option) *Validator {
 	v := &Validator{
 		Tree:            tree,
-		Schema:          schema.LoadFullSchema(projectRoot),
+		Schema:          schema.LoadFullSchema(projectRoot, opts...),
 		Overrides:       make(map[string]parser.Value),
 		Variables:       make(map[string]parser.Value),
 		RawOverrides:    overrides,
@@ -74,7 +81,7 @@ func NewValidator(tree *index.ProjectTree, projectRoot string, overrides map[str
 
 			if valStr, ok := overrides[k]; ok {
 				if shouldAutoQuoteWithDef(valStr, varInfo.Def) {
-					p := parser.NewParser("Temp = \"" + valStr + "\"")
+					p := parser.NewParser("Temp = " + parser.QuoteString(valStr))
 					cfg, _ := p.Parse()
 					if cfg != nil && len(cfg.Definitions) > 0 {
 						if f, ok := cfg.Definitions[0].(*parser.Field); ok {
@@ -115,6 +122,9 @@ func (v *Validator) collectActiveNodes(ctx context.Context, node *index.ProjectN
 	}
 
 	written := make(map[string]bool)
+	// The conditions of template bodies depend on the arguments of each
+	// #use and are not recorded as branches.
+	templateDepth := 0
 	var processEval func([]index.EvaluatedDefinition, *index.ProjectNode)
 	processEval = func(evaluated []index.EvaluatedDefinition, node *index.ProjectNode) {
 		for _, ed := range evaluated {
@@ -169,7 +179,11 @@ func (v *Validator) collectActiveNodes(ctx context.Context, node *index.ProjectN
 			case *parser.IfBlock:
 				cond := v.Tree.EvaluateValue(d.Condition, ed.Ctx)
 				id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
-				if v.Tree.IsTrue(cond) {
+				taken := v.Tree.IsTrue(cond)
+				if templateDepth == 0 {
+					v.recordBranch(d, ed.File, taken)
+				}
+				if taken {
 					// Activate fragments for 'Then' branch
 					v.muActive.Lock()
 					for _, f := range node.Fragments {
@@ -189,6 +203,18 @@ func (v *Validator) collectActiveNodes(ctx context.Context, node *index.ProjectN
 					v.muActive.Unlock()
 					processEval(v.Tree.EvaluateDefinitions(d.Else, ed.Ctx, ed.File), node)
 				}
+			case *parser.SwitchBlock:
+				id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
+				if branch := v.Tree.SelectSwitchBranch(d, ed.Ctx); branch != nil {
+					v.muActive.Lock()
+					for _, f := range node.Fragments {
+						if f.IsConditional && f.BranchID == id+":"+branch.ID {
+							v.ActiveFragments[f] = true
+						}
+					}
+					v.muActive.Unlock()
+					processEval(v.Tree.EvaluateDefinitions(branch.Body, ed.Ctx, ed.File), node)
+				}
 			case *parser.ForeachBlock:
 				iterable := v.Tree.EvaluateValue(d.Iterable, ed.Ctx)
 				id := fmt.Sprintf("%d:%d", d.Position.Line, d.Position.Column)
@@ -224,7 +250,9 @@ func (v *Validator) collectActiveNodes(ctx context.Context, node *index.ProjectN
 					}
 				}
 				v.muActive.Unlock()
+				templateDepth++
 				processEval(v.Tree.EvaluateDefinitions(d.Body, ed.Ctx, ed.File), node)
+				templateDepth--
 			}
 		}
 	}
@@ -238,14 +266,10 @@ func (v *Validator) collectActiveNodes(ctx context.Context, node *index.ProjectN
 	}
 }
 
-func (v *Validator) ValidateProject(ctx context.Context) {
-	if v.Tree == nil {
-		return
-	}
-	// Initial full resolution (before activation pass, as ActiveFragments is empty)
-	v.Tree.ResolveFields(nil)
-	v.Tree.ResolveReferences(nil)
-
+// activate collects the active nodes and fragments of the configuration the
+// variables select, and resolves the fields and references of the active
+// fragments.
+func (v *Validator) activate(ctx context.Context) {
 	// Multi-pass active node collection to handle variables defined in conditional blocks
 	for pass := 0; pass < 5; pass++ { // Max 5 passes to avoid infinite loops
 		evalCtx := &index.EvaluationContext{Variables: v.Variables, Tree: v.Tree}
@@ -258,7 +282,7 @@ func (v *Validator) ValidateProject(ctx context.Context) {
 
 				if valStr, ok := v.RawOverrides[k]; ok {
 					if shouldAutoQuoteWithDef(valStr, varInfo.Def) {
-						p := parser.NewParser("Temp = \"" + valStr + "\"")
+						p := parser.NewParser("Temp = " + parser.QuoteString(valStr))
 						cfg, _ := p.Parse()
 						if cfg != nil && len(cfg.Definitions) > 0 {
 							if f, ok := cfg.Definitions[0].(*parser.Field); ok {
@@ -291,6 +315,17 @@ func (v *Validator) ValidateProject(ctx context.Context) {
 			break
 		}
 	}
+}
+
+func (v *Validator) ValidateProject(ctx context.Context) {
+	if v.Tree == nil {
+		return
+	}
+	// Initial full resolution (before activation pass, as ActiveFragments is empty)
+	v.Tree.ResolveFields(nil)
+	v.Tree.ResolveReferences(nil)
+
+	v.activate(ctx)
 
 	evalCtx := &index.EvaluationContext{Variables: v.Variables, Tree: v.Tree}
 
@@ -370,9 +405,20 @@ func (v *Validator) ValidateProject(ctx context.Context) {
 	v.CheckDataSourceThreading(ctx)
 	v.CheckINOUTOrdering(ctx)
 	v.CheckSignalConsistency(ctx)
+	v.CheckSignalRules(ctx)
 	v.CheckVariables(ctx)
 	v.CheckUnresolvedVariables(ctx)
+	v.CheckVariableUsage(ctx)
 	v.CheckConditionalReferences(ctx)
+	v.CheckSwitchBlocks(ctx)
+	v.CheckTemplates(ctx)
+	v.CheckTypes(ctx)
+	v.CheckStateMachines(ctx)
+	v.CheckRealTimeApplications(ctx)
+	v.CheckNaming(ctx)
+	v.CheckRules(ctx)
+	v.CheckRelease(ctx)
+	v.sortDiagnostics()
 }
 
 func (v *Validator) validateNode(ctx context.Context, node *index.ProjectNode, evalCtx *index.EvaluationContext) {
@@ -651,7 +697,7 @@ func (v *Validator) validateTypeField(f index.EvaluatedField, node *index.Projec
 		return
 	}
 
-	if !isValidType(typeName) {
+	if !v.isValidType(typeName) {
 		v.report(node, "invalid_type", LevelError,
 			fmt.Sprintf("Invalid Type '%s'", typeName),
 			f.Raw.Position, f.File)
@@ -659,6 +705,15 @@ func (v *Validator) validateTypeField(f index.EvaluatedField, node *index.Projec
 }
 
 func (v *Validator) validateGenericField(f index.EvaluatedField, node *index.ProjectNode) {
+	if node != nil && v.isSymbolField(f, node) {
+		return
+	}
+	if node != nil && (f.Raw.Name == "Default" || f.Raw.Name == "Value") {
+		if t := v.Tree.LookupType(v.signalType(node, node.Fields)); t != nil && t.IsEnum() {
+			// Identifiers are enum labels, checked in validateSignalValues.
+			return
+		}
+	}
 	v.validateValue(f.Value, node, f.File)
 }
 
@@ -708,7 +763,7 @@ func (v *Validator) isSuppressed(warningType string, node *index.ProjectNode) bo
 	}
 
 	// Legacy tag support
-	if warningType == "unused_gam" || warningType == "unused_signal" {
+	if isUnusedTag(warningType) {
 		if v.isGloballyAllowed("unused", file) {
 			return true
 		}
@@ -718,6 +773,9 @@ func (v *Validator) isSuppressed(warningType string, node *index.ProjectNode) bo
 			return true
 		}
 	}
+	if strings.HasPrefix(warningType, "schema_") && v.isGloballyAllowed("schema_validation", file) {
+		return true
+	}
 	
 	if node == nil {
 		return false
@@ -725,12 +783,15 @@ func (v *Validator) isSuppressed(warningType string, node *index.ProjectNode) bo
 
 	// Check local pragmas on the node
 	checkTags := []string{warningType}
-	if warningType == "unused_gam" || warningType == "unused_signal" {
+	if isUnusedTag(warningType) {
 		checkTags = append(checkTags, "unused")
 	}
 	if warningType == "implicit_signal" {
 		checkTags = append(checkTags, "implicit")
 	}
+	if strings.HasPrefix(warningType, "schema_") && warningType != "schema_validation" {
+		checkTags = append(checkTags, "schema_validation")
+	}
 
 	for _, tag := range checkTags {
 		prefix1 := fmt.Sprintf("allow(%s)", tag)
@@ -791,7 +852,39 @@ func (v *Validator) report(node *index.ProjectNode, tag string, level Diagnostic
 		Message:  msg,
 		Position: pos,
 		File:     file,
+		Rule:     tag,
+	})
+}
+
+// sortDiagnostics orders the diagnostics by file, position and rule, so that
+// they do not depend on the scheduling of the workers, and drops those
+// reported more than once, as by several activation passes.
+func (v *Validator) sortDiagnostics() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	sort.SliceStable(v.Diagnostics, func(i, j int) bool {
+		a, b := v.Diagnostics[i], v.Diagnostics[j]
+		switch {
+		case a.File != b.File:
+			return a.File < b.File
+		case a.Position.Line != b.Position.Line:
+			return a.Position.Line < b.Position.Line
+		case a.Position.Column != b.Position.Column:
+			return a.Position.Column < b.Position.Column
+		case a.Rule != b.Rule:
+			return a.Rule < b.Rule
+		case a.Level != b.Level:
+			return a.Level < b.Level
+		}
+		return a.Message < b.Message
 	})
+	unique := v.Diagnostics[:0]
+	for i, d := range v.Diagnostics {
+		if i == 0 || d != v.Diagnostics[i-1] {
+			unique = append(unique, d)
+		}
+	}
+	v.Diagnostics = unique
 }
 
 func shouldAutoQuoteWithDef(valStr string, def *parser.VariableDefinition) bool {
@@ -848,7 +941,7 @@ func (v *Validator) validateWithCUE(node *index.ProjectNode, className string) {
 		// Report errors
 
 		// Parse CUE error to diagnostic
-		v.reportCUEError(err, node)
+		v.reportCUEError(err, node, lookupName, data)
 	}
 
 	// Check Parent constraints from #meta
@@ -923,16 +1016,6 @@ func (v *Validator) getMetaType(node *index.ProjectNode) string {
 }
 
 
-func (v *Validator) reportCUEError(err error, node *index.ProjectNode) {
-	list := errors.Errors(err)
-	for _, e := range list {
-		msg := e.Error()
-		v.report(node, "schema_validation", LevelError,
-			fmt.Sprintf("Schema Validation Error: %v", msg),
-			v.getNodePosition(node), v.getNodeFile(node))
-	}
-}
-
 func (v *Validator) nodeToMapWithDepth(node *index.ProjectNode, depth int) map[string]interface{} {
 	m := make(map[string]interface{})
 	fields := v.getFields(node)
@@ -1126,7 +1209,7 @@ func (v *Validator) validateSignal(node *index.ProjectNode, fields map[string][]
 			return
 		}
 
-		if !isValidType(typeStr) {
+		if !v.isValidType(typeStr) {
 			v.report(node, "invalid_signal_type", LevelError,
 				fmt.Sprintf("Invalid Type '%s' for Signal '%s'", typeStr, node.RealName),
 				typeFields[0].Raw.Position, typeFields[0].File)
@@ -1134,6 +1217,7 @@ func (v *Validator) validateSignal(node *index.ProjectNode, fields map[string][]
 	}
 	
 	v.validateByteSize(node, fields)
+	v.validateSignalValues(node, fields)
 }
 
 func (v *Validator) validateGAM(node *index.ProjectNode) {
@@ -1244,7 +1328,7 @@ func (v *Validator) validateGAMSignal(gamNode, signalNode *index.ProjectNode, di
 		} else {
 			// Check Type validity even for implicit
 			typeVal := v.getFieldValue(typeFields[0], signalNode)
-			if !isValidType(typeVal) {
+			if !v.isValidType(typeVal) {
 				v.report(signalNode, "invalid_signal_type", LevelError,
 					fmt.Sprintf("Invalid Type '%s' for Signal '%s'", typeVal, signalNode.RealName),
 					typeFields[0].Raw.Position, typeFields[0].File)
@@ -1303,7 +1387,7 @@ func (v *Validator) validateGAMSignal(gamNode, signalNode *index.ProjectNode, di
 		// Check Type validity if present
 		if typeFields, ok := fields["Type"]; ok && len(typeFields) > 0 {
 			typeVal := v.getFieldValue(typeFields[0], signalNode)
-			if !isValidType(typeVal) {
+			if !v.isValidType(typeVal) {
 				v.report(signalNode, "invalid_signal_type", LevelError,
 					fmt.Sprintf("Invalid Type '%s' for Signal '%s'", typeVal, signalNode.RealName),
 					typeFields[0].Raw.Position, typeFields[0].File)
@@ -1314,8 +1398,11 @@ func (v *Validator) validateGAMSignal(gamNode, signalNode *index.ProjectNode, di
 	// Validate ByteSize
 	v.validateByteSize(signalNode, fields)
 
-	// Validate Value initialization
-	if valField, hasValue := fields["Value"]; hasValue && len(valField) > 0 {
+	// Validate Default/Value shape and element types
+	v.validateSignalValues(signalNode, fields)
+
+	// Validate Value initialization (arrays are checked element-wise above)
+	if valField, hasValue := fields["Value"]; hasValue && len(valField) > 0 && !isArrayValue(valField[0].Value) {
 		var typeStr string
 		if typeFields, ok := fields["Type"]; ok && len(typeFields) > 0 {
 			typeStr = v.getFieldValue(typeFields[0], signalNode)
@@ -1508,19 +1595,8 @@ func (v *Validator) validateByteSize(node *index.ProjectNode, fields map[string]
 }
 
 func (v *Validator) getTypeSize(typeName string) int {
-	switch typeName {
-	case "uint8", "int8", "char8", "bool":
-		return 1
-	case "uint16", "int16":
-		return 2
-	case "uint32", "int32", "float32":
-		return 4
-	case "uint64", "int64", "float64":
-		return 8
-	case "string":
-		return -1 // Variable size, usually pointer or handled differently
-	}
-	return 0 // Unknown
+	// -1 for variable sizes (string), 0 for unknown types.
+	return int(v.Tree.TypeSize(typeName))
 }
 
 func (v *Validator) checkSignalProperty(gamSig, dsSig *index.ProjectNode, prop string) {
@@ -1703,13 +1779,16 @@ func (v *Validator) checkUnusedRecursive(ctx context.Context, node *index.Projec
 func (v *Validator) getNodePosition(node *index.ProjectNode) parser.Position {
 	v.muActive.Lock()
 	defer v.muActive.Unlock()
+	// Fragments of conditional blocks have no object position of their own.
 	for _, frag := range node.Fragments {
-		if v.ActiveFragments[frag] {
+		if v.ActiveFragments[frag] && frag.ObjectPos.Line > 0 {
 			return frag.ObjectPos
 		}
 	}
-	if len(node.Fragments) > 0 {
-		return node.Fragments[0].ObjectPos
+	for _, frag := range node.Fragments {
+		if frag.ObjectPos.Line > 0 {
+			return frag.ObjectPos
+		}
 	}
 	return parser.Position{Line: 1, Column: 1}
 }
@@ -1718,7 +1797,12 @@ func (v *Validator) getNodeFile(node *index.ProjectNode) string {
 	v.muActive.Lock()
 	defer v.muActive.Unlock()
 	for _, frag := range node.Fragments {
-		if v.ActiveFragments[frag] {
+		if v.ActiveFragments[frag] && frag.ObjectPos.Line > 0 {
+			return frag.File
+		}
+	}
+	for _, frag := range node.Fragments {
+		if frag.ObjectPos.Line > 0 {
 			return frag.File
 		}
 	}
@@ -1804,44 +1888,10 @@ func (v *Validator) CheckDataSourceThreading(ctx context.Context) {
 }
 
 func (v *Validator) checkAppDataSourceThreading(ctx context.Context, appNode *index.ProjectNode) {
-	// 2. Find States
-	var statesNode *index.ProjectNode
-	if s, ok := appNode.Children["States"]; ok {
-		statesNode = s
-	} else {
-		for _, child := range appNode.Children {
-			if cls, ok := child.Metadata["Class"]; ok && cls == "StateMachine" {
-				statesNode = child
-				break
-			}
-		}
-	}
-
-	if statesNode == nil {
-		return
-	}
-
-	// 3. Iterate States
-	for _, state := range statesNode.Children {
+	for _, state := range v.Tree.ApplicationStates(appNode) {
 		dsUsage := make(map[*index.ProjectNode]string) // DS Node -> Thread Name
-		var threads []*index.ProjectNode
-
-		// Search for threads in the state (either direct children or inside "Threads" container)
-		for _, child := range state.Children {
-			if child.RealName == "Threads" {
-				for _, t := range child.Children {
-					if cls, ok := t.Metadata["Class"]; ok && cls == "RealTimeThread" {
-						threads = append(threads, t)
-					}
-				}
-			} else {
-				if cls, ok := child.Metadata["Class"]; ok && cls == "RealTimeThread" {
-					threads = append(threads, child)
-				}
-			}
-		}
 
-		for _, thread := range threads {
+		for _, thread := range v.Tree.StateThreads(state) {
 			gams := v.getThreadGAMs(thread)
 			for _, gam := range gams {
 				dss := v.getGAMDataSources(gam)
@@ -1944,39 +1994,8 @@ func (v *Validator) CheckINOUTOrdering(ctx context.Context) {
 }
 
 func (v *Validator) checkAppINOUTOrdering(ctx context.Context, appNode *index.ProjectNode) {
-	var statesNode *index.ProjectNode
-	if s, ok := appNode.Children["States"]; ok {
-		statesNode = s
-	} else {
-		for _, child := range appNode.Children {
-			if cls, ok := child.Metadata["Class"]; ok && cls == "StateMachine" {
-				statesNode = child
-				break
-			}
-		}
-	}
-
-	if statesNode == nil {
-		return
-	}
-
-	for _, state := range statesNode.Children {
-		var threads []*index.ProjectNode
-		for _, child := range state.Children {
-			if child.RealName == "Threads" {
-				for _, t := range child.Children {
-					if cls, ok := t.Metadata["Class"]; ok && cls == "RealTimeThread" {
-						threads = append(threads, t)
-					}
-				}
-			} else {
-				if cls, ok := child.Metadata["Class"]; ok && cls == "RealTimeThread" {
-					threads = append(threads, child)
-				}
-			}
-		}
-
-		for _, thread := range threads {
+	for _, state := range v.Tree.ApplicationStates(appNode) {
+		for _, thread := range v.Tree.StateThreads(state) {
 			producedSignals := make(map[*index.ProjectNode]map[string][]*index.ProjectNode)
 			consumedSignals := make(map[*index.ProjectNode]map[string]bool)
 
@@ -2350,39 +2369,52 @@ func (v *Validator) checkValueForConditionalRef(val parser.Value, node *index.Pr
 }
 
 func (v *Validator) checkTemplateUse(inst *parser.TemplateInstantiation, file string) {
-	tdef, ok := v.Tree.Templates[inst.Template]
-	if !ok {
+	if _, ok := v.Tree.Templates[inst.Template]; !ok {
 		v.report(nil, "unknown_template", LevelError,
 			fmt.Sprintf("Unknown template: '%s'", inst.Template),
 			inst.Position, file)
 		return
 	}
+	chain, err := v.Tree.TemplateChain(inst.Template)
+	if err != nil {
+		// Reported on the template definition.
+		return
+	}
+	params := index.TemplateParameters(chain)
 
 	// Check arguments
 	provided := make(map[string]bool)
 	for _, arg := range inst.Arguments {
 		provided[arg.Name] = true
 		// Find param
-		found := false
-		for _, p := range tdef.Parameters {
-			if p.Name == arg.Name {
-				found = true
+		var param *parser.TemplateParameter
+		for i := range params {
+			if params[i].Name == arg.Name {
+				param = &params[i]
 				break
 			}
 		}
-		if !found {
+		if param == nil {
 			v.report(nil, "invalid_template_arg", LevelError,
 				fmt.Sprintf("Template '%s' has no parameter named '%s'", inst.Template, arg.Name),
-				inst.Position, file)
+				arg.Position, file)
+			continue
+		}
+		if err := v.templateArgMismatch(*param, arg.Value); err != nil {
+			v.report(nil, "template_arg_mismatch", LevelError,
+				fmt.Sprintf("Argument '%s' of template '%s' does not match '%s': %v", arg.Name, inst.Template, param.TypeExpr, err),
+				arg.Position, file)
 		}
 	}
 
 	// Check missing mandatory params
-	for _, p := range tdef.Parameters {
+	for _, p := range params {
 		if p.DefaultValue == nil && !provided[p.Name] {
 			v.report(nil, "missing_template_arg", LevelError,
 				fmt.Sprintf("Missing mandatory argument '%s' for template '%s'", p.Name, inst.Template),
 				inst.Position, file)
 		}
 	}
+
+	v.checkTemplateFills(inst, chain, file)
 }
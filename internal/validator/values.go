@@ -0,0 +1,337 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// validateSignalValues checks that the Default and Value fields of a signal
+// match the declared shape (NumberOfElements/NumberOfDimensions) and that
+// every element is compatible with the signal Type.
+//
+// MARTe writes matrices as nested arrays, outermost dimension first:
+//
+//	NumberOfDimensions = 2
+//	NumberOfElements = 6
+//	Default = { {1 2 3} {4 5 6} }
+func (v *Validator) validateSignalValues(node *index.ProjectNode, fields map[string][]index.EvaluatedField) {
+	// Ranges and Samples reshape the signal locally; the declared
+	// dimensions no longer describe the value.
+	if r, ok := fields["Ranges"]; ok && len(r) > 0 {
+		return
+	}
+	if s, ok := fields["Samples"]; ok && len(s) > 0 {
+		return
+	}
+
+	typeStr := v.signalType(node, fields)
+	if t := v.Tree.LookupType(typeStr); t != nil && !t.IsEnum() {
+		// Struct values are not checked member by member.
+		return
+	}
+	numElements, hasElements := v.signalIntProperty(node, fields, "NumberOfElements")
+	if !hasElements {
+		numElements = 1
+	}
+	numDimensions, hasDimensions := v.signalIntProperty(node, fields, "NumberOfDimensions")
+	if !hasDimensions {
+		numDimensions = 0
+		if numElements > 1 {
+			numDimensions = 1
+		}
+	}
+
+	for _, name := range []string{"Default", "Value"} {
+		fs, ok := fields[name]
+		if !ok || len(fs) == 0 || fs[0].Value == nil {
+			continue
+		}
+		f := fs[0]
+		if !v.checkValueShape(node, name, f, typeStr, numElements, numDimensions) {
+			continue
+		}
+		arr, isArray := f.Value.(*parser.ArrayValue)
+		// Scalar initial values are type-checked against the CUE type in
+		// validateGAMSignal.
+		if name == "Value" && !isArray {
+			continue
+		}
+		if isArray {
+			v.checkArrayElements(node, name, f, arr, typeStr)
+		} else {
+			v.checkValueElement(node, name, f, f.Value, typeStr)
+		}
+	}
+}
+
+// checkValueShape reports a shape mismatch between a value and the signal
+// declaration. It returns false when the value shape is invalid.
+func (v *Validator) checkValueShape(node *index.ProjectNode, name string, f index.EvaluatedField, typeStr string, numElements, numDimensions int64) bool {
+	arr, isArray := f.Value.(*parser.ArrayValue)
+	if !isArray {
+		if s, ok := f.Value.(*parser.StringValue); ok && s.Quoted && typeStr == "char8" {
+			if int64(len(s.Value)) > numElements {
+				v.report(node, "signal_value_shape", LevelError,
+					fmt.Sprintf("%s of signal '%s' has %d characters but NumberOfElements is %d", name, node.RealName, len(s.Value), numElements),
+					f.Raw.Position, f.File)
+				return false
+			}
+			return true
+		}
+		if numElements > 1 {
+			v.report(node, "signal_value_shape", LevelError,
+				fmt.Sprintf("%s of signal '%s' is a scalar but NumberOfElements is %d", name, node.RealName, numElements),
+				f.Raw.Position, f.File)
+			return false
+		}
+		return true
+	}
+
+	shape, regular := arr.Shape()
+	if !regular {
+		v.report(node, "signal_value_shape", LevelError,
+			fmt.Sprintf("%s of signal '%s' is not a regular matrix: all rows must have the same length", name, node.RealName),
+			f.Raw.Position, f.File)
+		return false
+	}
+
+	// A one-element array is accepted for scalar signals.
+	if numDimensions == 0 {
+		if len(shape) != 1 || shape[0] != 1 {
+			v.report(node, "signal_value_shape", LevelError,
+				fmt.Sprintf("%s of signal '%s' has shape %s but the signal is a scalar", name, node.RealName, formatShape(shape)),
+				f.Raw.Position, f.File)
+			return false
+		}
+		return true
+	}
+
+	if int64(len(shape)) != numDimensions {
+		v.report(node, "signal_value_shape", LevelError,
+			fmt.Sprintf("%s of signal '%s' has %d dimension(s) (shape %s) but NumberOfDimensions is %d", name, node.RealName, len(shape), formatShape(shape), numDimensions),
+			f.Raw.Position, f.File)
+		return false
+	}
+
+	total := int64(1)
+	for _, n := range shape {
+		total *= int64(n)
+	}
+	if total != numElements {
+		v.report(node, "signal_value_shape", LevelError,
+			fmt.Sprintf("%s of signal '%s' has %d elements (shape %s) but NumberOfElements is %d", name, node.RealName, total, formatShape(shape), numElements),
+			f.Raw.Position, f.File)
+		return false
+	}
+	return true
+}
+
+func (v *Validator) checkArrayElements(node *index.ProjectNode, name string, f index.EvaluatedField, arr *parser.ArrayValue, typeStr string) {
+	for _, e := range arr.Elements {
+		if inner, ok := e.(*parser.ArrayValue); ok {
+			v.checkArrayElements(node, name, f, inner, typeStr)
+			continue
+		}
+		if !v.checkValueElement(node, name, f, e, typeStr) {
+			// One diagnostic per value is enough.
+			return
+		}
+	}
+}
+
+// checkValueElement reports an element whose kind cannot be stored in a
+// signal of the given type. Elements that did not evaluate to a literal are
+// skipped.
+func (v *Validator) checkValueElement(node *index.ProjectNode, name string, f index.EvaluatedField, e parser.Value, typeStr string) bool {
+	var ok bool
+	switch typeStr {
+	case "uint8", "int8", "uint16", "int16", "uint32", "int32", "uint64", "int64":
+		switch e.(type) {
+		case *parser.IntValue:
+			ok = true
+		case *parser.FloatValue, *parser.StringValue, *parser.BoolValue:
+			ok = false
+		default:
+			return true
+		}
+	case "float32", "float64":
+		switch e.(type) {
+		case *parser.IntValue, *parser.FloatValue:
+			ok = true
+		case *parser.StringValue, *parser.BoolValue:
+			ok = false
+		default:
+			return true
+		}
+	case "bool":
+		switch t := e.(type) {
+		case *parser.BoolValue:
+			ok = true
+		case *parser.IntValue:
+			ok = t.Value == 0 || t.Value == 1
+		case *parser.FloatValue, *parser.StringValue:
+			ok = false
+		default:
+			return true
+		}
+	case "string", "char8":
+		switch e.(type) {
+		case *parser.StringValue:
+			ok = true
+		case *parser.IntValue:
+			ok = typeStr == "char8"
+		case *parser.FloatValue, *parser.BoolValue:
+			ok = false
+		default:
+			return true
+		}
+	default:
+		if t := v.Tree.LookupType(typeStr); t != nil && t.IsEnum() {
+			return v.checkEnumElement(node, name, f, e, t)
+		}
+		return true
+	}
+
+	pos := elementPosition(f, e)
+	if !ok {
+		v.report(node, "signal_value_type", LevelError,
+			fmt.Sprintf("%s of signal '%s' contains '%s' which is not a valid %s", name, node.RealName, valueText(e), typeStr),
+			pos, f.File)
+		return false
+	}
+	if !inTypeRange(e, typeStr) {
+		v.report(node, "signal_value_range", LevelError,
+			fmt.Sprintf("%s of signal '%s' contains '%s' which is out of range for %s (%s)", name, node.RealName, valueText(e), typeStr, index.TypeRange(typeStr)),
+			pos, f.File)
+		return false
+	}
+	return true
+}
+
+// elementPosition returns the position of an element of the value of f, or
+// the position of f for computed elements and values taken from variables,
+// which may be defined elsewhere.
+func elementPosition(f index.EvaluatedField, e parser.Value) parser.Position {
+	if pos := e.Pos(); pos.Line > 0 && hasLiteralAt(f.Raw.Value, pos) {
+		return pos
+	}
+	return f.Raw.Position
+}
+
+func hasLiteralAt(val parser.Value, pos parser.Position) bool {
+	if arr, ok := val.(*parser.ArrayValue); ok {
+		for _, e := range arr.Elements {
+			if hasLiteralAt(e, pos) {
+				return true
+			}
+		}
+		return false
+	}
+	return val != nil && val.Pos() == pos
+}
+
+// inTypeRange reports whether a numeric literal can be represented by a
+// base type. Integers are read from their source text so that values beyond
+// int64 are compared exactly.
+func inTypeRange(e parser.Value, typeStr string) bool {
+	switch t := e.(type) {
+	case *parser.IntValue:
+		if min, max, ok := index.IntTypeRange(typeStr); ok {
+			n, ok := new(big.Int).SetString(t.Raw, 0)
+			if !ok {
+				n = big.NewInt(t.Value)
+			}
+			return n.Cmp(min) >= 0 && n.Cmp(max) <= 0
+		}
+		if max, ok := index.FloatTypeMax(typeStr); ok {
+			return math.Abs(float64(t.Value)) <= max
+		}
+	case *parser.FloatValue:
+		if max, ok := index.FloatTypeMax(typeStr); ok {
+			return math.Abs(t.Value) <= max
+		}
+	}
+	return true
+}
+
+// signalType returns the Type of a signal, falling back to the DataSource
+// definition for GAM signals.
+func (v *Validator) signalType(node *index.ProjectNode, fields map[string][]index.EvaluatedField) string {
+	if typeFields, ok := fields["Type"]; ok && len(typeFields) > 0 {
+		return v.getFieldValue(typeFields[0], node)
+	}
+	if node.Target != nil {
+		if tFields, ok := node.Target.Fields["Type"]; ok && len(tFields) > 0 {
+			return v.getFieldValue(tFields[0], node.Target)
+		}
+		return node.Target.Metadata["Type"]
+	}
+	return ""
+}
+
+// signalIntProperty returns an integer property of a signal, falling back to
+// the DataSource definition for GAM signals.
+func (v *Validator) signalIntProperty(node *index.ProjectNode, fields map[string][]index.EvaluatedField, key string) (int64, bool) {
+	if fs, ok := fields[key]; ok && len(fs) > 0 {
+		return toInt64(v.ValueToInterface(fs[0].Value, node))
+	}
+	if node.Target != nil {
+		if fs, ok := node.Target.Fields[key]; ok && len(fs) > 0 {
+			return toInt64(v.ValueToInterface(fs[0].Value, node.Target))
+		}
+		if s, ok := node.Target.Metadata[key]; ok {
+			if i, err := strconv.ParseInt(s, 0, 64); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func toInt64(val interface{}) (int64, bool) {
+	switch i := val.(type) {
+	case int64:
+		return i, true
+	case int:
+		return int64(i), true
+	}
+	return 0, false
+}
+
+func formatShape(shape []int) string {
+	parts := make([]string, len(shape))
+	for i, n := range shape {
+		parts[i] = strconv.Itoa(n)
+	}
+	return "[" + strings.Join(parts, "x") + "]"
+}
+
+func valueText(val parser.Value) string {
+	switch t := val.(type) {
+	case *parser.StringValue:
+		if t.Quoted {
+			return "\"" + t.Value + "\""
+		}
+		return t.Value
+	case *parser.ReferenceValue:
+		return t.Value
+	case *parser.IntValue:
+		return t.Raw
+	case *parser.FloatValue:
+		return t.Raw
+	case *parser.BoolValue:
+		return strconv.FormatBool(t.Value)
+	}
+	return ""
+}
+
+func isArrayValue(val parser.Value) bool {
+	_, ok := val.(*parser.ArrayValue)
+	return ok
+}
@@ -0,0 +1,274 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// declaredVariable is a #var or #let definition and the node whose scope it
+// belongs to.
+type declaredVariable struct {
+	def  *parser.VariableDefinition
+	node *index.ProjectNode
+	file string
+}
+
+// binding is a name bound by a #foreach or a #template parameter.
+type binding struct {
+	name  string
+	desc  string // e.g. "Loop variable 'I'"
+	pos   parser.Position
+	file  string
+	node  *index.ProjectNode
+	block parser.Definition
+	outer []binding // bindings of the enclosing blocks, innermost last
+}
+
+// variableUsage is what CheckVariableUsage gathers from the definitions of
+// the project.
+type variableUsage struct {
+	root      *index.ProjectNode
+	variables []declaredVariable
+	templates map[string]declaredTemplate
+	bindings  []binding
+	used      map[string]bool
+	uses      map[string]bool
+}
+
+type declaredTemplate struct {
+	def  *parser.TemplateDefinition
+	node *index.ProjectNode
+	file string
+}
+
+// CheckVariableUsage reports #var and #let definitions no '@' reference
+// uses, #templates no #use or 'extends' names, loop variables and template
+// parameters shadowing a variable of an enclosing scope, and -v overrides
+// that name no #var.
+func (v *Validator) CheckVariableUsage(ctx context.Context) {
+	u := &variableUsage{
+		root:      v.Tree.Root,
+		templates: make(map[string]declaredTemplate),
+		used:      make(map[string]bool),
+		uses:      make(map[string]bool),
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		for _, frag := range node.Fragments {
+			// Object and conditional fragments are reached from the file
+			// fragment they are nested in.
+			if !frag.IsObject && !frag.IsConditional {
+				u.walk(frag.Definitions, node, frag.File, nil)
+			}
+		}
+	})
+	if ctx.Err() != nil {
+		return
+	}
+
+	for _, dv := range u.variables {
+		if u.used[dv.def.Name] {
+			continue
+		}
+		kind := "Variable"
+		if dv.def.IsConst {
+			kind = "Constant"
+		}
+		v.report(dv.node, "unused_variable", LevelWarning,
+			fmt.Sprintf("Unused %s: '%s' is defined but never referenced", kind, dv.def.Name),
+			dv.def.Position, dv.file)
+	}
+
+	names := make([]string, 0, len(u.templates))
+	for name := range u.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if u.uses[name] {
+			continue
+		}
+		t := u.templates[name]
+		v.report(t.node, "unused_template", LevelWarning,
+			fmt.Sprintf("Unused Template: '%s' is defined but never used by #use or extends", name),
+			t.def.Position, t.file)
+	}
+
+	for _, b := range u.bindings {
+		if where := u.shadowed(b); where != "" {
+			v.report(b.node, "shadowed_variable", LevelWarning,
+				fmt.Sprintf("%s shadows %s", b.desc, where),
+				b.pos, b.file)
+		}
+	}
+
+	v.checkOverrides(u)
+}
+
+// walk gathers the definitions and uses of defs, nested in the scope of node
+// and of the bindings outer.
+func (u *variableUsage) walk(defs []parser.Definition, node *index.ProjectNode, file string, outer []binding) {
+	use := func(val parser.Value) {
+		for _, name := range variableNames(val) {
+			u.used[name] = true
+		}
+	}
+	for _, def := range defs {
+		switch d := def.(type) {
+		case *parser.Field:
+			use(d.Value)
+		case *parser.VariableDefinition:
+			u.variables = append(u.variables, declaredVariable{def: d, node: node, file: file})
+			use(d.DefaultValue)
+		case *parser.ObjectNode:
+			use(d.Name)
+			child := node
+			if name, ok := d.Name.(*parser.StringValue); ok {
+				if c, ok := node.Children[index.NormalizeName(name.Value)]; ok {
+					child = c
+				}
+			} else if name, ok := d.Name.(*parser.ReferenceValue); ok {
+				if c, ok := node.Children[index.NormalizeName(name.Value)]; ok {
+					child = c
+				}
+			}
+			u.walk(d.Subnode.Definitions, child, file, outer)
+		case *parser.SignalShorthand:
+			use(d.NumElements)
+			u.walk(d.ExtraFields.Definitions, node, file, outer)
+		case *parser.IfBlock:
+			use(d.Condition)
+			u.walk(d.Then, node, file, outer)
+			u.walk(d.Else, node, file, outer)
+		case *parser.SwitchBlock:
+			use(d.Subject)
+			for _, b := range d.Branches() {
+				for _, val := range b.Values {
+					use(val)
+				}
+				u.walk(b.Body, node, file, outer)
+			}
+		case *parser.SlotBlock:
+			u.walk(d.Body, node, file, outer)
+		case *parser.ForeachBlock:
+			use(d.Iterable)
+			inner := outer
+			for _, name := range []string{d.KeyVar, d.ValueVar} {
+				if name == "" {
+					continue
+				}
+				b := binding{name: name, desc: fmt.Sprintf("Loop variable '%s'", name), pos: d.Position, file: file, node: node, block: d, outer: outer}
+				u.bindings = append(u.bindings, b)
+				inner = append(inner[:len(inner):len(inner)], b)
+			}
+			u.walk(d.Body, node, file, inner)
+		case *parser.TemplateDefinition:
+			u.templates[d.Name] = declaredTemplate{def: d, node: node, file: file}
+			if d.Base != "" {
+				u.uses[d.Base] = true
+			}
+			inner := outer
+			for _, p := range d.Parameters {
+				use(p.DefaultValue)
+				b := binding{name: p.Name, desc: fmt.Sprintf("Parameter '%s' of template '%s'", p.Name, d.Name), pos: d.Position, file: file, node: node, block: d, outer: outer}
+				u.bindings = append(u.bindings, b)
+				inner = append(inner[:len(inner):len(inner)], b)
+			}
+			u.walk(d.Body, node, file, inner)
+		case *parser.TemplateInstantiation:
+			u.uses[d.Template] = true
+			for _, arg := range d.Arguments {
+				use(arg.Value)
+			}
+			for _, b := range d.Blocks {
+				u.walk(b.Body, node, file, outer)
+			}
+		}
+	}
+}
+
+// shadowed describes the variable b shadows, if any: a binding of an
+// enclosing block or a #var/#let of the scope of b, defined outside the
+// block binding b.
+func (u *variableUsage) shadowed(b binding) string {
+	for i := len(b.outer) - 1; i >= 0; i-- {
+		if o := b.outer[i]; o.name == b.name {
+			return fmt.Sprintf("%s defined at %s:%d:%d", lowerFirst(o.desc), o.file, o.pos.Line, o.pos.Column)
+		}
+	}
+	for _, dv := range u.variables {
+		if dv.def.Name != b.name || (dv.node != u.root && !isScopeOf(dv.node, b.node)) {
+			continue
+		}
+		if dv.file == b.file && inBlock(dv.def.Position, b.block) {
+			continue
+		}
+		kind := "variable"
+		if dv.def.IsConst {
+			kind = "constant"
+		}
+		return fmt.Sprintf("%s '%s' defined at %s:%d:%d", kind, dv.def.Name, dv.file, dv.def.Position.Line, dv.def.Position.Column)
+	}
+	return ""
+}
+
+// checkOverrides reports the -v overrides that name no #var.
+func (v *Validator) checkOverrides(u *variableUsage) {
+	defined := make(map[string]bool)
+	for _, dv := range u.variables {
+		if !dv.def.IsConst {
+			defined[dv.def.Name] = true
+		}
+	}
+	names := make([]string, 0, len(v.RawOverrides))
+	for name := range v.RawOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !defined[name] {
+			v.report(nil, "unknown_override", LevelError,
+				fmt.Sprintf("Unknown variable '%s' in override -v%s=%s: no #var defines it", name, name, v.RawOverrides[name]),
+				parser.Position{}, "")
+		}
+	}
+}
+
+// isScopeOf tells whether the variables of scope are visible from node; those
+// of the root are visible from every file.
+func isScopeOf(scope, node *index.ProjectNode) bool {
+	for n := node; n != nil; n = n.Parent {
+		if n == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func inBlock(pos parser.Position, block parser.Definition) bool {
+	start, end := block.Pos(), block.End()
+	after := pos.Line > start.Line || (pos.Line == start.Line && pos.Column >= start.Column)
+	before := pos.Line < end.Line || (pos.Line == end.Line && pos.Column <= end.Column)
+	return after && before
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// isUnusedTag tells whether an ignore(unused) pragma suppresses tag.
+func isUnusedTag(tag string) bool {
+	switch tag {
+	case "unused_gam", "unused_signal", "unused_variable", "unused_template",
+		"dead_gam", "dead_datasource", "dead_signal", "dead_branch":
+		return true
+	}
+	return false
+}
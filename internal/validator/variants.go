@@ -0,0 +1,457 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+// maxVariants bounds the number of variants CheckVariants evaluates.
+const maxVariants = 256
+
+// branchOutcome records which branches of an #if were taken.
+type branchOutcome struct {
+	file      string
+	then, els bool
+}
+
+// variantVariable is a #var and the values its type enumerates.
+type variantVariable struct {
+	name   string
+	values []parser.Value
+}
+
+// variantUsage is what CheckVariants gathers across the variants.
+type variantUsage struct {
+	active map[*index.ProjectNode]bool
+	used   map[*index.ProjectNode]bool
+}
+
+// CheckVariants evaluates the project under every combination of the values
+// of the #vars whose type enumerates them, such as bool or "Sim" | "HW", and
+// reports the GAMs, DataSources and DataSource signals no variant uses and
+// the #if branches no variant takes. Variables set by -v overrides keep
+// their value. The other #vars may take any value, so the #if conditions
+// and #switch subjects reading them are not reported and the objects their
+// branches reference count as used. It returns the number of variants
+// evaluated, and restores the active configuration of v before returning.
+func (v *Validator) CheckVariants(ctx context.Context) (int, error) {
+	if v.Tree == nil {
+		return 0, nil
+	}
+	declared := v.declaredVariables()
+	vars := v.variantVariables(declared)
+	free := freeVariables(declared, vars, v.RawOverrides)
+	total := 1
+	for _, vv := range vars {
+		total *= len(vv.values)
+		if total > maxVariants {
+			names := make([]string, len(vars))
+			for i, vv := range vars {
+				names[i] = vv.name
+			}
+			return 0, fmt.Errorf("the variables %s have more than %d variants; pin some with -v", strings.Join(names, ", "), maxVariants)
+		}
+	}
+
+	usage := &variantUsage{
+		active: make(map[*index.ProjectNode]bool),
+		used:   make(map[*index.ProjectNode]bool),
+	}
+	branches := make(map[*parser.IfBlock]*branchOutcome)
+	choice := make([]int, len(vars))
+	for n := 0; n < total; n++ {
+		if ctx.Err() != nil {
+			break
+		}
+		overrides := make(map[string]parser.Value, len(v.Overrides)+len(vars))
+		for k, val := range v.Overrides {
+			overrides[k] = val
+		}
+		for i, vv := range vars {
+			overrides[vv.name] = vv.values[choice[i]]
+		}
+		v.evaluateVariant(ctx, overrides, branches, usage)
+
+		// Next combination, the last variable varying fastest.
+		for i := len(choice) - 1; i >= 0; i-- {
+			choice[i]++
+			if choice[i] < len(vars[i].values) {
+				break
+			}
+			choice[i] = 0
+		}
+	}
+
+	// Whatever the blocks reading free variables reference may be used.
+	v.Tree.ResolveFields(nil)
+	v.Tree.ResolveReferences(nil)
+	v.markFreeBlockUsage(v.freeBlocks(free), usage)
+
+	// Resolve again for the configuration v validated.
+	v.Tree.ResolveFields(v.ActiveFragments)
+	v.Tree.ResolveReferences(v.ActiveFragments)
+	if ctx.Err() != nil {
+		return total, ctx.Err()
+	}
+
+	v.reportDeadNodes(usage)
+	v.reportDeadBranches(branches, free)
+	v.sortDiagnostics()
+	return total, nil
+}
+
+// declaredVariables returns the #var and #let definitions of the project.
+func (v *Validator) declaredVariables() []declaredVariable {
+	u := &variableUsage{
+		root:      v.Tree.Root,
+		templates: make(map[string]declaredTemplate),
+		used:      make(map[string]bool),
+		uses:      make(map[string]bool),
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		for _, frag := range node.Fragments {
+			if !frag.IsObject && !frag.IsConditional {
+				u.walk(frag.Definitions, node, frag.File, nil)
+			}
+		}
+	})
+	return u.variables
+}
+
+// variantVariables returns the #vars to vary, by name.
+func (v *Validator) variantVariables(declared []declaredVariable) []variantVariable {
+	seen := make(map[string]bool)
+	var vars []variantVariable
+	for _, dv := range declared {
+		name := dv.def.Name
+		if dv.def.IsConst || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := v.RawOverrides[name]; ok {
+			continue
+		}
+		if values := v.enumeratedValues(dv.def.TypeExpr); len(values) > 0 {
+			vars = append(vars, variantVariable{name: name, values: values})
+		}
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].name < vars[j].name })
+	return vars
+}
+
+// freeVariables returns the names of the #vars neither varied nor pinned by
+// an override, and of the #let constants computed from them.
+func freeVariables(declared []declaredVariable, vars []variantVariable, overrides map[string]string) map[string]bool {
+	varied := make(map[string]bool)
+	for _, vv := range vars {
+		varied[vv.name] = true
+	}
+	free := make(map[string]bool)
+	for _, dv := range declared {
+		name := dv.def.Name
+		if _, pinned := overrides[name]; !dv.def.IsConst && !varied[name] && !pinned {
+			free[name] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, dv := range declared {
+			if dv.def.IsConst && !free[dv.def.Name] && readsAny(dv.def.DefaultValue, free) {
+				free[dv.def.Name] = true
+				changed = true
+			}
+		}
+	}
+	return free
+}
+
+// readsAny tells whether val reads one of the variables names.
+func readsAny(val parser.Value, names map[string]bool) bool {
+	for _, name := range variableNames(val) {
+		if names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// freeBlock is an #if or #switch reading a free variable.
+type freeBlock struct {
+	file  string
+	block parser.Definition
+}
+
+// freeBlocks returns the outermost #if and #switch blocks of the project
+// whose condition or subject reads a variable of free.
+func (v *Validator) freeBlocks(free map[string]bool) []freeBlock {
+	var blocks []freeBlock
+	var walk func(defs []parser.Definition, file string)
+	walk = func(defs []parser.Definition, file string) {
+		for _, def := range defs {
+			switch d := def.(type) {
+			case *parser.ObjectNode:
+				walk(d.Subnode.Definitions, file)
+			case *parser.IfBlock:
+				if readsAny(d.Condition, free) {
+					blocks = append(blocks, freeBlock{file: file, block: d})
+					continue
+				}
+				walk(d.Then, file)
+				walk(d.Else, file)
+			case *parser.SwitchBlock:
+				if readsAny(d.Subject, free) {
+					blocks = append(blocks, freeBlock{file: file, block: d})
+					continue
+				}
+				for _, b := range d.Branches() {
+					walk(b.Body, file)
+				}
+			case *parser.ForeachBlock:
+				walk(d.Body, file)
+			case *parser.SlotBlock:
+				walk(d.Body, file)
+			case *parser.TemplateDefinition:
+				walk(d.Body, file)
+			case *parser.TemplateInstantiation:
+				for _, b := range d.Blocks {
+					walk(b.Body, file)
+				}
+			}
+		}
+	}
+	if len(free) == 0 {
+		return nil
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		for _, frag := range node.Fragments {
+			if !frag.IsObject && !frag.IsConditional {
+				walk(frag.Definitions, frag.File)
+			}
+		}
+	})
+	return blocks
+}
+
+// markFreeBlockUsage marks as used the objects referenced from blocks and the
+// DataSource signals the GAM signals defined in blocks use.
+func (v *Validator) markFreeBlockUsage(blocks []freeBlock, usage *variantUsage) {
+	if len(blocks) == 0 {
+		return
+	}
+	within := func(file string, pos parser.Position) bool {
+		for _, b := range blocks {
+			if b.file == file && inBlock(pos, b.block) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, ref := range v.Tree.References {
+		if ref.Target != nil && within(ref.File, ref.Position) {
+			usage.used[ref.Target] = true
+		}
+	}
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if node.Target == nil {
+			return
+		}
+		for _, frag := range node.Fragments {
+			if within(frag.File, frag.ObjectPos) {
+				usage.used[node.Target] = true
+				return
+			}
+		}
+	})
+}
+
+// enumeratedValues returns the values the CUE type typeExpr enumerates, or
+// nil if it does not enumerate them.
+func (v *Validator) enumeratedValues(typeExpr string) []parser.Value {
+	if v.Schema == nil || typeExpr == "" {
+		return nil
+	}
+	typeVal := v.Schema.Context.CompileString(typeExpr)
+	if typeVal.Err() != nil {
+		return nil
+	}
+	if !typeVal.IsConcrete() && typeVal.IncompleteKind() == cue.BoolKind {
+		return []parser.Value{&parser.BoolValue{Value: false}, &parser.BoolValue{Value: true}}
+	}
+
+	alternatives := []cue.Value{typeVal}
+	if op, args := typeVal.Expr(); op == cue.OrOp {
+		alternatives = args
+	}
+	var values []parser.Value
+	keys := make(map[string]bool)
+	for _, alt := range alternatives {
+		if !alt.IsConcrete() {
+			return nil
+		}
+		var val parser.Value
+		switch alt.Kind() {
+		case cue.StringKind:
+			s, _ := alt.String()
+			val = &parser.StringValue{Value: s, Quoted: true, Raw: strconv.Quote(s)}
+		case cue.IntKind:
+			n, _ := alt.Int64()
+			val = &parser.IntValue{Value: n, Raw: strconv.FormatInt(n, 10)}
+		case cue.FloatKind:
+			f, _ := alt.Float64()
+			val = &parser.FloatValue{Value: f, Raw: strconv.FormatFloat(f, 'g', -1, 64)}
+		case cue.BoolKind:
+			b, _ := alt.Bool()
+			val = &parser.BoolValue{Value: b}
+		default:
+			return nil
+		}
+		key, _ := switchKey(val)
+		if !keys[key] {
+			keys[key] = true
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// evaluateVariant activates the configuration overrides select, recording
+// the #if branches it takes and the nodes it activates and uses.
+func (v *Validator) evaluateVariant(ctx context.Context, overrides map[string]parser.Value, branches map[*parser.IfBlock]*branchOutcome, usage *variantUsage) {
+	variant := &Validator{
+		Tree:            v.Tree,
+		Schema:          v.Schema,
+		Overrides:       overrides,
+		Variables:       make(map[string]parser.Value),
+		ActiveNodes:     make(map[*index.ProjectNode]bool),
+		ActiveFragments: make(map[*index.Fragment]bool),
+		branches:        branches,
+	}
+	for k, val := range overrides {
+		variant.Variables[k] = val
+	}
+	v.Tree.ResolveFields(nil)
+	v.Tree.ResolveReferences(nil)
+	variant.activate(ctx)
+
+	for node := range variant.ActiveNodes {
+		usage.active[node] = true
+	}
+	for _, ref := range v.Tree.References {
+		if ref.Target != nil && variant.isPositionActive(ref.File, ref.Position) {
+			usage.used[ref.Target] = true
+		}
+	}
+	for node := range variant.ActiveNodes {
+		if node.Target != nil {
+			usage.used[node.Target] = true
+		}
+	}
+}
+
+// recordBranch records that the #if d of file took its then branch, or its
+// else branch if taken is false.
+func (v *Validator) recordBranch(d *parser.IfBlock, file string, taken bool) {
+	if v.branches == nil {
+		return
+	}
+	v.muActive.Lock()
+	defer v.muActive.Unlock()
+	o := v.branches[d]
+	if o == nil {
+		o = &branchOutcome{file: file}
+		v.branches[d] = o
+	}
+	if taken {
+		o.then = true
+	} else {
+		o.els = true
+	}
+}
+
+// reportDeadNodes reports the GAMs, DataSources and DataSource signals active
+// in some variant but used in none. The signals of an unused DataSource are
+// not reported on their own.
+func (v *Validator) reportDeadNodes(usage *variantUsage) {
+	v.Tree.Walk(func(node *index.ProjectNode) {
+		if !usage.active[node] {
+			return
+		}
+		if v.Tree.IsGAM(node) && !usage.used[node] {
+			v.report(node, "dead_gam", LevelWarning,
+				fmt.Sprintf("Dead GAM: %s is not referenced in any thread or scheduler in any variant", node.RealName),
+				v.getNodePosition(node), v.getNodeFile(node))
+		}
+		if !v.Tree.IsDataSource(node) {
+			return
+		}
+		var signals []*index.ProjectNode
+		if c, ok := node.Children["Signals"]; ok {
+			for _, sig := range v.Tree.OrderedChildren(c) {
+				if usage.active[sig] {
+					signals = append(signals, sig)
+				}
+			}
+		}
+		var unused []*index.ProjectNode
+		for _, sig := range signals {
+			if !usage.used[sig] {
+				unused = append(unused, sig)
+			}
+		}
+		if !usage.used[node] && len(unused) == len(signals) {
+			v.report(node, "dead_datasource", LevelWarning,
+				fmt.Sprintf("Dead DataSource: %s is not used in any variant", node.RealName),
+				v.getNodePosition(node), v.getNodeFile(node))
+			return
+		}
+		for _, sig := range unused {
+			v.report(sig, "dead_signal", LevelWarning,
+				fmt.Sprintf("Dead Signal: %s of DataSource %s is not referenced in any variant", sig.RealName, node.RealName),
+				v.getNodePosition(sig), v.getNodeFile(sig))
+		}
+	})
+}
+
+// reportDeadBranches reports the #if branches no variant takes. An #if no
+// variant reaches is inside a dead branch itself and is not reported, and
+// neither is one whose condition reads a variable of free.
+func (v *Validator) reportDeadBranches(branches map[*parser.IfBlock]*branchOutcome, free map[string]bool) {
+	blocks := make([]*parser.IfBlock, 0, len(branches))
+	for d := range branches {
+		if !readsAny(d.Condition, free) {
+			blocks = append(blocks, d)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if fa, fb := branches[a].file, branches[b].file; fa != fb {
+			return fa < fb
+		}
+		if a.Position.Line != b.Position.Line {
+			return a.Position.Line < b.Position.Line
+		}
+		return a.Position.Column < b.Position.Column
+	})
+	for _, d := range blocks {
+		o := branches[d]
+		node := v.Tree.GetNodeContaining(o.file, d.Position)
+		switch {
+		case !o.then:
+			v.report(node, "dead_branch", LevelWarning,
+				"Dead Branch: the #if condition is false in every variant, so its branch is never taken",
+				d.Position, o.file)
+		case !o.els && len(d.Else) > 0:
+			v.report(node, "dead_branch", LevelWarning,
+				"Dead Branch: the #if condition is true in every variant, so its #else branch is never taken",
+				d.Position, o.file)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+func TestCSTRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"+A = {\n    Class = X // trailing\n}\n",
+		"//# Doc\n  +B={Class=Y\n\tS = \"say \\\"hi\\\"\"\n  R = `raw\nline`}\n//! pragma\n",
+		"#var N: uint = 2\n#if @N > 1\n  +C = { Arr = { 1 2, 3 } }\n#end\n",
+		"+Broken = { Class = ",
+		"A = \"café\" /* block\ncomment */ B = 1",
+	}
+	for _, in := range inputs {
+		if out := parser.ParseCST(in).String(); out != in {
+			t.Errorf("Round trip mismatch.\nExpected: %q\nGot:      %q", in, out)
+		}
+	}
+}
+
+func TestCSTNavigation(t *testing.T) {
+	cst := parser.ParseCST("#var   Gain: float64 = 1\n+A = {\n  Class = X\n}\n")
+
+	tok := cst.TokenAt(parser.Position{Line: 1, Column: 2})
+	if tok == nil || tok.Type != parser.TokenVar {
+		t.Fatalf("Expected #var token, got %v", tok)
+	}
+	name := cst.Next(tok)
+	if name == nil || name.Value != "Gain" || name.Position.Column != 8 {
+		t.Errorf("Expected Gain at column 8, got %+v", name)
+	}
+	if cst.TokenAt(parser.Position{Line: 1, Column: 6}) != nil {
+		t.Error("Expected no token inside whitespace")
+	}
+	if indent := cst.Indent(3); indent != "  " {
+		t.Errorf("Expected two-space indent, got %q", indent)
+	}
+
+	defs := 0
+	for _, c := range cst.Root.Children {
+		if n, ok := c.(*parser.CSTNode); ok && n.Kind == parser.CSTDefinition {
+			defs++
+		}
+	}
+	if defs != 2 {
+		t.Errorf("Expected 2 top-level definitions, got %d", defs)
+	}
+}
+
+func TestComputeEditsPreservesComments(t *testing.T) {
+	input := "+A = {\n// keep me\nClass = X\n    Value   =  1 //! ignore(x)\n}\n"
+	p := parser.NewParser(input)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buf bytes.Buffer
+	formatter.Format(config, &buf)
+	formatted := buf.String()
+
+	edits := parser.ComputeEdits(input, formatted)
+	if len(edits) == 0 {
+		t.Fatal("Expected edits")
+	}
+	for _, e := range edits {
+		if strings.Contains(e.NewText, "keep me") || strings.Contains(e.NewText, "ignore") {
+			t.Errorf("Edit rewrites a comment: %+v", e)
+		}
+	}
+
+	// Apply edits back to front.
+	cst := parser.ParseCST(input)
+	out := input
+	for i := len(edits) - 1; i >= 0; i-- {
+		start, end := cst.OffsetOf(edits[i].Start), cst.OffsetOf(edits[i].End)
+		out = out[:start] + edits[i].NewText + out[end:]
+	}
+	if out != formatted {
+		t.Errorf("Applying edits did not produce formatted text.\nExpected:\n%s\nGot:\n%s", formatted, out)
+	}
+
+	if edits := parser.ComputeEdits(formatted, formatted); len(edits) != 0 {
+		t.Errorf("Expected no edits for identical text, got %d", len(edits))
+	}
+}
@@ -276,6 +276,32 @@ func TestBuildWithVariableOverride(t *testing.T) {
 	}
 }
 
+func TestBuildWithUnknownVariableOverride(t *testing.T) {
+	ctx := framework.NewTestContext(t)
+	defer ctx.Cleanup()
+
+	tf := framework.WrapT(t, ctx)
+
+	tf.CreateFile("config.marte", `
+//! allow(unknown_class)
+#var COUNT: int = 5
+
++Config = {
+    Class = "Test"
+    Count = @COUNT
+}
+`)
+
+	result := tf.RunBuild("-vCOUNTS=99", "config.marte")
+
+	if result.ExitCode == 0 {
+		t.Fatalf("Expected the build to fail on an unknown override, got: %s", result.Output)
+	}
+	if !strings.Contains(result.Stderr, "ERROR: Unknown variable 'COUNTS' in override -vCOUNTS=99") {
+		t.Fatalf("Expected the unknown override to be reported, got: %s", result.Stderr)
+	}
+}
+
 func TestBuildWithConditional(t *testing.T) {
 	ctx := framework.NewTestContext(t)
 	defer ctx.Cleanup()
@@ -1,6 +1,9 @@
 package e2e
 
 import (
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/marte-community/marte-dev-tools/test/e2e/framework"
@@ -298,3 +301,60 @@ func TestCheckRangesFormat(t *testing.T) {
 	result = tf.RunCheck("invalid_ranges_inner.marte")
 	framework.AssertErrors(tf, result, "Ranges")
 }
+
+func TestCheckMARTeVersion(t *testing.T) {
+	ctx := framework.NewTestContext(t)
+	defer ctx.Cleanup()
+
+	tf := framework.WrapT(t, ctx)
+
+	tf.CreateFile("gate.marte", `
++Gate = {
+    Class = MemoryGate
+}
+`)
+
+	framework.AssertNoErrors(tf, tf.RunCheck("gate.marte"))
+	framework.AssertErrors(tf, tf.RunCheck("--marte-version=v1.3", "gate.marte"), "Class 'MemoryGate' is not available in MARTe2-components v1.3")
+
+	result := tf.RunCheck("--marte-version=v0.9", "gate.marte")
+	if !strings.Contains(result.Stderr, `Unknown MARTe version "v0.9"`) {
+		t.Errorf("Expected unknown version error, got %s", result.Stderr)
+	}
+}
+
+func TestCheckDeterministicOutput(t *testing.T) {
+	ctx := framework.NewTestContext(t)
+	defer ctx.Cleanup()
+
+	tf := framework.WrapT(t, ctx)
+
+	examples, err := filepath.Abs(filepath.Join("..", "..", "examples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The log prefix carries the time of day.
+	timestamp := regexp.MustCompile(`(?m)^\[mdt\] \S+ \S+ `)
+
+	first := timestamp.ReplaceAllString(tf.RunCheck("-P", examples).Stdout, "")
+	if !strings.Contains(first, "WARNING:") {
+		t.Fatalf("Expected diagnostics for the examples, got %s", first)
+	}
+	lines := strings.Split(first, "\n")
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if strings.Contains(line, "ERROR:") || strings.Contains(line, "WARNING:") {
+			if seen[line] {
+				t.Errorf("Diagnostic reported twice: %s", line)
+			}
+			seen[line] = true
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		out := timestamp.ReplaceAllString(tf.RunCheck("-P", examples).Stdout, "")
+		if out != first {
+			t.Fatalf("Run %d differs from the first run:\n%s\n---\n%s", i+2, out, first)
+		}
+	}
+}
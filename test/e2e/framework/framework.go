@@ -293,6 +293,34 @@ func (tc *TestContext) RunFmt(args ...string) *FmtResult {
 	}
 }
 
+type SizeResult struct {
+	Output   string
+	Stderr   string
+	ExitCode int
+}
+
+func (tc *TestContext) RunSize(args ...string) *SizeResult {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(tc.mdtPath, append([]string{"size"}, args...)...)
+	cmd.Dir = tc.tempDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+
+	return &SizeResult{
+		Output:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: exitCode,
+	}
+}
+
 type T struct {
 	*testing.T
 	ctx *TestContext
@@ -338,6 +366,10 @@ func (t *T) RunFmt(args ...string) *FmtResult {
 	return t.ctx.RunFmt(args...)
 }
 
+func (t *T) RunSize(args ...string) *SizeResult {
+	return t.ctx.RunSize(args...)
+}
+
 func (t *T) ResetLSP() {
 	t.ctx.ResetLSP()
 }
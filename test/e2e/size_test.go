@@ -0,0 +1,119 @@
+package e2e
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/test/e2e/framework"
+)
+
+const sizeApp = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    +Timings = { Class = TimingDataSource }
+    +Timer = {
+      Class = LinuxTimer
+      Signals = {
+        Counter = { Type = uint32 }
+        Time = { Type = uint64 }
+      }
+    }
+    +Log = {
+      Class = FileWriter
+      NumberOfBuffers = 10
+      Signals = { Counter = { Type = uint32 } }
+    }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Copy = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = Timer Type = uint32 Frequency = 100 }
+        Time = { DataSource = Timer Type = uint64 }
+      }
+      OutputSignals = {
+        Counter = { DataSource = Log Type = uint32 }
+        Time = { DataSource = Log Type = uint64 }
+      }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Main = { Class = RealTimeThread Functions = { Copy } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+func TestSizeReport(t *testing.T) {
+	ctx := framework.NewTestContext(t)
+	defer ctx.Cleanup()
+
+	tf := framework.WrapT(t, ctx)
+	tf.CreateFile("app.marte", sizeApp)
+
+	result := tf.RunSize("app.marte")
+	if result.ExitCode != 0 {
+		t.Fatalf("size failed: %s", result.Stderr)
+	}
+	for _, want := range []string{"DataSource  Log", "App.Run.Main", "Total"} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("Expected %q in table, got:\n%s", want, result.Output)
+		}
+	}
+
+	result = tf.RunSize("--format=json", "app.marte")
+	var report struct {
+		DataSources []struct {
+			Name    string
+			Buffers int64
+			Bytes   int64
+		}
+		Total int64
+	}
+	if err := json.Unmarshal([]byte(result.Output), &report); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, result.Output)
+	}
+	// Log holds Counter and the implicit Time, 12 bytes, in 10 buffers.
+	if len(report.DataSources) != 3 || report.DataSources[0].Name != "Log" || report.DataSources[0].Bytes != 120 {
+		t.Errorf("Unexpected DataSources: %+v", report.DataSources)
+	}
+	// Log 120, Timer 12, Timings 0 and the 24 bytes of Copy.
+	if report.Total != 156 {
+		t.Errorf("Expected a total of 156 bytes, got %d", report.Total)
+	}
+
+	result = tf.RunSize("--format=csv", "app.marte")
+	if !strings.Contains(result.Output, "GAM,Copy,IOGAM,4,1,24") {
+		t.Errorf("Expected Copy row in CSV, got:\n%s", result.Output)
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	ctx := framework.NewTestContext(t)
+	defer ctx.Cleanup()
+
+	tf := framework.WrapT(t, ctx)
+	tf.CreateFile("app.marte", sizeApp)
+
+	if result := tf.RunSize("--max-total=1K", "--max-gam=24", "app.marte"); result.ExitCode != 0 {
+		t.Errorf("Expected limits to pass, got %s", result.Stderr)
+	}
+
+	result := tf.RunSize("--max-datasource=100", "app.marte")
+	if result.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", result.ExitCode)
+	}
+	if !strings.Contains(result.Stderr, "DataSource 'Log' uses 120 bytes, more than the limit of 100") {
+		t.Errorf("Expected limit message, got %s", result.Stderr)
+	}
+}
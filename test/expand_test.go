@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+const expandContent = `#var Count: int = 2
+
+#template Channel(ID: int, Gain: float = 1.0)
+  +Ch = {
+    Class = AnalogInput
+    ID = @ID
+    #if @ID > 1
+      Gain = @Gain * 2
+    #else
+      Gain = @Gain
+    #end
+  }
+#end
+
++Hardware = {
+  Class = ReferenceContainer
+  #foreach I in { 1, 2, 3 }
+    #if @I <= @Count
+      #use Channel C(ID = @I)
+    #end
+  #end
+}
+`
+
+func expandAt(t *testing.T, line int, vars map[string]parser.Value) []index.Expansion {
+	t.Helper()
+	config, err := parser.NewParser(expandContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("expand.marte", config)
+	if vars == nil {
+		vars = map[string]parser.Value{"Count": &parser.IntValue{Value: 2, Raw: "2"}}
+	}
+	exps, err := pt.Expand(config, "expand.marte", line, vars)
+	if err != nil {
+		t.Fatalf("Expand failed: %v", err)
+	}
+	return exps
+}
+
+func TestExpandUseInLoop(t *testing.T) {
+	exps := expandAt(t, 19, nil)
+	if len(exps) != 3 {
+		t.Fatalf("Expected one expansion per iteration, got %d", len(exps))
+	}
+	out := index.FormatExpansions(exps)
+	for _, want := range []string{
+		"// #use Channel C (expand.marte:19:7) with I = 1\n",
+		"ID = 1\n", "Gain = 1.0\n",
+		"ID = 2\n", "Gain = 2\n",
+		"with I = 3 [inactive]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in expansion:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "#if") || strings.Contains(out, "@") {
+		t.Errorf("Expansion should be fully evaluated:\n%s", out)
+	}
+}
+
+func TestExpandLoop(t *testing.T) {
+	out := index.FormatExpansions(expandAt(t, 17, nil))
+	if strings.Count(out, "C = {") != 2 || !strings.HasPrefix(out, "// #foreach I (expand.marte:17:3)\n") {
+		t.Errorf("Expected the loop to produce two channels:\n%s", out)
+	}
+
+	// Without a line every outermost block is expanded.
+	out = index.FormatExpansions(expandAt(t, 0, nil))
+	if !strings.HasPrefix(out, "// #foreach I") {
+		t.Errorf("Expected the whole file to be expanded:\n%s", out)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	config, _ := parser.NewParser(expandContent).Parse()
+	pt := index.NewProjectTree()
+	pt.AddFile("expand.marte", config)
+	if _, err := pt.Expand(config, "expand.marte", 2, nil); err == nil {
+		t.Error("Expected an error for a line without a block")
+	}
+	if _, err := pt.Expand(config, "expand.marte", 7, nil); err == nil || !strings.Contains(err.Error(), "inside #template Channel") {
+		t.Errorf("Expected an error inside a template, got %v", err)
+	}
+}
+
+func TestExpandLSPCommand(t *testing.T) {
+	lsp.ResetTestServer()
+	var buf bytes.Buffer
+	lsp.Output = &buf
+	uri := "file://expand.marte"
+	lsp.HandleDidOpen(lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Text: expandContent},
+	})
+
+	actions := lsp.HandleCodeAction(lsp.CodeActionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Range:        lsp.Range{Start: lsp.Position{Line: 18, Character: 8}, End: lsp.Position{Line: 18, Character: 8}},
+	})
+	var cmd *lsp.Command
+	for _, a := range actions {
+		if a.Command != nil && a.Command.Command == lsp.ExpandCommand {
+			cmd = a.Command
+		}
+	}
+	if cmd == nil {
+		t.Fatalf("Expected a 'Show expansion' action, got %+v", actions)
+	}
+
+	var args []json.RawMessage
+	for _, a := range cmd.Arguments {
+		raw, _ := json.Marshal(a)
+		args = append(args, raw)
+	}
+	res, err := lsp.HandleExecuteCommand(lsp.ExecuteCommandParams{Command: cmd.Command, Arguments: args})
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+	doc, ok := res.(*lsp.ExpandResult)
+	if !ok || !strings.HasPrefix(doc.URI, "mdt-expand://") || !strings.Contains(doc.Content, "ID = 2") {
+		t.Errorf("Unexpected expansion document: %+v", res)
+	}
+
+	if _, err := lsp.HandleExecuteCommand(lsp.ExecuteCommandParams{Command: "mdt.unknown"}); err == nil {
+		t.Error("Expected an error for an unknown command")
+	}
+}
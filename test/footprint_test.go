@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+func TestFootprint(t *testing.T) {
+	fp := validateRTApp(t, rtAppContent).Footprint()
+
+	bytesOf := func(list []validator.MemoryUsage) map[string]int64 {
+		m := make(map[string]int64)
+		for _, u := range list {
+			m[u.Name] = u.Bytes
+		}
+		return m
+	}
+	// DDB holds the implicit signals A, B and C; Timer its two declared ones.
+	if ds := bytesOf(fp.DataSources); ds["DDB"] != 12 || ds["Timer"] != 8 || ds["Timings"] != 0 {
+		t.Errorf("Unexpected DataSource memory: %+v", fp.DataSources)
+	}
+	if gams := bytesOf(fp.GAMs); len(gams) != 3 || gams["Sync"] != 8 || gams["Resync"] != 8 || gams["Work"] != 8 {
+		t.Errorf("Unexpected GAM memory: %+v", fp.GAMs)
+	}
+	if th := bytesOf(fp.Threads); th["App.Run.Fast"] != 16 || th["App.Run.Late"] != 16 || th["App.Run.Free"] != 8 {
+		t.Errorf("Unexpected thread memory: %+v", fp.Threads)
+	}
+	if st := bytesOf(fp.States); st["App.Run"] != 44 {
+		t.Errorf("Unexpected state memory: %+v", fp.States)
+	}
+	if fp.Total != 44 {
+		t.Errorf("Expected a total of 44 bytes, got %d", fp.Total)
+	}
+
+	// NumberOfBuffers multiplies the memory of a DataSource.
+	content := strings.Replace(rtAppContent, "Class = LinuxTimer", "Class = LinuxTimer NumberOfBuffers = 4", 1)
+	fp = validateRTApp(t, content).Footprint()
+	timer := fp.DataSources[1]
+	if timer.Name != "Timer" || timer.Buffers != 4 || timer.Bytes != 32 || fp.Total != 68 {
+		t.Errorf("Expected 4 buffers of 8 bytes for Timer, got %+v (total %d)", timer, fp.Total)
+	}
+}
+
+func TestLSPFootprint(t *testing.T) {
+	lsp.ResetTestServer()
+	lsp.GlobalSchema = schema.LoadFullSchema(".")
+	var buf bytes.Buffer
+	lsp.Output = &buf
+
+	uri := "file://footprint.marte"
+	lsp.HandleDidOpen(lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Text: rtAppContent},
+	})
+
+	hover := lsp.HandleHover(lsp.HoverParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 5, Character: 6},
+	})
+	if hover == nil {
+		t.Fatal("Expected hover on DDB")
+	}
+	if content := hover.Contents.(lsp.MarkupContent).Value; !strings.Contains(content, "**Memory**: 12 bytes (3 signals)") {
+		t.Errorf("Expected memory in hover, got %q", content)
+	}
+
+	lenses := lsp.HandleCodeLens(lsp.CodeLensParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}})
+	titles := make(map[int]string)
+	for _, l := range lenses {
+		titles[l.Range.Start.Line] = l.Command.Title
+	}
+	if len(lenses) != 10 {
+		t.Errorf("Expected 10 code lenses, got %+v", lenses)
+	}
+	if titles[5] != "Memory: 12 bytes (3 signals)" || titles[7] != "Memory: 8 bytes (2 signals)" || titles[35] != "Memory: 44 bytes (GAMs and DataSources)" {
+		t.Errorf("Unexpected code lenses: %+v", titles)
+	}
+}
@@ -0,0 +1,236 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+func exampleSources(tb testing.TB) map[string]string {
+	tb.Helper()
+	var files []string
+	filepath.Walk("../examples", func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(path) == ".marte" {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if len(files) == 0 {
+		tb.Skip("no examples found")
+	}
+	sources := map[string]string{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		sources[f] = string(data)
+	}
+	return sources
+}
+
+func errorStrings(errs []error) []string {
+	out := make([]string, len(errs))
+	for i, e := range errs {
+		out[i] = e.Error()
+	}
+	sort.Strings(out)
+	return out
+}
+
+func checkUpdate(t *testing.T, name string, doc *parser.Document, newText string) *parser.Document {
+	t.Helper()
+	got := doc.Update(newText)
+	want := parser.ParseDocument(newText)
+	if !reflect.DeepEqual(got.Config(), want.Config()) {
+		t.Fatalf("%s: incremental=%v configuration differs from full parse", name, got.Incremental())
+	}
+	if !reflect.DeepEqual(errorStrings(got.Errors()), errorStrings(want.Errors())) {
+		t.Fatalf("%s: errors differ\nincremental: %v\nfull:        %v", name, got.Errors(), want.Errors())
+	}
+	return got
+}
+
+func TestIncrementalParseMatchesFullParse(t *testing.T) {
+	edits := []struct {
+		name   string
+		delete int
+		insert string
+	}{
+		{"insert char", 0, "x"},
+		{"insert newline", 0, "\n"},
+		{"delete char", 1, ""},
+		{"insert field", 0, "\nExtra = 1\n"},
+		{"insert brace", 0, "{"},
+		{"insert comment", 0, "// note\n"},
+		{"insert block comment", 0, "/*"},
+		{"replace", 3, "+N = { A = 2 }"},
+	}
+	for file, src := range exampleSources(t) {
+		doc := parser.ParseDocument(src)
+		step := len(src)/10 + 1
+		for off := 0; off < len(src); off += step {
+			for _, e := range edits {
+				end := off + e.delete
+				if end > len(src) {
+					end = len(src)
+				}
+				newText := src[:off] + e.insert + src[end:]
+				checkUpdate(t, filepath.Base(file)+": "+e.name, doc, newText)
+			}
+		}
+	}
+}
+
+func TestIncrementalParseReusesUnchangedNodes(t *testing.T) {
+	src := `+App = {
+    Class = RealTimeApplication
+    +Functions = {
+        Class = ReferenceContainer
+        +GAM1 = {
+            Class = IOGAM
+            Value = 1
+        }
+        +GAM2 = {
+            Class = IOGAM
+        }
+    }
+    +Data = {
+        Class = ReferenceContainer
+    }
+}
+// trailing
++Other = { Class = X }
+`
+	doc := parser.ParseDocument(src)
+	newText := strings.Replace(src, "Value = 1", "Value = 12\n            Gain = 2", 1)
+	upd := checkUpdate(t, "nested edit", doc, newText)
+	if !upd.Incremental() {
+		t.Fatal("Expected an incremental re-parse")
+	}
+
+	oldApp := doc.Config().Definitions[0].(*parser.ObjectNode)
+	newApp := upd.Config().Definitions[0].(*parser.ObjectNode)
+	if oldApp == newApp {
+		t.Error("Edited object must be copied, not modified")
+	}
+	oldFns := oldApp.Subnode.Definitions[1].(*parser.ObjectNode)
+	newFns := newApp.Subnode.Definitions[1].(*parser.ObjectNode)
+	if oldFns.Subnode.Definitions[0] != newFns.Subnode.Definitions[0] {
+		t.Error("Class field before the edit should be reused")
+	}
+	if p := oldApp.Subnode.Definitions[2].Pos(); p.Line != 13 {
+		t.Errorf("Old tree was modified: +Data at line %d", p.Line)
+	}
+	if p := newApp.Subnode.Definitions[2].Pos(); p.Line != 14 {
+		t.Errorf("Expected +Data shifted to line 14, got %d", p.Line)
+	}
+
+	// An edit on a single line leaves later nodes untouched.
+	same := strings.Replace(newText, "Gain = 2", "Gain = 3", 1)
+	upd2 := checkUpdate(t, "same line edit", upd, same)
+	if upd2.Config().Definitions[1] != upd.Config().Definitions[1] {
+		t.Error("+Other should be reused when no lines move")
+	}
+}
+
+func TestIncrementalParseErrors(t *testing.T) {
+	src := "+A = {\n    X = 1\n}\n+B = {\n    Y = )\n}\n+C = {\n    Z = 3\n}\n"
+	doc := parser.ParseDocument(src)
+	if len(doc.Errors()) != 1 {
+		t.Fatalf("Expected 1 error, got %v", doc.Errors())
+	}
+	// Edit before the error: the error moves down with its line.
+	upd := checkUpdate(t, "edit before error", doc, strings.Replace(src, "X = 1", "X = 1\n    W = 2", 1))
+	if !upd.Incremental() || !strings.HasPrefix(upd.Errors()[0].Error(), "6:") {
+		t.Errorf("Expected shifted error on line 6, got %v", upd.Errors())
+	}
+	// Fix the error.
+	fixed := checkUpdate(t, "fix error", upd, strings.Replace(upd.Text(), "Y = )", "Y = 2", 1))
+	if len(fixed.Errors()) != 0 {
+		t.Errorf("Expected no errors, got %v", fixed.Errors())
+	}
+	// Unbalanced brace falls back to a full parse.
+	broken := checkUpdate(t, "open brace", fixed, strings.Replace(fixed.Text(), "Z = 3", "Z = {", 1))
+	if broken.Incremental() {
+		t.Error("Expected a full re-parse for an unbalanced brace")
+	}
+}
+
+func benchmarkEdits(b *testing.B, incremental bool) {
+	sources := exampleSources(b)
+	type edit struct {
+		doc     *parser.Document
+		newText string
+	}
+	var edits []edit
+	for _, src := range sources {
+		// Type a character in the middle of the file.
+		off := strings.Index(src[len(src)/2:], "\n")
+		if off < 0 {
+			continue
+		}
+		off += len(src) / 2
+		edits = append(edits, edit{parser.ParseDocument(src), src[:off] + " " + src[off:]})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range edits {
+			if incremental {
+				e.doc.Update(e.newText)
+			} else {
+				parser.ParseDocument(e.newText)
+			}
+		}
+	}
+}
+
+func BenchmarkParseFull(b *testing.B)        { benchmarkEdits(b, false) }
+func BenchmarkParseIncremental(b *testing.B) { benchmarkEdits(b, true) }
+
+// BenchmarkDidChangeIndex measures what an LSP edit costs after parsing:
+// the snapshot clone and the re-indexing of the edited file, which are not
+// incremental.
+func BenchmarkDidChangeIndex(b *testing.B) {
+	sources := exampleSources(b)
+	tree := index.NewProjectTree()
+	type edit struct {
+		file    string
+		doc     *parser.Document
+		newText string
+	}
+	var edits []edit
+	for file, src := range sources {
+		doc := parser.ParseDocument(src)
+		if doc.Config() != nil {
+			tree.AddFile(file, doc.Config())
+		}
+		off := strings.Index(src[len(src)/2:], "\n")
+		if off < 0 {
+			continue
+		}
+		off += len(src) / 2
+		edits = append(edits, edit{file, doc, src[:off] + " " + src[off:]})
+	}
+	tree.ResolveReferences(nil)
+	tree.ResolveFields(nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, e := range edits {
+			config := e.doc.Update(e.newText).Config()
+			if config == nil {
+				continue
+			}
+			next := tree.Clone()
+			next.AddFile(e.file, config)
+			next.ResolveReferences(nil)
+			next.ResolveFields(nil)
+		}
+	}
+}
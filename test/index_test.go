@@ -70,3 +70,42 @@ func TestResolveReferencesWithMap(t *testing.T) {
 		t.Error("ResolveReferences failed to resolve A")
 	}
 }
+
+func TestApplicationStatesAndThreads(t *testing.T) {
+	content := `+App = {
+  Class = RealTimeApplication
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Main = { Class = RealTimeThread Functions = { A } }
+        +Notes = { Class = ReferenceContainer }
+      }
+      +Extra = { Class = RealTimeThread Functions = { B } }
+    }
+    +Docs = { Class = ReferenceContainer }
+  }
+}
+`
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("app.marte", config)
+	app := pt.IsolatedFiles["app.marte"].Children["App"]
+
+	states := pt.ApplicationStates(app)
+	if len(states) != 1 || states[0].Name != "Run" {
+		t.Fatalf("Expected only the RealTimeState Run, got %v", states)
+	}
+	var names []string
+	for _, thread := range pt.StateThreads(states[0]) {
+		names = append(names, thread.Name)
+	}
+	if len(names) != 2 || names[0] != "Main" || names[1] != "Extra" {
+		t.Errorf("Expected threads Main and Extra, got %v", names)
+	}
+}
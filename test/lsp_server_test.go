@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
@@ -179,11 +180,11 @@ Field=1
 
 	edits := lsp.HandleFormatting(params)
 
-	if len(edits) != 1 {
-		t.Fatalf("Expected 1 edit, got %d", len(edits))
+	if len(edits) == 0 {
+		t.Fatal("Expected formatting edits")
 	}
 
-	newText := edits[0].NewText
+	newText := applyTextEdits(content, edits)
 
 	expected := `#package Proj.Main
 
@@ -196,3 +197,27 @@ Field=1
 		t.Errorf("Formatting mismatch.\nExpected:\n%s\nGot:\n%s", expected, newText)
 	}
 }
+
+// applyTextEdits applies non-overlapping LSP edits to text.
+func applyTextEdits(text string, edits []lsp.TextEdit) string {
+	lineStarts := []int{0}
+	for i, c := range text {
+		if c == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	offset := func(p lsp.Position) int {
+		if p.Line >= len(lineStarts) {
+			return len(text)
+		}
+		return lineStarts[p.Line] + p.Character
+	}
+	sorted := append([]lsp.TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return offset(sorted[i].Range.Start) > offset(sorted[j].Range.Start)
+	})
+	for _, e := range sorted {
+		text = text[:offset(e.Range.Start)] + e.NewText + text[offset(e.Range.End):]
+	}
+	return text
+}
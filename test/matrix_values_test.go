@@ -0,0 +1,173 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/builder"
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+func TestArrayShape(t *testing.T) {
+	cases := []struct {
+		src     string
+		shape   string
+		regular bool
+	}{
+		{"{ 1 2 3 }", "[3]", true},
+		{"{ {1 2 3} {4 5 6} }", "[2 3]", true},
+		{"{ { {1 2} {3 4} } { {5 6} {7 8} } }", "[2 2 2]", true},
+		{"{}", "[0]", true},
+		{"{ {1 2} {3} }", "[2]", false},
+		{"{ {1 2} 3 }", "[2]", false},
+	}
+	for _, c := range cases {
+		p := parser.NewParser("A = " + c.src)
+		config, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse %s failed: %v", c.src, err)
+		}
+		arr, ok := config.Definitions[0].(*parser.Field).Value.(*parser.ArrayValue)
+		if !ok {
+			t.Fatalf("%s: expected ArrayValue", c.src)
+		}
+		shape, regular := arr.Shape()
+		if fmt.Sprint(shape) != c.shape {
+			t.Errorf("%s: expected shape %s, got %v", c.src, c.shape, shape)
+		}
+		if regular != c.regular {
+			t.Errorf("%s: expected regular=%v, got %v", c.src, c.regular, regular)
+		}
+	}
+}
+
+func TestMatrixRoundTrip(t *testing.T) {
+	input := `+DS = {
+  Class = GAMDataSource
+  Signals = {
+    M = {
+      Type = float32
+      NumberOfDimensions = 2
+      NumberOfElements = 6
+      Default = { { 1, 2, 3 }, { 4, 5, 6 } }
+    }
+    E = {
+      Type = uint32
+      Default = {}
+    }
+  }
+}
+`
+	p := parser.NewParser(input)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	formatter.Format(config, &buf)
+	if buf.String() != input {
+		t.Errorf("Formatter did not round-trip matrix.\nExpected:\n%s\nGot:\n%s", input, buf.String())
+	}
+
+	f, _ := os.CreateTemp("", "matrix.marte")
+	f.WriteString(input)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	outF, _ := os.CreateTemp("", "out_matrix.marte")
+	defer os.Remove(outF.Name())
+
+	b := builder.NewBuilder([]string{f.Name()}, nil)
+	if err := b.Build(outF); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	outF.Close()
+
+	out, _ := os.ReadFile(outF.Name())
+	outStr := string(out)
+	if !strings.Contains(outStr, "Default = { { 1 2 3 } { 4 5 6 } }") {
+		t.Errorf("Builder did not emit nested matrix:\n%s", outStr)
+	}
+	if !strings.Contains(outStr, "Default = {}") {
+		t.Errorf("Builder did not emit empty array:\n%s", outStr)
+	}
+}
+
+func TestValidatorMatrixDefaults(t *testing.T) {
+	content := `
++Data = {
+    Class = ReferenceContainer
+    +DS = {
+        Class = GAMDataSource
+        Signals = {
+            Good = { Type = float32 NumberOfDimensions = 2 NumberOfElements = 6 Default = { {1 2 3} {4.5 5 6} } }
+            GoodVector = { Type = uint32 NumberOfElements = 3 Default = { 1 2 3 } }
+            GoodScalar = { Type = uint32 Default = { 7 } }
+            GoodText = { Type = char8 NumberOfElements = 8 Default = "abc" }
+            BadCount = { Type = uint32 NumberOfDimensions = 2 NumberOfElements = 4 Default = { {1 2 3} {4 5 6} } }
+            BadDims = { Type = uint32 NumberOfDimensions = 1 NumberOfElements = 6 Default = { {1 2 3} {4 5 6} } }
+            Ragged = { Type = uint32 NumberOfDimensions = 2 NumberOfElements = 6 Default = { {1 2 3} {4 5} } }
+            BadScalar = { Type = uint32 NumberOfElements = 4 Default = 1 }
+            BadElem = { Type = int32 NumberOfElements = 3 Default = { 1 2.5 3 } }
+            BadText = { Type = char8 NumberOfElements = 2 Default = "abc" }
+        }
+    }
+}
+
++GAM = {
+    Class = IOGAM
+    InputSignals = {
+        Good = { DataSource = DS Value = { {0 0 0} {0 0 0} } }
+        Good2 = { Alias = Good DataSource = DS Value = { 1 2 3 4 5 6 } }
+    }
+}
+`
+	p := parser.NewParser(content)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	pt := index.NewProjectTree()
+	pt.AddFile("matrix.marte", config)
+	pt.ResolveReferences(nil)
+
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+
+	expected := map[string]string{
+		"BadCount":  "has 6 elements (shape [2x3]) but NumberOfElements is 4",
+		"BadDims":   "has 2 dimension(s) (shape [2x3]) but NumberOfDimensions is 1",
+		"Ragged":    "is not a regular matrix",
+		"BadScalar": "is a scalar but NumberOfElements is 4",
+		"BadElem":   "contains '2.5' which is not a valid int32",
+		"BadText":   "has 3 characters but NumberOfElements is 2",
+		"Good2":     "has 1 dimension(s) (shape [6]) but NumberOfDimensions is 2",
+	}
+	found := map[string]bool{}
+	for _, d := range v.Diagnostics {
+		for sig, msg := range expected {
+			if strings.Contains(d.Message, "signal '"+sig+"'") && strings.Contains(d.Message, msg) {
+				found[sig] = true
+			}
+		}
+		for _, good := range []string{"'Good'", "'GoodVector'", "'GoodScalar'", "'GoodText'"} {
+			if strings.Contains(d.Message, "signal "+good) {
+				t.Errorf("Unexpected diagnostic for %s: %s", good, d.Message)
+			}
+		}
+	}
+	for sig, msg := range expected {
+		if !found[sig] {
+			t.Errorf("Expected diagnostic for %s: %s", sig, msg)
+		}
+	}
+}
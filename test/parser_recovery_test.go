@@ -0,0 +1,126 @@
+package integration
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+func TestParserRecoveryMultipleErrors(t *testing.T) {
+	content := `
++A = {
+    Class = X
+    Arr = { 1, = 2 }
+    After = 1
+}
++B = {
+    Class = Y
+    Bad = )
+    Good = 2
+}
++C = { Class = Z }
+`
+	p := parser.NewParser(content)
+	config, err := p.Parse()
+	if err == nil {
+		t.Fatal("Expected parse error")
+	}
+	if len(p.Errors()) != 2 {
+		t.Errorf("Expected 2 errors, got %d: %v", len(p.Errors()), p.Errors())
+	}
+	if len(config.Definitions) != 3 {
+		t.Fatalf("Expected 3 top-level definitions, got %d", len(config.Definitions))
+	}
+
+	a := config.Definitions[0].(*parser.ObjectNode)
+	names := []string{}
+	errNodes := 0
+	for _, d := range a.Subnode.Definitions {
+		switch def := d.(type) {
+		case *parser.Field:
+			names = append(names, def.Name)
+		case *parser.ErrorNode:
+			errNodes++
+			if def.Position.Line != 4 {
+				t.Errorf("Expected ErrorNode on line 4, got %d", def.Position.Line)
+			}
+		}
+	}
+	if strings.Join(names, ",") != "Class,After" || errNodes != 1 {
+		t.Errorf("Unexpected +A content: fields %v, %d error nodes", names, errNodes)
+	}
+
+	b := config.Definitions[1].(*parser.ObjectNode)
+	last := b.Subnode.Definitions[len(b.Subnode.Definitions)-1]
+	if f, ok := last.(*parser.Field); !ok || f.Name != "Good" {
+		t.Errorf("Expected field Good after recovery in +B, got %T", last)
+	}
+}
+
+func TestParserRecoveryMissingBrace(t *testing.T) {
+	content := `
++App = {
+    Class = RealTimeApplication
+    +Data = {
+        Class = ReferenceContainer
+        +DS = {
+            Class = GAMDataSource
+    }
+    +States = {
+        Class = ReferenceContainer
+    }
+}
++Other = {
+    Class = ReferenceContainer
+}
+`
+	p := parser.NewParser(content)
+	config, err := p.Parse()
+	if err == nil {
+		t.Fatal("Expected parse error for missing brace")
+	}
+	// The '}' on line 8 closes +DS, so +Data is the block left open.
+	if len(p.Errors()) != 1 || !strings.Contains(err.Error(), "4:13: missing }") {
+		t.Errorf("Expected missing brace reported at +Data block, got %v", p.Errors())
+	}
+
+	pt := index.NewProjectTree()
+	pt.AddFile("missing_brace.marte", config)
+
+	root := pt.IsolatedFiles["missing_brace.marte"]
+	app := root.Children["App"]
+	if app == nil {
+		t.Fatal("App not indexed")
+	}
+	if _, ok := app.Children["States"]; !ok {
+		t.Error("States should be a child of App after recovery")
+	}
+	if data := app.Children["Data"]; data == nil || data.Children["DS"] == nil {
+		t.Error("Data.DS should be indexed")
+	}
+	if _, ok := root.Children["Other"]; !ok {
+		t.Error("Other should be indexed at top level")
+	}
+}
+
+func TestParserRecoveryMissingEnd(t *testing.T) {
+	content := `
++A = { Class = X }
+#if true
++B = { Class = Y }
+`
+	p := parser.NewParser(content)
+	config, err := p.Parse()
+	if err == nil || !strings.Contains(err.Error(), "expected #end") {
+		t.Fatalf("Expected missing #end error, got %v", err)
+	}
+	if len(config.Definitions) != 2 {
+		t.Fatalf("Expected 2 definitions, got %d", len(config.Definitions))
+	}
+	ifBlock, ok := config.Definitions[1].(*parser.IfBlock)
+	if !ok || len(ifBlock.Then) != 1 {
+		t.Errorf("Expected #if block to keep its body")
+	}
+}
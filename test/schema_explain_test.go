@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cueerrors "cuelang.org/go/cue/errors"
+
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+)
+
+func loadProjectSchema(t *testing.T, content string) *schema.Schema {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return schema.LoadFullSchema(dir)
+}
+
+func TestSchemaLayers(t *testing.T) {
+	s := loadProjectSchema(t, "package schema\n\n#Classes: PIDGAM: Kp: >0\n")
+	if len(s.Layers) != 2 || s.Layers[0].Name != schema.BuiltinLayer || filepath.Base(s.Layers[1].Name) != ".marte_schema.cue" {
+		t.Fatalf("Expected the built-in and project layers, got %+v", s.Layers)
+	}
+	if errs := s.Errors(); len(errs) != 0 {
+		t.Errorf("Expected no schema errors, got %v", errs)
+	}
+
+	// A file that does not compile is kept as a layer with its error.
+	s = loadProjectSchema(t, "package schema\n\n#Classes: PIDGAM: {\n\tKp:\n}\n")
+	if len(s.Layers) != 2 || s.Layers[1].Err == nil {
+		t.Fatalf("Expected the project layer to be skipped with an error, got %+v", s.Layers)
+	}
+	if errs := s.Errors(); len(errs) != 1 || !strings.Contains(cueerrors.Details(errs[0], nil), ".marte_schema.cue:5") {
+		t.Errorf("Expected the compile error with its position, got %v", errs)
+	}
+
+	// Conflicts between layers are reported too.
+	s = loadProjectSchema(t, "package schema\n\n#Classes: PIDGAM: Kp: string\n")
+	if errs := s.Errors(); len(errs) == 0 || !strings.Contains(cueerrors.Details(errs[0], nil), "conflicting values") {
+		t.Errorf("Expected a conflict between the layers, got %v", errs)
+	}
+}
+
+func TestSchemaExplain(t *testing.T) {
+	s := loadProjectSchema(t, `package schema
+
+#Classes: PIDGAM: {
+	Kp:    >0
+	Mode?: *"auto" | "manual"
+}
+`)
+	if s.Explain("NoSuchClass") != nil {
+		t.Errorf("Expected no explanation for an unknown class")
+	}
+	info := s.Explain("MARTe::PIDGAM")
+	if info == nil {
+		t.Fatal("Expected PIDGAM to be explained")
+	}
+	if len(info.Sources) != 2 || !info.Open {
+		t.Errorf("Expected an open class defined by two layers, got %+v", info)
+	}
+	fields := make(map[string]schema.FieldInfo)
+	for _, f := range info.Fields {
+		fields[f.Name] = f
+	}
+
+	kp := fields["Kp"]
+	if kp.Optional || len(kp.Constraints) != 2 || kp.Constraints[0].Expr != "float | int" || kp.Constraints[1].Expr != ">0" || kp.Constraints[1].Line != 4 {
+		t.Errorf("Expected Kp constrained by both layers, got %+v", kp)
+	}
+	mode := fields["Mode"]
+	if !mode.Optional || len(mode.Constraints) != 1 || strings.Join(mode.Enum, ",") != `"auto","manual"` || mode.Default != `"auto"` {
+		t.Errorf("Expected Mode to be an optional enum with a default, got %+v", mode)
+	}
+	if st := fields["SamplingTime"]; !st.Optional || len(st.Constraints) != 1 || st.Constraints[0].Layer != schema.BuiltinLayer {
+		t.Errorf("Expected SamplingTime from the built-in schema, got %+v", st)
+	}
+	if len(info.Meta) != 1 || info.Meta[0].Name != "MetaType" || info.Meta[0].Type != `"gam"` {
+		t.Errorf("Expected the #meta of PIDGAM, got %+v", info.Meta)
+	}
+}
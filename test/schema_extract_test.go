@@ -0,0 +1,237 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+)
+
+var extractSources = map[string]string{
+	"Filter/FilterGAM.h": `#include "GAM.h"
+namespace Demo {
+class BaseFilter : public MARTe::GAM {
+public:
+    virtual bool Initialise(MARTe::StructuredDataI &data);
+protected:
+    MARTe::uint32 order;
+};
+
+class FilterGAM : public BaseFilter {
+public:
+    virtual bool Initialise(MARTe::StructuredDataI &data);
+    virtual bool Setup();
+private:
+    MARTe::float32 gain;
+    MARTe::StreamString mode;
+    MARTe::Vector<MARTe::float64> coefficients;
+    MARTe::float32 *buffer;
+    MARTe::float32 offset;
+};
+}
+`,
+	"Filter/FilterGAM.cpp": `#include "FilterGAM.h"
+namespace Demo {
+bool BaseFilter::Initialise(MARTe::StructuredDataI &data) {
+    bool ok = GAM::Initialise(data);
+    if (ok) {
+        ok = data.Read("Order", order);
+        if (!ok) {
+            REPORT_ERROR(MARTe::ErrorManagement::InitialisationError, "Order shall be specified");
+        }
+    }
+    return ok;
+}
+
+bool FilterGAM::Initialise(MARTe::StructuredDataI &data) {
+    bool ok = BaseFilter::Initialise(data);
+    if (ok) {
+        ok = data.Read("Gain", gain);
+        if (!ok) {
+            REPORT_ERROR(MARTe::ErrorManagement::InitialisationError, "Gain shall be specified");
+        }
+    }
+    if (ok) {
+        if (!data.Read("Mode", mode)) {
+            REPORT_ERROR(MARTe::ErrorManagement::Warning, "Mode not set, using Direct");
+            mode = "Direct";
+        }
+    }
+    if (ok) {
+        // data.Read("Commented", gain);
+        data.Read("Offset", offset);
+        ok = data.Read("Coefficients", coefficients);
+    }
+    if (ok) {
+        ok = data.Read("Buffer", buffer);
+    }
+    if (ok) {
+        ok = data.MoveRelative("Limits");
+        if (ok) {
+            ok = data.Read("Max", gain);
+        }
+        ok = data.MoveToAncestor(1u);
+    }
+    return ok;
+}
+
+bool FilterGAM::Setup() {
+    bool ok = (GetSignalType(InputSignals, 0u) == MARTe::Float32Bit);
+    if (ok) {
+        ok = (MARTe::Float32Bit == GetSignalType(OutputSignals, 0u));
+    }
+    return ok;
+}
+
+CLASS_REGISTER(FilterGAM, "1.0")
+}
+`,
+	"Acq/AcqBoard.h": `#include "DataSourceI.h"
+namespace Demo {
+class AcqBoard: public MARTe::DataSourceI, public MARTe::MessageI {
+public:
+    virtual bool Initialise(MARTe::StructuredDataI &data);
+    virtual bool GetInputBrokers(MARTe::ReferenceContainer &inputBrokers, const MARTe::char8 * const functionName, void * const gamMemPtr);
+private:
+    MARTe::uint32 boardId;
+    MARTe::char8 device[64];
+};
+class Widget : public Vendor::Component {
+};
+}
+`,
+	"Acq/AcqBoard.cpp": `#include "AcqBoard.h"
+namespace Demo {
+bool AcqBoard::Initialise(MARTe::StructuredDataI &data) {
+    bool ok = DataSourceI::Initialise(data);
+    if (ok) {
+        ok = data.Read("BoardId", boardId);
+        if (!ok) {
+            REPORT_ERROR(MARTe::ErrorManagement::ParametersError, "BoardId shall be specified");
+        }
+    }
+    if (ok) {
+        ok = data.Read("Device", device);
+        if (!ok) {
+            REPORT_ERROR(MARTe::ErrorManagement::ParametersError, "Device shall be specified");
+        }
+    }
+    return ok;
+}
+bool AcqBoard::GetInputBrokers(MARTe::ReferenceContainer &inputBrokers, const MARTe::char8 * const functionName, void * const gamMemPtr) {
+    MARTe::ReferenceT<MARTe::MemoryMapSynchronisedInputBroker> broker("MemoryMapSynchronisedInputBroker");
+    return inputBrokers.Insert(broker);
+}
+CLASS_REGISTER(AcqBoard, "1.0")
+CLASS_REGISTER(Widget, "1.0")
+}
+`,
+}
+
+func extractFixture(t *testing.T) *schema.Extraction {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range extractSources {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := schema.ExtractSchema([]string{dir})
+	if err != nil {
+		t.Fatalf("Extraction failed: %v", err)
+	}
+	return e
+}
+
+func TestSchemaExtract(t *testing.T) {
+	e := extractFixture(t)
+	classes := make(map[string]*schema.ExtractedClass)
+	for _, c := range e.Classes {
+		classes[c.Name] = c
+	}
+	if len(classes) != 3 {
+		t.Fatalf("Expected FilterGAM, AcqBoard and Widget, got %+v", e.Classes)
+	}
+
+	filter := classes["FilterGAM"]
+	if filter.MetaType != "gam" {
+		t.Errorf("Expected FilterGAM to be a GAM through BaseFilter, got %q", filter.MetaType)
+	}
+	expected := []struct {
+		name, typ string
+		optional  bool
+		guessed   bool
+	}{
+		{"Gain", "float | int", false, false},
+		{"Mode", "string", true, false},
+		{"Offset", "float | int", true, false},
+		{"Coefficients", "[...(float | int)]", true, true},
+		{"Buffer", "_", true, true},
+		{"Order", "int", false, false},
+	}
+	if len(filter.Fields) != len(expected) {
+		t.Fatalf("Expected %d fields, got %+v", len(expected), filter.Fields)
+	}
+	for i, exp := range expected {
+		f := filter.Fields[i]
+		if f.Name != exp.name || f.Type != exp.typ || f.Optional != exp.optional || (f.Guess != "") != exp.guessed {
+			t.Errorf("Unexpected field %d: %+v, expected %+v", i, f, exp)
+		}
+	}
+	if len(filter.InputTypes) != 1 || filter.InputTypes[0] != "float32" || len(filter.OutputTypes) != 1 || filter.OutputTypes[0] != "float32" {
+		t.Errorf("Expected float32 signal checks, got %v and %v", filter.InputTypes, filter.OutputTypes)
+	}
+
+	acq := classes["AcqBoard"]
+	if acq.MetaType != "datasource" || acq.Direction != "IN" {
+		t.Errorf("Expected an input DataSource, got %+v", acq)
+	}
+	if len(acq.Fields) != 2 || acq.Fields[1].Type != "string" || acq.Fields[1].Optional {
+		t.Errorf("Expected a mandatory string Device, got %+v", acq.Fields)
+	}
+
+	if w := classes["Widget"]; len(w.Guesses) != 1 || w.MetaType != "" {
+		t.Errorf("Expected the unknown base class of Widget to be marked, got %+v", w)
+	}
+}
+
+func TestSchemaExtractOutput(t *testing.T) {
+	e := extractFixture(t)
+	text := e.CUE()
+	if v := cuecontext.New().CompileString(text); v.Err() != nil {
+		t.Fatalf("Generated schema does not compile: %v\n%s", v.Err(), text)
+	}
+	for _, want := range []string{
+		"package schema",
+		"// guessed: type of 'buffer' unknown",
+		"#meta: direction: \"IN\"",
+		"InputSignals: {[_]: {Type: \"float32\", ...}} // guessed",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the generated schema:\n%s", want, text)
+		}
+	}
+
+	// The skeleton works as a project schema.
+	for _, c := range []struct{ content, field string }{
+		{"+Filter = {\n  Class = FilterGAM\n  Order = 2\n}\n", "Gain"},
+		{"+Filter = {\n  Class = FilterGAM\n  Order = 2\n  Gain = 1.0\n  Mode = 3\n}\n", "Mode"},
+	} {
+		v := validateWithSchema(t, c.content, text)
+		found := false
+		for _, d := range v.Diagnostics {
+			found = found || strings.Contains(d.Message, c.field)
+		}
+		if !found {
+			t.Errorf("Expected %s to be reported, got %+v", c.field, v.Diagnostics)
+		}
+	}
+}
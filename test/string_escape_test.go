@@ -0,0 +1,115 @@
+package integration
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/builder"
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+)
+
+func TestStringEscapes(t *testing.T) {
+	cases := []struct {
+		src      string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"C:\\path"`, `C:\path`},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"caf\u00e9"`, "café"},
+		{"\"y = a +\\\n      b\"", "y = a +b"},
+		{"`raw \\n \"text\"`", `raw \n "text"`},
+		{"`line1\nline2`", "line1\nline2"},
+	}
+	for _, c := range cases {
+		p := parser.NewParser("A = " + c.src)
+		config, err := p.Parse()
+		if err != nil {
+			t.Fatalf("Parse %s failed: %v", c.src, err)
+		}
+		s, ok := config.Definitions[0].(*parser.Field).Value.(*parser.StringValue)
+		if !ok {
+			t.Fatalf("%s: expected StringValue", c.src)
+		}
+		if s.Value != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.src, c.expected, s.Value)
+		}
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	for _, src := range []string{`"bad \q escape"`, `"short \u12"`} {
+		p := parser.NewParser("A = " + src)
+		_, err := p.Parse()
+		if err == nil {
+			t.Errorf("%s: expected parse error", src)
+		}
+	}
+}
+
+func TestMultiLineStringPositions(t *testing.T) {
+	input := "Expr = `a +\n  b`\nNext = 1\n"
+	p := parser.NewParser(input)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(config.Definitions) != 2 {
+		t.Fatalf("Expected 2 definitions, got %d", len(config.Definitions))
+	}
+	s := config.Definitions[0].(*parser.Field).Value.(*parser.StringValue)
+	if end := s.End(); end.Line != 2 || end.Column != 5 {
+		t.Errorf("Expected string to end at 2:5, got %d:%d", end.Line, end.Column)
+	}
+	next := config.Definitions[1].(*parser.Field)
+	if next.Position.Line != 3 {
+		t.Errorf("Expected Next on line 3, got %d", next.Position.Line)
+	}
+}
+
+func TestStringEscapeFormatAndBuild(t *testing.T) {
+	input := "+GAM = {\n" +
+		"  Class = MathExpressionGAM\n" +
+		"  Expression = \"Out = \\\"In\\\" + 1;\\n\"\n" +
+		"  Raw = `C:\\dir\n" +
+		"second line`\n" +
+		"}\n"
+
+	p := parser.NewParser(input)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buf bytes.Buffer
+	formatter.Format(config, &buf)
+	if buf.String() != input {
+		t.Errorf("Formatter changed string literals.\nExpected:\n%s\nGot:\n%s", input, buf.String())
+	}
+
+	f, _ := os.CreateTemp("", "escape.marte")
+	f.WriteString(input)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	outF, _ := os.CreateTemp("", "out_escape.marte")
+	defer os.Remove(outF.Name())
+
+	b := builder.NewBuilder([]string{f.Name()}, nil)
+	if err := b.Build(outF); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	outF.Close()
+
+	out, _ := os.ReadFile(outF.Name())
+	outStr := string(out)
+	if !strings.Contains(outStr, `Expression = "Out = \"In\" + 1;\n"`) {
+		t.Errorf("Builder did not re-escape Expression:\n%s", outStr)
+	}
+	if !strings.Contains(outStr, `Raw = "C:\\dir\nsecond line"`) {
+		t.Errorf("Builder did not convert raw string:\n%s", outStr)
+	}
+}
@@ -0,0 +1,228 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/builder"
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const switchContent = `#var Mode: "Fast" | "Slow" | "Off" = "Slow"
+
++Obj = {
+    Class = X
+    #switch @Mode
+    #case "Fast"
+        Rate = 1000
+    #case "Slow", "Off" // shared
+        Rate = 10
+    #default
+        Rate = 0
+    #end
+}
+`
+
+func TestSwitchParse(t *testing.T) {
+	p := parser.NewParser(switchContent)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	obj := config.Definitions[1].(*parser.ObjectNode)
+	sw, ok := obj.Subnode.Definitions[1].(*parser.SwitchBlock)
+	if !ok {
+		t.Fatalf("Expected SwitchBlock, got %T", obj.Subnode.Definitions[1])
+	}
+	if len(sw.Cases) != 2 || len(sw.Cases[1].Values) != 2 || sw.DefaultPosition == nil {
+		t.Fatalf("Unexpected switch structure: %+v", sw)
+	}
+	branches := sw.Branches()
+	if len(branches) != 3 || branches[2].ID != "default" || branches[0].End != branches[1].Position {
+		t.Errorf("Unexpected branches: %+v", branches)
+	}
+
+	var buf bytes.Buffer
+	formatter.Format(config, &buf)
+	formatted := buf.String()
+	if !strings.Contains(formatted, "  #case \"Slow\", \"Off\" // shared\n    Rate = 10\n") {
+		t.Errorf("Unexpected formatting:\n%s", formatted)
+	}
+	p2 := parser.NewParser(formatted)
+	config2, err := p2.Parse()
+	if err != nil {
+		t.Fatalf("Formatted output does not parse: %v", err)
+	}
+	buf.Reset()
+	formatter.Format(config2, &buf)
+	if buf.String() != formatted {
+		t.Errorf("Formatting is not stable:\n%s\n---\n%s", formatted, buf.String())
+	}
+}
+
+func TestSwitchParseErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"#switch @M\n#case 1\nA = 1\n", "expected #end"},
+		{"#switch @M\nA = 1\n#case 1\n#end\n", "expected #case or #default"},
+		{"#switch @M\n#default\n#default\n#end\n", "duplicate #default"},
+		{"#switch @M\n#case 1\n#else\n#end\n", "#else is not allowed in #switch"},
+		{"#if true\n#case 1\n#end\n", "expected #end"},
+	}
+	for _, tt := range tests {
+		p := parser.NewParser(tt.content)
+		_, err := p.Parse()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected error %q, got %v", tt.content, tt.err, err)
+		}
+	}
+}
+
+func TestSwitchBuild(t *testing.T) {
+	for _, tt := range []struct {
+		overrides map[string]string
+		want      string
+	}{
+		{nil, "Rate = 10"},
+		{map[string]string{"Mode": "Fast"}, "Rate = 1000"},
+		{map[string]string{"Mode": "Off"}, "Rate = 10"},
+		{map[string]string{"Mode": "Other"}, "Rate = 0"},
+	} {
+		f, _ := os.CreateTemp("", "switch_*.marte")
+		f.WriteString(switchContent)
+		f.Close()
+		defer os.Remove(f.Name())
+
+		b := builder.NewBuilder([]string{f.Name()}, tt.overrides)
+		outF, _ := os.CreateTemp("", "switch_out_*.marte")
+		defer os.Remove(outF.Name())
+		if err := b.Build(outF); err != nil {
+			t.Fatalf("Build failed: %v", err)
+		}
+		outF.Close()
+		data, _ := os.ReadFile(outF.Name())
+		out := string(data)
+		if strings.Count(out, "Rate =") != 1 || !strings.Contains(out, tt.want) {
+			t.Errorf("%v: expected only %q, got:\n%s", tt.overrides, tt.want, out)
+		}
+	}
+}
+
+func validateSwitch(t *testing.T, content string) *validator.Validator {
+	t.Helper()
+	p := parser.NewParser(content)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("switch.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func TestSwitchExhaustiveness(t *testing.T) {
+	content := `#var Mode: "Fast" | "Slow" | "Off" = "Slow"
++Obj = {
+    Class = X
+    #switch @Mode
+    #case "Fast"
+        Rate = 1000
+    #case "Slow", "Medium"
+        Rate = 10
+    #case "Fast"
+        Rate = 1
+    #end
+}
+`
+	v := validateSwitch(t, content)
+	var notExhaustive, unknown, duplicate bool
+	for _, d := range v.Diagnostics {
+		switch {
+		case strings.Contains(d.Message, "does not handle"):
+			notExhaustive = strings.Contains(d.Message, `"Off"`) && !strings.Contains(d.Message, `"Fast"`)
+		case strings.Contains(d.Message, "is not a possible value"):
+			unknown = strings.Contains(d.Message, `"Medium"`)
+		case strings.Contains(d.Message, "Duplicate #case"):
+			duplicate = d.Position.Line == 9
+		}
+	}
+	if !notExhaustive || !unknown || !duplicate {
+		t.Errorf("Missing switch diagnostics (exhaustive=%v unknown=%v duplicate=%v): %+v", notExhaustive, unknown, duplicate, v.Diagnostics)
+	}
+
+	// A #default makes the switch exhaustive.
+	v = validateSwitch(t, switchContent)
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "#switch") || strings.Contains(d.Message, "#case") {
+			t.Errorf("Unexpected diagnostic: %s", d.Message)
+		}
+	}
+	if len(v.InactiveRanges) != 2 {
+		t.Fatalf("Expected 2 inactive branches, got %+v", v.InactiveRanges)
+	}
+	if r := v.InactiveRanges[0]; r.Start.Line != 6 || r.End.Line != 8 {
+		t.Errorf("Expected first inactive branch on lines 6-8, got %+v", r)
+	}
+}
+
+func TestSwitchLSPInactiveBranches(t *testing.T) {
+	lsp.ResetTestServer()
+	lsp.GlobalSchema = schema.LoadFullSchema(".")
+	var buf bytes.Buffer
+	lsp.Output = &buf
+
+	lsp.HandleDidOpen(lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: "file://switch.marte", Text: switchContent},
+	})
+
+	output := buf.String()
+	if !strings.Contains(output, "Inactive #switch branch") || !strings.Contains(output, `"tags":[1]`) {
+		t.Errorf("Expected inactive branch hints, got:\n%s", output)
+	}
+}
+
+func TestSwitchNestedInBlocks(t *testing.T) {
+	content := `#var Mode: "Fast" | "Slow" = "Slow"
+#var On: bool = true
++Obj = {
+    Class = X
+    #if @On
+        #switch @Mode
+        #case "Fast"
+            Rate = 1000
+        #case "Fast"
+            Rate = 1
+        #end
+    #end
+}
+`
+	v := validateSwitch(t, content)
+	var notExhaustive, duplicate int
+	for _, d := range v.Diagnostics {
+		switch {
+		case strings.Contains(d.Message, "does not handle"):
+			notExhaustive++
+		case strings.Contains(d.Message, "Duplicate #case"):
+			duplicate++
+		}
+	}
+	if notExhaustive != 1 || duplicate != 1 {
+		t.Errorf("Expected one exhaustiveness and one duplicate diagnostic for the nested #switch, got: %+v", v.Diagnostics)
+	}
+	if len(v.InactiveRanges) != 2 {
+		t.Errorf("Expected both branches of the nested #switch inactive, got %+v", v.InactiveRanges)
+	}
+}
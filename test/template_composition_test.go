@@ -0,0 +1,276 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/builder"
+	"github.com/marte-community/marte-dev-tools/internal/formatter"
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const compositionContent = `#var Rate: int = 7
+
+#template Stage(Gain: float = 1.0)
+  +Filter = {
+    Class = IOGAM
+    G = @Gain
+  }
+#end
+
+#template Pipeline(Rate: int, Gain: float = 2.0)
+  Class = ReferenceContainer
+  Rate = @Rate
+  #slot Stages
+    #use Stage Default(Gain = @Gain)
+  #end
+  #slot Extra
+  #end
+#end
+
+#template FastPipeline(Rate: int = 1000) extends Pipeline
+  Fast = true
+  #slot Extra // overrides the base slot
+    Note = "fast"
+  #end
+#end
+
++Root = {
+  Class = ReferenceContainer
+  #use Pipeline P(Rate = 10)
+  #use FastPipeline F()
+    #fill Stages
+      #use Stage S1(Gain = 5.0)
+      Marker = @Rate
+    #end
+  #end
+}
+`
+
+func TestTemplateCompositionFormat(t *testing.T) {
+	p := parser.NewParser(compositionContent)
+	config, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	fast := config.Definitions[3].(*parser.TemplateDefinition)
+	if fast.Base != "Pipeline" {
+		t.Errorf("Expected FastPipeline to extend Pipeline, got %q", fast.Base)
+	}
+
+	var buf bytes.Buffer
+	formatter.Format(config, &buf)
+	formatted := buf.String()
+	for _, want := range []string{
+		"#template FastPipeline(Rate: int = 1000) extends Pipeline\n",
+		"  #slot Extra // overrides the base slot\n    Note = \"fast\"\n  #end\n",
+		"  #use FastPipeline F()\n    #fill Stages\n",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected %q in formatted output:\n%s", want, formatted)
+		}
+	}
+	config2, err := parser.NewParser(formatted).Parse()
+	if err != nil {
+		t.Fatalf("Formatted output does not parse: %v", err)
+	}
+	buf.Reset()
+	formatter.Format(config2, &buf)
+	if buf.String() != formatted {
+		t.Errorf("Formatting is not stable:\n%s\n---\n%s", formatted, buf.String())
+	}
+}
+
+func TestTemplateCompositionParseErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		err     string
+	}{
+		{"#template T() extends\n#end\n", "expected base template name"},
+		{"#template T()\n#slot\n#end\n#end\n", "expected slot name"},
+		{"#use T X()\n#fill A\nB = 1\n", "expected #end"},
+	}
+	for _, tt := range tests {
+		_, err := parser.NewParser(tt.content).Parse()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: expected error %q, got %v", tt.content, tt.err, err)
+		}
+	}
+}
+
+func buildComposition(t *testing.T, content string) string {
+	t.Helper()
+	f, _ := os.CreateTemp("", "composition_*.marte")
+	f.WriteString(content)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	b := builder.NewBuilder([]string{f.Name()}, nil)
+	outF, _ := os.CreateTemp("", "composition_out_*.marte")
+	defer os.Remove(outF.Name())
+	if err := b.Build(outF); err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	outF.Close()
+	data, _ := os.ReadFile(outF.Name())
+	return string(data)
+}
+
+// templateSection returns the output written for the instance name at the
+// first nesting level.
+func templateSection(out, name string) string {
+	start := strings.Index(out, "\n  "+name+" = {\n")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(out[start:], "\n  }\n")
+	return out[start : start+end]
+}
+
+func TestTemplateCompositionBuild(t *testing.T) {
+	out := buildComposition(t, compositionContent)
+
+	p := templateSection(out, "P")
+	if !strings.Contains(p, "Default = {") || !strings.Contains(p, "G = 2.0") || strings.Contains(p, "Note") {
+		t.Errorf("Expected P to use the default slots:\n%s", p)
+	}
+
+	// #fill blocks are evaluated where they are written: Marker sees the
+	// global Rate, not the parameter of the template.
+	f := templateSection(out, "F")
+	for _, want := range []string{"Rate = 1000", "Fast = true", `Note = "fast"`, "S1 = {", "G = 5.0", "Marker = 7"} {
+		if !strings.Contains(f, want) {
+			t.Errorf("Expected %q in F:\n%s", want, f)
+		}
+	}
+	if strings.Contains(f, "Default") {
+		t.Errorf("Filled slot should replace its default content:\n%s", f)
+	}
+}
+
+func TestTemplateLexicalScope(t *testing.T) {
+	content := `#var Gain: float = 1.0
+
+#template Show()
+  Value = @Gain
+#end
+
+#template Outer(Gain: float = 5.0)
+  Own = @Gain
+  #use Show S()
+#end
+
++Root = {
+  Class = ReferenceContainer
+  #use Outer O()
+}
+`
+	// Template bodies used to be scoped dynamically from the #use site, so
+	// Show saw the Gain parameter of Outer and wrote Value = 5.0. They are
+	// now scoped lexically and see the global Gain.
+	out := buildComposition(t, content)
+	if !strings.Contains(out, "Own = 5.0") || !strings.Contains(out, "Value = 1.0") || strings.Contains(out, "Value = 5.0") {
+		t.Errorf("Template bodies should not see the parameters of the template using them:\n%s", out)
+	}
+}
+
+func TestTemplateRecursionGuard(t *testing.T) {
+	content := `#template Loop()
+  Class = ReferenceContainer
+  #use Loop L()
+#end
+
++Root = {
+  Class = ReferenceContainer
+  #use Loop R()
+}
+`
+	out := buildComposition(t, content)
+	if !strings.Contains(out, "R = {") || strings.Contains(out, "L = {") {
+		t.Errorf("Expected recursion to stop at the first expansion:\n%s", out)
+	}
+
+	v := validateComposition(t, content)
+	if !hasDiagnostic(v, "is used recursively", 8) {
+		t.Errorf("Expected recursion diagnostic on line 8, got %+v", v.Diagnostics)
+	}
+}
+
+func validateComposition(t *testing.T, content string) *validator.Validator {
+	t.Helper()
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("composition.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func hasDiagnostic(v *validator.Validator, msg string, line int) bool {
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, msg) && d.Position.Line == line {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTemplateCompositionDiagnostics(t *testing.T) {
+	content := `#template Inner(Gain: float)
+  G = @Gain
+#end
+
+#template Outer(Scale: float = 1.0)
+  #use Inner I(Gain = @Scale)
+  #slot Body
+  #end
+#end
+
+#template Bad() extends Missing
+#end
+
++Root = {
+  Class = ReferenceContainer
+  #use Outer O(Scale = "fast")
+  #use Outer O2()
+    #fill Nope
+    #end
+    #fill Body
+    #end
+    #fill Body
+    #end
+  #end
+}
+`
+	v := validateComposition(t, content)
+
+	// The bad value reaches Inner through Outer: the error points at the
+	// argument of the root #use and names the chain.
+	found := false
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "via Outer 'O' → Inner 'I'") {
+			found = d.Position.Line == 16 && d.Position.Column == 16
+		}
+	}
+	if !found {
+		t.Errorf("Expected chained diagnostic at 16:16, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "extends unknown template 'Missing'", 11) {
+		t.Errorf("Expected unknown base diagnostic, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "has no slot named 'Nope'", 18) {
+		t.Errorf("Expected unknown slot diagnostic, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "filled more than once", 22) {
+		t.Errorf("Expected duplicate fill diagnostic, got %+v", v.Diagnostics)
+	}
+}
@@ -0,0 +1,190 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const userTypesContent = `+Types = {
+  Class = ReferenceContainer
+  +Vec3 = {
+    Class = IntrospectionStructure
+    X = { Type = float32 }
+    Y = { Type = float32 }
+    Z = { Type = float32 }
+  }
+  +Pose = {
+    Class = IntrospectionStructure
+    Pos = { Type = Vec3 }
+    Flags = { Type = uint8 NumberOfElements = 4 }
+    Mode = { Type = Mode }
+  }
+}
+
++App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = {
+      Class = GAMDataSource
+      Signals = {
+        P = { Type = Pose }
+        Raw = { Type = uint8 NumberOfElements = 17 }
+        M = { Type = Mode Default = On }
+        Bad = { Type = Mode Default = Broken }
+      }
+    }
+    +Timings = { Class = TimingDataSource }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Copy = {
+      Class = IOGAM
+      InputSignals = { P = { DataSource = DDB } }
+      OutputSignals = { Raw = { DataSource = DDB } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +T1 = { Class = RealTimeThread Functions = { Copy } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+const userTypesSchema = `#Types: Mode: { Type: "uint8", Values: ["Off", "On"] }
+`
+
+func validateUserTypes(t *testing.T, content string) *validator.Validator {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(userTypesSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("types.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, dir, nil)
+	validator.AttachSchema(pt, v.Schema)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func TestUserTypeSizes(t *testing.T) {
+	v := validateUserTypes(t, userTypesContent)
+	if size := v.Tree.TypeSize("Pose"); size != 17 {
+		t.Errorf("Expected Pose to take 17 bytes, got %d", size)
+	}
+	layout := v.Tree.TypeLayout("Pose")
+	if len(layout) != 5 || layout[2].Path != "Pos.Z" || layout[3].Offset != 12 || layout[4].Type != "Mode" || layout[4].Size != 1 {
+		t.Errorf("Unexpected layout: %+v", layout)
+	}
+
+	// The IOGAM copies a 17-byte struct into 17 bytes.
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "InputSize") || strings.Contains(d.Message, "ByteSize") || strings.Contains(d.Message, "Invalid Type") {
+			t.Errorf("Unexpected diagnostic: %s", d.Message)
+		}
+		if strings.Contains(d.Message, "Unknown reference 'On'") {
+			t.Errorf("Enum labels should not be resolved as references: %s", d.Message)
+		}
+	}
+	if !hasDiagnostic(v, "'Broken' which is not a value of enum Mode (Off, On)", 28) {
+		t.Errorf("Expected unknown enum label diagnostic, got %+v", v.Diagnostics)
+	}
+
+	v = validateUserTypes(t, strings.Replace(userTypesContent, "NumberOfElements = 17", "NumberOfElements = 16", 1))
+	if !hasDiagnostic(v, "conflicting values 16 and 17", 35) {
+		t.Errorf("Expected IOGAM size mismatch, got %+v", v.Diagnostics)
+	}
+}
+
+func TestNewValidatorKeepsSchemaTypes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(userTypesSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pt := index.NewProjectTree()
+	v := validator.NewValidator(pt, dir, nil)
+	if pt.LookupType("Mode") != nil {
+		t.Error("NewValidator must not register the schema types in the tree")
+	}
+	validator.AttachSchema(pt, v.Schema)
+	if mode := pt.Clone().LookupType("Mode"); mode == nil || mode.Base != "uint8" {
+		t.Errorf("Expected the schema types to survive Clone, got %+v", mode)
+	}
+}
+
+func TestUserTypeDiagnostics(t *testing.T) {
+	content := strings.Replace(userTypesContent, "    Mode = { Type = Mode }\n",
+		"    Mode = { Type = Mode }\n    Self = { Type = Pose }\n    Empty = { NumberOfElements = 2 }\n", 1)
+	v := validateUserTypes(t, content)
+	if !hasDiagnostic(v, "Type 'Pose' contains itself (Pose → Pose)", 9) {
+		t.Errorf("Expected recursive type diagnostic, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Member 'Empty' of type 'Pose' must define Type", 15) {
+		t.Errorf("Expected missing member type diagnostic, got %+v", v.Diagnostics)
+	}
+
+	v = validateUserTypes(t, strings.Replace(userTypesContent, "Type = Mode Default = On", "Type = Moder", 1))
+	if !hasDiagnostic(v, "Invalid Type 'Moder'", 27) {
+		t.Errorf("Expected unknown type diagnostic, got %+v", v.Diagnostics)
+	}
+}
+
+func TestUserTypeHover(t *testing.T) {
+	lsp.ResetTestServer()
+	uri := "file://types.marte"
+	lsp.GetTestDocuments()[uri] = userTypesContent
+	cfg, err := parser.NewParser(userTypesContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	tree := lsp.GetTestTree()
+	tree.AddFile("types.marte", cfg)
+	tree.ResolveReferences(nil)
+	tree.SetSchemaTypes(map[string]*index.TypeDef{
+		"Mode": {Name: "Mode", Base: "uint8", Values: []string{"Off", "On"}},
+	})
+
+	hover := lsp.HandleHover(lsp.HoverParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 24, Character: 8},
+	})
+	if hover == nil {
+		t.Fatal("Expected hover for signal P")
+	}
+	text := hover.Contents.(lsp.MarkupContent).Value
+	for _, want := range []string{"**Size**: 17 bytes", "**Struct** `Pose` (17 bytes)", "| 4 | `Pos.Y` | `float32` | 4 |", "| 12 | `Flags` | `uint8[4]` | 4 |"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in hover:\n%s", want, text)
+		}
+	}
+
+	hover = lsp.HandleHover(lsp.HoverParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 26, Character: 8},
+	})
+	if hover == nil || !strings.Contains(hover.Contents.(lsp.MarkupContent).Value, "**Enum** `Mode`: `uint8` {Off, On}") {
+		t.Errorf("Expected enum description in hover, got %+v", hover)
+	}
+}
@@ -39,7 +39,7 @@ func TestMDSWriterValidation(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "TreeName: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'TreeName'") {
 			found = true
 			break
 		}
@@ -72,7 +72,7 @@ func TestMathExpressionGAMValidation(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "Expression: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'Expression'") {
 			found = true
 			break
 		}
@@ -36,10 +36,10 @@ func TestPIDGAMValidation(t *testing.T) {
 	foundKd := false
 
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "Ki: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'Ki'") {
 			foundKi = true
 		}
-		if strings.Contains(d.Message, "Kd: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'Kd'") {
 			foundKd = true
 		}
 	}
@@ -74,7 +74,7 @@ func TestFileDataSourceValidation(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "Filename: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'Filename'") {
 			found = true
 			break
 		}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"strings"
+	"testing"
+)
+
+const crossThreadContent = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timings = { Class = TimingDataSource }
+    +FastTimer = { Class = LinuxTimer Signals = { Counter = { Type = uint32 } } }
+    +SlowTimer = { Class = LinuxTimer Signals = { Counter = { Type = uint32 } } }
+    +Async = {
+      Class = RealTimeThreadAsyncBridge
+      Signals = { X = { Type = uint32 } Y = { Type = uint32 } Z = { Type = uint32 } }
+    }
+    +Sync = {
+      Class = RealTimeThreadSynchronisation
+      Signals = { S = { Type = uint32 } T = { Type = uint32 } }
+    }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Producer = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = FastTimer Type = uint32 Frequency = 1000 }
+        Y = { DataSource = Async Type = uint32 }
+        Z = { DataSource = Async Type = uint32 }
+      }
+      OutputSignals = {
+        X = { DataSource = Async Type = uint32 }
+        S = { DataSource = Sync Type = uint32 }
+        T = { DataSource = Sync Type = uint32 }
+      }
+    }
+    +Slow = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = SlowTimer Type = uint32 Frequency = 100 }
+        X = { DataSource = Async Type = uint32 NumberOfElements = 2 }
+      }
+      OutputSignals = {
+        Y = { DataSource = Async Type = uint32 }
+        X = { DataSource = Async Type = uint32 }
+      }
+    }
+    +Consumer = {
+      Class = IOGAM
+      InputSignals = {
+        S = { DataSource = Sync Type = uint32 Samples = 5 Frequency = 1 }
+        T = { DataSource = Sync Type = uint32 Samples = 10 }
+        Y = { DataSource = Async Type = uint32 }
+      }
+      OutputSignals = {
+        SOut = { DataSource = DDB Type = uint32 NumberOfElements = 5 }
+        TOut = { DataSource = DDB Type = uint32 NumberOfElements = 10 }
+        YOut = { DataSource = DDB Type = uint32 }
+      }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Fast = { Class = RealTimeThread Functions = { Producer } }
+        +Background = { Class = RealTimeThread Functions = { Slow } }
+        +Logger = { Class = RealTimeThread Functions = { Consumer } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+func TestCrossThreadFlow(t *testing.T) {
+	v := validateRTApp(t, crossThreadContent)
+
+	expected := []struct {
+		msg  string
+		line int
+	}{
+		{"Signal 'Z' of RealTimeThreadAsyncBridge 'Async' is read by GAM 'Producer' in thread 'Fast' of state 'Run' but no thread writes it", 26},
+		{"Signal 'X' of RealTimeThreadAsyncBridge 'Async' is written by GAM 'Slow' in thread 'Background' and by GAM 'Producer' in thread 'Fast' of state 'Run'; it allows one writer", 42},
+		{"Signal 'X' of RealTimeThreadAsyncBridge 'Async' is 8 bytes in GAM 'Slow' but 4 bytes in GAM 'Producer' that writes it", 38},
+		{"Thread 'Fast' reads signal 'Y' of RealTimeThreadAsyncBridge 'Async' at 1000 Hz but thread 'Background' writes it at 100 Hz", 25},
+		// Logger waits on Sync: it runs at 1000 Hz / 5 samples.
+		{"Thread 'Logger' reads signal 'Y' of RealTimeThreadAsyncBridge 'Async' at 200 Hz but thread 'Background' writes it at 100 Hz", 50},
+		{"Thread 'Logger' reads signal 'T' of RealTimeThreadSynchronisation 'Sync' with 10 samples per cycle but signal 'S' with 5", 49},
+	}
+	for _, e := range expected {
+		if !hasDiagnostic(v, e.msg, e.line) {
+			t.Errorf("Expected %q at line %d, got %+v", e.msg, e.line, v.Diagnostics)
+		}
+	}
+
+	// Readers of the synchronised signals take whole samples of the writer.
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "'S' of RealTimeThreadSynchronisation") {
+			t.Errorf("Unexpected diagnostic for S: %s", d.Message)
+		}
+	}
+
+	// A single writer running at least as fast as its readers is fine.
+	content := strings.Replace(crossThreadContent, "        X = { DataSource = Async Type = uint32 }\n      }\n    }\n    +Consumer", "      }\n    }\n    +Consumer", 1)
+	content = strings.Replace(content, "X = { DataSource = Async Type = uint32 NumberOfElements = 2 }", "X = { DataSource = Async Type = uint32 }", 1)
+	content = strings.Replace(content, "Frequency = 100 }", "Frequency = 1000 }", 1)
+	content = strings.Replace(content, "Z = { DataSource = Async Type = uint32 }\n", "", 1)
+	v = validateRTApp(t, content)
+	for _, d := range v.Diagnostics {
+		for _, tag := range []string{"no thread writes it", "allows one writer", "that writes it", " Hz but thread"} {
+			if strings.Contains(d.Message, tag) {
+				t.Errorf("Unexpected diagnostic: %s", d.Message)
+			}
+		}
+	}
+}
@@ -80,7 +80,7 @@ func TestGAMSchedulerValidation(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "TimingDataSource: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'TimingDataSource'") {
 			found = true
 			break
 		}
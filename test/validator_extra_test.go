@@ -66,7 +66,7 @@ func TestFileWriterValidation(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "Filename: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'Filename'") {
 			found = true
 			break
 		}
@@ -67,7 +67,7 @@ func TestValidator_IOGAM(t *testing.T) {
 					}
 				}
 			}`,
-			expected: []string{"Schema Validation Error"},
+			expected: []string{"Field 'InputSize' of class 'IOGAM' has conflicting values"},
 		},
 		{
 			name: "IOGAM with DataSource Reference",
@@ -315,7 +315,7 @@ func TestValidator_IOGAM(t *testing.T) {
 
 			if len(tt.expected) == 0 {
 				for _, d := range v.Diagnostics {
-					if strings.Contains(d.Message, "IOGAM Size Mismatch") || strings.Contains(d.Message, "Schema Validation Error") {
+					if strings.Contains(d.Message, "IOGAM Size Mismatch") || strings.HasPrefix(d.Rule, "schema_") {
 						t.Errorf("Unexpected IOGAM error: %s", d.Message)
 					}
 				}
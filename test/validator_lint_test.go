@@ -0,0 +1,247 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const lintContent = `#var gain_value: float32 = 1.0
+#template Filter(Coeff: float32 = 1.0)
+  Gain = @Coeff
+#end
+
+$App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DSInputs
+    +DSInputs = {
+      Class = GAMDataSource
+      Signals = {
+        my_signal = { Type = uint32 }
+        AVeryLongSignalNameThatNobodyCanType = { Type = uint32 }
+        Out1 = { Type = uint32 }
+        Out2 = { Type = uint32 }
+        Out3 = { Type = uint32 }
+      }
+    }
+    +Timer = { Class = LinuxTimer Signals = { Counter = { Type = uint32 } Time = { Type = uint32 } } }
+    +DSTimings = { Class = TimingDataSource } +DSLogger = { Class = LoggerDataSource Signals = { Out1 = { Type = uint32 } Out2 = { Type = uint32 } Out3 = { Type = uint32 } } }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Copy = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = Timer Type = uint32 }
+        my_signal = { DataSource = DSInputs }
+        AVeryLongSignalNameThatNobodyCanType = { DataSource = DSInputs }
+      }
+      OutputSignals = {
+        Out1 = { DataSource = DSInputs Type = uint32 }
+        Out2 = { DataSource = DSInputs Type = uint32 }
+        Out3 = { DataSource = DSInputs Type = uint32 }
+      }
+    }
+    +InitGAM = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = Timer Type = uint32 Frequency = 100 }
+        Time = { DataSource = Timer Type = uint32 }
+      }
+      OutputSignals = {
+        my_signal = { DataSource = DSInputs Type = uint32 }
+        AVeryLongSignalNameThatNobodyCanType = { DataSource = DSInputs Type = uint32 }
+      }
+    }
+    +LogGAM = {
+      Class = IOGAM
+      InputSignals = {
+        Out1 = { DataSource = DSInputs Type = uint32 }
+        Out2 = { DataSource = DSInputs Type = uint32 }
+        Out3 = { DataSource = DSInputs Type = uint32 }
+      }
+      OutputSignals = {
+        Out1 = { DataSource = DSLogger Type = uint32 }
+        Out2 = { DataSource = DSLogger Type = uint32 }
+        Out3 = { DataSource = DSLogger Type = uint32 }
+      }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Main = { Class = RealTimeThread Functions = { InitGAM Copy LogGAM } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = DSTimings }
+}
+`
+
+const lintSchema = `#Lint: {
+	naming: {
+		gam:        "^[A-Z][A-Za-z0-9]*GAM$"
+		datasource: "^DS[A-Z][A-Za-z0-9]*$"
+		signal:     "^[A-Z][A-Za-z0-9]*$"
+		variable:   "^[a-z][A-Za-z0-9]*$"
+		template:   "^[A-Z][A-Za-z0-9]*Template$"
+	}
+	maxLength:       32
+	plusDefinitions: true
+}
+`
+
+func validateLint(t *testing.T, content string, rules string) *validator.Validator {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("app.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, dir, nil)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+// lintDiagnostics drops the warnings about the variable and the template the
+// fixture defines without using.
+func lintDiagnostics(v *validator.Validator) []validator.Diagnostic {
+	var diags []validator.Diagnostic
+	for _, d := range v.Diagnostics {
+		if d.Rule != "unused_variable" && d.Rule != "unused_template" {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+func TestValidatorNamingLint(t *testing.T) {
+	v := validateLint(t, lintContent, lintSchema)
+
+	expected := []struct {
+		msg  string
+		line int
+	}{
+		{"GAM name 'Copy' does not match the naming rule '^[A-Z][A-Za-z0-9]*GAM$'; rename to 'CopyGAM'", 26},
+		{"DataSource name 'Timer' does not match the naming rule '^DS[A-Z][A-Za-z0-9]*$'; rename to 'DSTimer'", 21},
+		{"Signal name 'my_signal' does not match the naming rule '^[A-Z][A-Za-z0-9]*$'; rename to 'MySignal'", 14},
+		{"Signal name 'my_signal' does not match the naming rule '^[A-Z][A-Za-z0-9]*$'; rename to 'MySignal'", 30},
+		{"Signal name 'AVeryLongSignalNameThatNobodyCanType' is 36 characters long; names are limited to 32", 15},
+		{"Signal name 'AVeryLongSignalNameThatNobodyCanType' is 36 characters long; names are limited to 32", 31},
+		{"Signal name 'my_signal' does not match the naming rule '^[A-Z][A-Za-z0-9]*$'; rename to 'MySignal'", 46},
+		{"Signal name 'AVeryLongSignalNameThatNobodyCanType' is 36 characters long; names are limited to 32", 47},
+		{"Variable name 'gain_value' does not match the naming rule '^[a-z][A-Za-z0-9]*$'; rename to 'gainValue'", 1},
+		{"Template name 'Filter' does not match the naming rule '^[A-Z][A-Za-z0-9]*Template$'; rename to 'FilterTemplate'", 2},
+		{"Object '$App' is defined with '$' outside Functions and Data; define it as '+App'", 6},
+	}
+	for _, e := range expected {
+		if !hasDiagnostic(v, e.msg, e.line) {
+			t.Errorf("Expected %q at line %d, got %+v", e.msg, e.line, v.Diagnostics)
+		}
+	}
+	for _, d := range v.Diagnostics {
+		if d.Level != validator.LevelWarning {
+			t.Errorf("Expected only warnings, got %+v", d)
+		}
+	}
+	if diags := lintDiagnostics(v); len(diags) != len(expected) {
+		t.Errorf("Expected %d diagnostics, got %+v", len(expected), diags)
+	}
+
+	// Without rules nothing is reported.
+	v = validateLint(t, lintContent, "")
+	for _, d := range lintDiagnostics(v) {
+		t.Errorf("Unexpected diagnostic without naming rules: %+v", d)
+	}
+}
+
+func TestValidatorInvalidNamingRule(t *testing.T) {
+	v := validateLint(t, lintContent, `#Lint: naming: {
+	gam:    "^[A-Z"
+	signal: "^[A-Z][A-Za-z0-9]*$"
+}
+`)
+	found := false
+	for _, d := range findRule(v, "invalid_rule") {
+		if d.Message == "Naming rule 'gam' is not a valid regular expression: error parsing regexp: missing closing ]: `[A-Z`" {
+			found = true
+			if filepath.Base(d.File) != ".marte_schema.cue" || d.Position.Line != 2 {
+				t.Errorf("Expected the error at the pattern, got %+v", d)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected invalid naming rule error, got %+v", v.Diagnostics)
+	}
+}
+
+func TestLSPNamingQuickFix(t *testing.T) {
+	lsp.ResetTestServer()
+	lsp.GlobalSchema = schema.LoadFullSchema(".")
+	var buf bytes.Buffer
+	lsp.Output = &buf
+
+	uri := "file://lint.marte"
+	lsp.HandleDidOpen(lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Text: lintContent},
+	})
+
+	codeActions := func(line, char int, msg string) []lsp.CodeAction {
+		pos := lsp.Position{Line: line, Character: char}
+		return lsp.HandleCodeAction(lsp.CodeActionParams{
+			TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+			Range:        lsp.Range{Start: pos, End: pos},
+			Context: lsp.CodeActionContext{
+				Diagnostics: []lsp.LSPDiagnostic{{Range: lsp.Range{Start: pos, End: pos}, Message: msg}},
+			},
+		})
+	}
+
+	actions := codeActions(25, 4, "GAM name 'Copy' does not match the naming rule '^[A-Z][A-Za-z0-9]*GAM$'; rename to 'CopyGAM'")
+	var rename *lsp.CodeAction
+	for i := range actions {
+		if actions[i].Title == "Rename to 'CopyGAM'" {
+			rename = &actions[i]
+		}
+	}
+	if rename == nil || rename.Edit == nil {
+		t.Fatalf("Expected a rename quick fix, got %+v", actions)
+	}
+	lines := make(map[int]string)
+	for _, e := range rename.Edit.Changes[uri] {
+		lines[e.Range.Start.Line] = e.NewText
+	}
+	// The definition and the reference in the thread are renamed.
+	if lines[25] != "+CopyGAM" || lines[69] != "CopyGAM" {
+		t.Errorf("Unexpected rename edits: %+v", rename.Edit.Changes)
+	}
+
+	actions = codeActions(0, 0, "Variable name 'gain_value' does not match the naming rule '^[a-z][A-Za-z0-9]*$'; rename to 'gainValue'")
+	if len(actions) != 1 || len(actions[0].Edit.Changes[uri]) != 1 || actions[0].Edit.Changes[uri][0].NewText != "gainValue" {
+		t.Errorf("Expected a quick fix renaming the variable, got %+v", actions)
+	}
+
+	actions = codeActions(5, 0, "Object '$App' is defined with '$' outside Functions and Data; define it as '+App'")
+	if len(actions) != 1 || actions[0].Edit.Changes[uri][0].NewText != "+" {
+		t.Errorf("Expected a quick fix replacing '$' with '+', got %+v", actions)
+	}
+}
@@ -59,7 +59,7 @@ package schema
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "CustomField: incomplete value") {
+		if strings.Contains(d.Message, "Missing mandatory field 'CustomField'") {
 			found = true
 			break
 		}
@@ -0,0 +1,155 @@
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const releaseContent = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timer = {
+      Class = LinuxTimer
+      Phase = 10
+      Signals = { Counter = { Type = uint32 } }
+    }
+    +Gate = { Class = MemoryGate Signals = { X = { Type = uint32 } } }
+    +Timings = { Class = TimingDataSource }
+    +Replay = { Class = FileReader Filename = "in.csv" Interpolate = "no" Signals = { Y = { Type = uint32 } } }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Copy = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = Timer Type = uint32 Frequency = 100 } }
+      OutputSignals = { X = { DataSource = Gate Type = uint32 } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = { Class = ReferenceContainer +T1 = { Class = RealTimeThread Functions = { Copy } } }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+// validateWithSchema validates content with text as the project schema.
+func validateWithSchema(t *testing.T, content string, text string, opts ...schema.Option) *validator.Validator {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("app.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, dir, nil, opts...)
+	validator.AttachSchema(pt, v.Schema)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+const unavailableGate = "Class 'MemoryGate' is not available in MARTe2-components v1.3"
+
+func TestSchemaVersions(t *testing.T) {
+	versions := schema.Versions()
+	if len(versions) < 2 || !schema.HasVersion("v1.3") || schema.HasVersion("v0.0") {
+		t.Errorf("Unexpected bundled versions: %v", versions)
+	}
+}
+
+func TestValidatorRelease(t *testing.T) {
+	// Without a target every class is available.
+	v := validateWithSchema(t, releaseContent, "")
+	if hasDiagnostic(v, unavailableGate, 12) {
+		t.Errorf("Unexpected release diagnostic without a target: %+v", v.Diagnostics)
+	}
+
+	v = validateWithSchema(t, releaseContent, `#Target: version: "v1.3"`)
+	if !hasDiagnostic(v, unavailableGate, 12) {
+		t.Errorf("Expected MemoryGate to be unavailable in v1.3, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Field 'Phase' of class 'LinuxTimer' is not available in MARTe2-components v1.3", 9) {
+		t.Errorf("Expected Phase to be unavailable in v1.3, got %+v", v.Diagnostics)
+	}
+	v = validateWithSchema(t, releaseContent, `#Target: version: "v1.5"`)
+	if hasDiagnostic(v, unavailableGate, 12) {
+		t.Errorf("Expected MemoryGate to be available in v1.5, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Field 'Phase' of class 'LinuxTimer' is not available in MARTe2-components v1.5", 9) {
+		t.Errorf("Expected Phase to be unavailable in v1.5, got %+v", v.Diagnostics)
+	}
+
+	// A project describes further parameters missing from its release.
+	v = validateWithSchema(t, releaseContent, `#Target: version: "v1.5"
+#Release: unavailable: fields: FileReader: ["Interpolate"]
+`)
+	if !hasDiagnostic(v, "Field 'Interpolate' of class 'FileReader' is not available in MARTe2-components v1.5", 14) {
+		t.Errorf("Expected Interpolate to be unavailable, got %+v", v.Diagnostics)
+	}
+
+	// --marte-version overrides the target of the project.
+	v = validateWithSchema(t, releaseContent, `#Target: version: "v1.3"`, schema.WithVersion("v1.5"))
+	if hasDiagnostic(v, unavailableGate, 12) {
+		t.Errorf("Expected the selected version to override the target, got %+v", v.Diagnostics)
+	}
+}
+
+func TestValidatorUnknownRelease(t *testing.T) {
+	v := validateWithSchema(t, releaseContent, `
+#Target: version: "v0.9"
+`)
+	found := false
+	for _, d := range v.Diagnostics {
+		if d.Message == "Unknown MARTe version 'v0.9' (bundled: v1.3, v1.5)" {
+			found = true
+			if filepath.Base(d.File) != ".marte_schema.cue" || d.Position.Line != 2 {
+				t.Errorf("Expected the error at the target, got %+v", d)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected unknown version error, got %+v", v.Diagnostics)
+	}
+}
+
+func TestLSPMARTeVersionOption(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(`#Target: version: "v1.5"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ option, want string }{
+		{"", "v1.5"},
+		{"v1.3", "v1.3"},
+		{"v0.9", "v1.5"}, // not bundled: the #Target is kept
+	} {
+		lsp.ResetTestServer()
+		params, _ := json.Marshal(lsp.InitializeParams{
+			RootPath:              dir,
+			InitializationOptions: lsp.InitializationOptions{MARTeVersion: tt.option},
+		})
+		lsp.HandleMessage(&lsp.JsonRpcMessage{Method: "initialize", Params: params, ID: 1})
+		if lsp.GlobalSchema == nil || lsp.GlobalSchema.Version != tt.want {
+			t.Errorf("marteVersion %q: expected the schema of %s, got %+v", tt.option, tt.want, lsp.GlobalSchema)
+		}
+	}
+}
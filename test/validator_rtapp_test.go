@@ -0,0 +1,112 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const rtAppContent = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timings = { Class = TimingDataSource }
+    +Timer = {
+      Class = LinuxTimer
+      Signals = {
+        Counter = { Type = uint32 }
+        Time = { Type = uint32 }
+      }
+    }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Sync = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = Timer Type = uint32 Frequency = 100 } }
+      OutputSignals = { A = { DataSource = DDB Type = uint32 } }
+    }
+    +Resync = {
+      Class = IOGAM
+      InputSignals = { Time = { DataSource = Timer Type = uint32 Frequency = 10 } }
+      OutputSignals = { B = { DataSource = DDB Type = uint32 } }
+    }
+    +Work = {
+      Class = IOGAM
+      InputSignals = { A = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { C = { DataSource = DDB Type = uint32 } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Fast = { Class = RealTimeThread Functions = { Sync Resync } }
+        +Late = { Class = RealTimeThread Functions = { Work Resync } }
+        +Free = { Class = RealTimeThread Functions = { Work } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+func validateRTApp(t *testing.T, content string) *validator.Validator {
+	t.Helper()
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("app.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, "", nil)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func TestRealTimeThreadSync(t *testing.T) {
+	v := validateRTApp(t, rtAppContent)
+	if !hasDiagnostic(v, "Signal 'Time' of GAM 'Resync' is a second synchronising input of thread 'Fast' in state 'Run' (first: 'Counter' of GAM 'Sync')", 25) {
+		t.Errorf("Expected multiple synchronising inputs error, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Thread 'Late' of state 'Run' synchronises on GAM 'Resync' but starts with GAM 'Work'", 41) {
+		t.Errorf("Expected late synchronisation warning, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Thread 'Free' of state 'Run' has no synchronising input", 42) {
+		t.Errorf("Expected missing synchronisation warning, got %+v", v.Diagnostics)
+	}
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "TimingDataSource") {
+			t.Errorf("Unexpected diagnostic: %s", d.Message)
+		}
+	}
+
+	// A signal with both annotations is a single synchronising input.
+	v = validateRTApp(t, strings.Replace(rtAppContent, "Frequency = 100", "Frequency = 100 Trigger = 1", 1))
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "'Counter' of GAM 'Sync' is a second") {
+			t.Errorf("Unexpected diagnostic: %s", d.Message)
+		}
+	}
+}
+
+func TestRealTimeScheduler(t *testing.T) {
+	v := validateRTApp(t, strings.Replace(rtAppContent, "TimingDataSource = Timings", "TimingDataSource = Timer", 1))
+	if !hasDiagnostic(v, "TimingDataSource 'Timer' of 'App' must be a TimingDataSource, not LinuxTimer", 46) {
+		t.Errorf("Expected TimingDataSource class error, got %+v", v.Diagnostics)
+	}
+
+	v = validateRTApp(t, strings.Replace(rtAppContent, "TimingDataSource = Timings", `TimingDataSource = "Timngs"`, 1))
+	if !hasDiagnostic(v, "TimingDataSource 'Timngs' of 'App' does not exist", 46) {
+		t.Errorf("Expected missing TimingDataSource error, got %+v", v.Diagnostics)
+	}
+}
@@ -0,0 +1,160 @@
+package integration
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const rulesContent = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource Signals = { X = { Type = uint32 } Y = { Type = uint32 } Z = { Type = uint32 } } }
+    +Timer = { Class = LinuxTimer Signals = { Counter = { Type = uint32 } Time = { Type = uint32 } } }
+    +Store = {
+      Class = MDSWriter
+      NumberOfBuffers = 10
+      CPUMask = 1
+      StackSize = 10000000
+      TreeName = "test"
+      StoreOnTrigger = 0
+      Signals = { X = { Type = uint32 } Y = { Type = uint32 } }
+    }
+    +Timings = { Class = TimingDataSource }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Acq = {
+      Class = IOGAM
+      InputSignals = {
+        Counter = { DataSource = Timer Frequency = 100 }
+        Time = { DataSource = Timer }
+      }
+      OutputSignals = { X = { DataSource = DDB Type = uint32 } Y = { DataSource = DDB Type = uint32 } }
+    }
+    +LocalLog = {
+      Class = IOGAM
+      InputSignals = { X = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { X = { DataSource = Store } }
+    }
+    +RemoteLog = {
+      Class = IOGAM
+      InputSignals = { Y = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { Y = { DataSource = Store } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +T1 = { Class = RealTimeThread Functions = { Acq LocalLog } }
+        +T2 = { Class = RealTimeThread Functions = { RemoteLog } }
+      }
+    }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+`
+
+const rulesSchema = `import "list"
+
+#Rules: MDSWriterSameThread: {
+	graph:   _
+	message: "MDSWriter signals must come from a GAM in the same thread"
+	violations: [
+		for ds in graph.datasources if ds.class == "MDSWriter"
+		for sig in ds.signals
+		for w in sig.producers
+		for i in graph.gams[w.gam].inputs if i.datasource != ""
+		for p in graph.datasources[i.datasource].signals[i.signal].producers
+		if len([for t in p.threads if list.Contains(w.threads, t) {t}]) == 0 {w.signal},
+	]
+}
+
+#Rules: NoUnusedDDB: {
+	graph: _
+	level: "warning"
+	message: "unused"
+	violations: [
+		for ds in graph.datasources if ds.class == "GAMDataSource"
+		for sig in ds.signals if len(sig.consumers) == 0 {
+			path:    sig.path
+			message: "Signal \(sig.name) of \(ds.name) is never read"
+		},
+	]
+}
+`
+
+func TestRuleGraph(t *testing.T) {
+	g := validateRTApp(t, rulesContent).RuleGraph()
+
+	acq := g.GAMs["App.Functions.Acq"]
+	if acq == nil || acq.Class != "IOGAM" || len(acq.Threads) != 1 || acq.Threads[0] != "App.States.Run.Threads.T1" {
+		t.Fatalf("Unexpected GAM export: %+v", acq)
+	}
+	if in := acq.Inputs["Counter"]; in == nil || in.DataSource != "App.Data.Timer" || in.Type != "uint32" || in.Elements != 1 {
+		t.Errorf("Unexpected GAM signal export: %+v", acq.Inputs["Counter"])
+	}
+
+	y := g.DataSources["App.Data.Store"].Signals["Y"]
+	if y == nil || len(y.Producers) != 1 || y.Producers[0].GAM != "App.Functions.RemoteLog" || y.Producers[0].Threads[0] != "App.States.Run.Threads.T2" {
+		t.Errorf("Unexpected DataSource signal export: %+v", y)
+	}
+	if x := g.DataSources["App.Data.DDB"].Signals["X"]; x == nil || len(x.Producers) != 1 || len(x.Consumers) != 1 {
+		t.Errorf("Expected DDB.X written by Acq and read by LocalLog, got %+v", x)
+	}
+	if th := g.Threads["App.States.Run.Threads.T1"]; th == nil || th.State != "App.States.Run" || len(th.GAMs) != 2 {
+		t.Errorf("Unexpected thread export: %+v", th)
+	}
+	if st := g.States["App.States.Run"]; st == nil || len(st.Threads) != 2 {
+		t.Errorf("Unexpected state export: %+v", st)
+	}
+}
+
+func TestValidatorProjectRules(t *testing.T) {
+	v := validateLint(t, rulesContent, rulesSchema)
+
+	if !hasDiagnostic(v, "MDSWriter signals must come from a GAM in the same thread", 37) {
+		t.Errorf("Expected rule violation on RemoteLog.Y, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Signal Z of DDB is never read", 6) {
+		t.Errorf("Expected rule violation with its own message, got %+v", v.Diagnostics)
+	}
+	for _, d := range v.Diagnostics {
+		switch {
+		case strings.HasPrefix(d.Message, "MDSWriter signals") && d.Level != validator.LevelError,
+			strings.HasSuffix(d.Message, "is never read") && d.Level != validator.LevelWarning:
+			t.Errorf("Unexpected level: %+v", d)
+		case d.Message == "MDSWriter signals must come from a GAM in the same thread" && d.Position.Line != 37:
+			t.Errorf("Unexpected violation: %+v", d)
+		}
+	}
+}
+
+func TestValidatorProjectRuleError(t *testing.T) {
+	schema := `#Rules: Broken: {
+	graph: _
+	message: "never"
+	violations: [for g in graph.gams {g.missing}]
+}
+`
+	v := validateLint(t, rulesContent, schema)
+	found := false
+	for _, d := range v.Diagnostics {
+		if strings.HasPrefix(d.Message, "Rule 'Broken' cannot be evaluated") {
+			found = true
+			if filepath.Base(d.File) != ".marte_schema.cue" || d.Position.Line != 1 {
+				t.Errorf("Expected the error at the rule definition, got %+v", d)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected rule evaluation error, got %+v", v.Diagnostics)
+	}
+}
@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const schemaErrorsTimer = `#package Proj.Data
+
++Timer = {
+  Class = LinuxTimer
+  Signals = {
+    Counter = { Type = uint32 Kind = 3 }
+  }
+}
+`
+
+const schemaErrorsTimerSettings = `#package Proj.Data
+
++Timer = {
+  ExecutionMode = 5
+  SleepNature = Fast
+  CPUMask = -1
+}
+
++PID = {
+  Class = PIDGAM
+  Kp = 1
+}
+`
+
+func findRule(v *validator.Validator, rule string) []validator.Diagnostic {
+	var found []validator.Diagnostic
+	for _, d := range v.Diagnostics {
+		if d.Rule == rule {
+			found = append(found, d)
+		}
+	}
+	return found
+}
+
+func TestSchemaErrorLocations(t *testing.T) {
+	schemaText := `#Classes: LinuxTimer: {
+	CPUMask?: int & >=0
+	Signals: [_]: {Kind?: "a" | "b", ...}
+}
+`
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(schemaText), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pt := index.NewProjectTree()
+	for _, f := range []struct{ name, content string }{
+		{"timer.marte", schemaErrorsTimer},
+		{"settings.marte", schemaErrorsTimerSettings},
+	} {
+		config, err := parser.NewParser(f.content).Parse()
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		pt.AddFile(f.name, config)
+	}
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, dir, nil)
+	v.ValidateProject(context.Background())
+
+	expected := []struct {
+		rule, msg, file string
+		line, col       int
+	}{
+		{"schema_invalid_enum", `Invalid value Fast for field 'SleepNature' of class 'LinuxTimer': expected one of "Busy", "Default"`, "settings.marte", 5, 3},
+		{"schema_type_mismatch", "Field 'ExecutionMode' of class 'LinuxTimer' must be a string, got an integer 5", "settings.marte", 4, 3},
+		{"schema_out_of_range", "Value -1 of field 'CPUMask' of class 'LinuxTimer' is out of range: expected >=0", "settings.marte", 6, 3},
+		{"schema_invalid_enum", `Invalid value 3 for field 'Signals.Counter.Kind' of class 'LinuxTimer': expected one of "a", "b"`, "timer.marte", 6, 31},
+		{"schema_missing_field", "Missing mandatory field 'Ki' of class 'PIDGAM'", "settings.marte", 9, 1},
+	}
+	for _, e := range expected {
+		found := false
+		for _, d := range findRule(v, e.rule) {
+			if d.Message == e.msg {
+				found = true
+				if d.File != e.file || d.Position.Line != e.line || d.Position.Column != e.col {
+					t.Errorf("%q reported at %s:%d:%d, expected %s:%d:%d", e.msg, d.File, d.Position.Line, d.Position.Column, e.file, e.line, e.col)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s %q, got %+v", e.rule, e.msg, v.Diagnostics)
+		}
+	}
+}
+
+func TestSchemaErrorSuppression(t *testing.T) {
+	// The rule IDs of schema errors can be ignored, and so can all of them
+	// through the older schema_validation tag.
+	for _, pragma := range []string{"schema_missing_field", "schema_validation"} {
+		content := "//! ignore(" + pragma + ")\n+PID = {\n  Class = PIDGAM\n  Kp = 1\n}\n"
+		v := validateWithSchema(t, content, "")
+		if d := findRule(v, "schema_missing_field"); len(d) != 0 {
+			t.Errorf("Expected ignore(%s) to suppress missing fields, got %+v", pragma, d)
+		}
+	}
+}
@@ -16,7 +16,7 @@ func TestSchemaMetaValidation(t *testing.T) {
 +App = {
     Class = RealTimeApplication
     Functions = { Class = ReferenceContainer }
-    Data = { Class = ReferenceContainer DefaultDataSource = "DS" }
+    Data = { Class = ReferenceContainer DefaultDataSource = "DS" +DS = { Class = TimingDataSource } }
     States = { Class = ReferenceContainer }
     Scheduler = { Class = GAMScheduler TimingDataSource = "DS" }
     #meta = {
@@ -47,7 +47,7 @@ func TestSchemaMetaValidation(t *testing.T) {
 +App = {
     Class = RealTimeApplication
     Functions = { Class = ReferenceContainer }
-    Data = { Class = ReferenceContainer DefaultDataSource = "DS" }
+    Data = { Class = ReferenceContainer DefaultDataSource = "DS" +DS = { Class = TimingDataSource } }
     States = { Class = ReferenceContainer }
     Scheduler = { Class = GAMScheduler TimingDataSource = "DS" }
     #meta = {
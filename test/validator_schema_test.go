@@ -33,7 +33,7 @@ func TestSchemaValidationType(t *testing.T) {
 
 	found := false
 	for _, d := range v.Diagnostics {
-		if strings.Contains(d.Message, "mismatched types") {
+		if d.Rule == "schema_type_mismatch" && strings.Contains(d.Message, "Field 'First'") {
 			found = true
 			break
 		}
@@ -0,0 +1,129 @@
+package integration
+
+import (
+	"testing"
+)
+
+const signalRulesContent = `
++Data = {
+  Class = ReferenceContainer
+  +Timer = {
+    Class = LinuxTimer
+    Signals = {
+      Counter = { Type = uint32 }
+      Time = { Type = float32 }
+    }
+  }
+  +Timer2 = {
+    Class = LinuxTimer
+    Signals = {
+      Counter = { Type = uint64 }
+    }
+  }
+  +CA = {
+    Class = EPICSCAInput
+    Signals = {
+      Name = { Type = char8 NumberOfElements = 20 PVName = "NAME" }
+      Label = { Type = char8 NumberOfElements = 40 PVName = "LABEL" }
+    }
+  }
+  +Acq = {
+    Class = AcqDataSource
+    Signals = {
+      Channel = { Type = int16 }
+      Debug = { Type = int16 }
+    }
+  }
+  +DDB = {
+    Class = GAMDataSource
+  }
+}
+
++Functions = {
+  Class = ReferenceContainer
+  //! ignore(unused)
+  +Read = {
+    Class = IOGAM
+    InputSignals = {
+      Counter = { DataSource = Timer Type = uint32 }
+      Period = { DataSource = Timer2 Alias = Time Type = float64 }
+      Name = { DataSource = CA Type = char8 NumberOfElements = 20 }
+      Label = { DataSource = CA Type = char8 NumberOfElements = 40 }
+      Channel = { DataSource = Acq Type = int16 }
+      Debug = { DataSource = Acq Type = int16 }
+    }
+    OutputSignals = {
+      Counter = { DataSource = DDB Type = uint32 }
+      Period = { DataSource = DDB Type = float64 }
+      Name = { DataSource = DDB Type = char8 NumberOfElements = 20 }
+      Label = { DataSource = DDB Type = char8 NumberOfElements = 40 }
+      Channel = { DataSource = DDB Type = int16 }
+      Debug = { DataSource = DDB Type = int16 }
+    }
+  }
+  //! ignore(unused)
+  +Convert = {
+    Class = ConversionGAM
+    InputSignals = {
+      Channel = { DataSource = DDB Type = int16 }
+      Samples = { DataSource = DDB Type = int16 NumberOfElements = 4 NumberOfDimensions = 1 }
+    }
+    OutputSignals = {
+      ChannelF = { DataSource = DDB Type = float32 }
+      SamplesF = { DataSource = DDB Type = float32 NumberOfElements = 2 NumberOfDimensions = 1 }
+      Text = { DataSource = DDB Type = char8 NumberOfElements = 4 NumberOfDimensions = 1 }
+    }
+  }
+}
+`
+
+const signalRulesSchema = `#Classes: AcqDataSource: {
+	#meta: MetaType:  "datasource"
+	#meta: direction: "IN"
+	#meta: Signals: {
+		Channel?: {Type: "int16", NumberOfDimensions: <=1}
+	}
+	...
+}
+`
+
+func TestSignalRules(t *testing.T) {
+	v := validateWithSchema(t, signalRulesContent, signalRulesSchema)
+
+	expected := []struct {
+		rule, msg string
+		line      int
+	}{
+		{"signal_incompatible", "Signal 'Time' of DataSource '+Timer' (class 'LinuxTimer') cannot have Type float32: expected one of uint32, uint64", 8},
+		{"signal_incompatible", "Signal 'Time' of DataSource '+Timer2' (class 'LinuxTimer') cannot have Type float64: expected one of uint32, uint64", 0},
+		{"signal_incompatible", "Signal 'Name' of DataSource '+CA' (class 'EPICSCAInput') cannot have NumberOfElements 20: expected 40", 20},
+		{"signal_not_allowed", "Signal 'Debug' is not allowed in DataSource '+Acq' (class 'AcqDataSource')", 28},
+		{"signal_incompatible", "Output signal 'Text' of GAM '+Convert' (class 'ConversionGAM') cannot have Type char8: expected one of float32, float64, int16, int32, int64, int8, uint16, uint32, uint64, uint8", 68},
+		{"signal_pair_mismatch", "Output signal 'SamplesF' of GAM '+Convert' (class 'ConversionGAM') cannot have NumberOfElements 2 paired with 'Samples': expected 4", 67},
+		{"signal_pair_mismatch", "GAM '+Convert' (class 'ConversionGAM') pairs its input and output signals but has 2 input and 3 output signals", 59},
+	}
+	for _, e := range expected {
+		found := false
+		for _, d := range findRule(v, e.rule) {
+			if d.Message == e.msg {
+				found = true
+				if e.line != 0 && d.Position.Line != e.line {
+					t.Errorf("%q reported at line %d, expected %d", e.msg, d.Position.Line, e.line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s %q, got %+v", e.rule, e.msg, v.Diagnostics)
+		}
+	}
+
+	count := 0
+	for _, d := range v.Diagnostics {
+		if d.Rule == "signal_incompatible" || d.Rule == "signal_not_allowed" || d.Rule == "signal_pair_mismatch" {
+			count++
+		}
+	}
+	if count != len(expected) {
+		t.Errorf("Expected %d signal rule diagnostics, got %+v", len(expected), v.Diagnostics)
+	}
+}
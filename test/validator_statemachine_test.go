@@ -0,0 +1,178 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const stateMachineContent = `+App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timings = { Class = TimingDataSource }
+  }
+  +Functions = { Class = ReferenceContainer }
+  +States = {
+    Class = ReferenceContainer
+    +Idle = { Class = RealTimeState +Threads = { Class = ReferenceContainer } }
+    +Run = { Class = RealTimeState +Threads = { Class = ReferenceContainer } }
+  }
+  +Scheduler = { Class = GAMScheduler TimingDataSource = Timings }
+}
+
++SM = {
+  Class = StateMachine
+  +INITIAL = {
+    Class = ReferenceContainer
+    +START = {
+      Class = StateMachineEvent
+      NextState = "IDLE"
+      NextStateError = "EROR"
+      Timeout = 0
+      +Prepare = {
+        Class = Message
+        Destination = App
+        Function = PrepareNextState
+        Mode = ExpectsReply
+        +Parameters = { Class = ConfigurationDatabase param1 = Idel }
+      }
+      +Go = {
+        Class = Message
+        Destination = "App"
+        Function = StartNextState
+        Mode = ExpectsReply
+      }
+    }
+  }
+  +IDLE = {
+    Class = ReferenceContainer
+    +GOTORUN = {
+      Class = StateMachineEvent
+      NextState = RUN
+      NextStateError = ERROR
+      +Self = { Class = Message Destination = "SM" Function = GOTOSTOP }
+      +Lost = { Class = Message Destination = "Nowhere" Function = X }
+    }
+  }
+  +RUN = { Class = ReferenceContainer }
+  +ERROR = {
+    Class = ReferenceContainer
+    +RESET = { Class = StateMachineEvent NextState = IDLE NextStateError = ERROR }
+  }
+  +ORPHAN = {
+    Class = ReferenceContainer
+    +X = { Class = StateMachineEvent NextState = IDLE NextStateError = ERROR }
+  }
+}
+`
+
+func validateStateMachine(t *testing.T, pt *index.ProjectTree) *validator.Validator {
+	t.Helper()
+	config, err := parser.NewParser(stateMachineContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt.AddFile("sm.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func TestStateMachineTransitions(t *testing.T) {
+	v := validateStateMachine(t, index.NewProjectTree())
+	for _, want := range []struct {
+		msg  string
+		line int
+	}{
+		{"NextStateError 'EROR' of event 'START' is not a state of StateMachine 'SM'", 25},
+		{"State 'RUN' of StateMachine 'SM' has no StateMachineEvent and can never be left", 52},
+		{"State 'ORPHAN' of StateMachine 'SM' is not reachable from 'INITIAL'", 57},
+	} {
+		if !hasDiagnostic(v, want.msg, want.line) {
+			t.Errorf("Expected %q on line %d, got %+v", want.msg, want.line, v.Diagnostics)
+		}
+	}
+	for _, d := range v.Diagnostics {
+		if d.Position.Line == 46 || d.Position.Line == 47 {
+			t.Errorf("Bare state names should not be resolved as references: %s", d.Message)
+		}
+	}
+}
+
+func TestStateMachineMessages(t *testing.T) {
+	v := validateStateMachine(t, index.NewProjectTree())
+	for _, want := range []struct {
+		msg  string
+		line int
+	}{
+		{"PrepareNextState 'Idel' of Message 'Prepare' is not a state of 'App'", 32},
+		{"Function 'StartNextState' of Message 'Go' is not provided by RealTimeApplication 'App'", 37},
+		{"Function 'GOTOSTOP' of Message 'Self' is not an event of StateMachine 'SM' (events: START, GOTORUN, RESET, X)", 48},
+		{"Destination 'Nowhere' of Message 'Lost' does not exist", 49},
+	} {
+		if !hasDiagnostic(v, want.msg, want.line) {
+			t.Errorf("Expected %q on line %d, got %+v", want.msg, want.line, v.Diagnostics)
+		}
+	}
+	if hasDiagnostic(v, "Unknown reference 'PrepareNextState'", 30) || hasDiagnostic(v, "Unknown reference 'ExpectsReply'", 31) {
+		t.Errorf("Message functions and modes are not references: %+v", v.Diagnostics)
+	}
+}
+
+func TestStateMachineDefinition(t *testing.T) {
+	lsp.ResetTestServer()
+	uri := "file://sm.marte"
+	lsp.GetTestDocuments()[uri] = stateMachineContent
+	// Index only: the links come from reference resolution, not from the
+	// validator.
+	config, err := parser.NewParser(stateMachineContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	lsp.GetTestTree().AddFile("sm.marte", config)
+	lsp.GetTestTree().ResolveReferences(nil)
+
+	// NextState = "IDLE" on line 24 jumps to +IDLE on line 42.
+	res := lsp.HandleDefinition(lsp.DefinitionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 23, Character: 19},
+	})
+	locs, ok := res.([]lsp.Location)
+	if !ok || len(locs) != 1 || locs[0].Range.Start.Line != 41 {
+		t.Errorf("Expected the definition of IDLE, got %+v", res)
+	}
+
+	// Destination = "App" on line 36 jumps to +App on line 1.
+	res = lsp.HandleDefinition(lsp.DefinitionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 35, Character: 24},
+	})
+	locs, ok = res.([]lsp.Location)
+	if !ok || len(locs) != 1 || locs[0].Range.Start.Line != 0 {
+		t.Errorf("Expected the definition of App, got %+v", res)
+	}
+
+	// The quoted names are added once, to the references of the file and to
+	// the legacy slice.
+	tree := lsp.GetTestTree()
+	count := func(refs []index.Reference) int {
+		n := 0
+		for _, ref := range refs {
+			if ref.Name == "IDLE" && ref.Position.Line == 24 && ref.Target != nil {
+				n++
+			}
+		}
+		return n
+	}
+	if count(tree.FileReferences["sm.marte"]) != 1 || count(tree.References) != 1 {
+		t.Errorf("Expected the NextState reference once, got %+v", tree.References)
+	}
+}
@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+func checkTiming(t *testing.T, content, schema string, budget float64) (*validator.Validator, []validator.ThreadTiming) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".marte_schema.cue"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("app.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, dir, nil)
+	v.ValidateProject(context.Background())
+	return v, v.CheckTiming(context.Background(), budget)
+}
+
+func TestThreadTiming(t *testing.T) {
+	content := strings.Replace(rtAppContent, "Frequency = 100 ", "Frequency = 10000 ", 1)
+	content = strings.Replace(content, "+Fast = { Class = RealTimeThread", "+Fast = { Class = RealTimeThread CPUs = 0x3", 1)
+	content = strings.Replace(content, "+Late = { Class = RealTimeThread", "+Late = { Class = RealTimeThread CPUs = 0x2", 1)
+	v, timings := checkTiming(t, content, `#Classes: IOGAM: #meta: cost_ns: "2000 + 20000*elements"`+"\n", validator.DefaultTimingBudget)
+
+	if len(timings) != 3 {
+		t.Fatalf("Expected 3 threads, got %+v", timings)
+	}
+	fast := timings[0]
+	if fast.Thread != "Fast" || fast.Frequency != 10000 || fast.CPUs != 3 || fast.CostNs != 84000 || len(fast.GAMs) != 2 || !fast.GAMs[0].Modelled {
+		t.Errorf("Unexpected timing for Fast: %+v", fast)
+	}
+	if timings[2].PeriodNs() != 0 || timings[2].Load() != 0 {
+		t.Errorf("Expected unknown period for Free, got %+v", timings[2])
+	}
+	if !hasDiagnostic(v, "Thread 'Fast' of state 'Run' is estimated at 84000 ns per cycle, 84% of its 100000 ns period (budget 80%)", 40) {
+		t.Errorf("Expected overload warning, got %+v", v.Diagnostics)
+	}
+	if !hasDiagnostic(v, "Threads 'Fast' and 'Late' of state 'Run' share CPUs 0x2", 41) {
+		t.Errorf("Expected shared CPUs warning, got %+v", v.Diagnostics)
+	}
+
+	// A constant model and a larger budget.
+	v, timings = checkTiming(t, content, "#Classes: IOGAM: #meta: cost_ns: 1000\n", 0.9)
+	if timings[0].CostNs != 2000 {
+		t.Errorf("Expected 2000 ns for Fast, got %+v", timings[0])
+	}
+	for _, d := range v.Diagnostics {
+		if strings.Contains(d.Message, "ns per cycle") {
+			t.Errorf("Unexpected diagnostic: %s", d.Message)
+		}
+	}
+}
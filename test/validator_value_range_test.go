@@ -0,0 +1,125 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/lsp"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/schema"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+func TestTypeRange(t *testing.T) {
+	cases := map[string]string{
+		"uint8":   "0 .. 255",
+		"int16":   "-32768 .. 32767",
+		"uint64":  "0 .. 18446744073709551615",
+		"int64":   "-9223372036854775808 .. 9223372036854775807",
+		"float32": "-3.4028234663852886e+38 .. 3.4028234663852886e+38",
+		"bool":    "0 .. 1",
+		"string":  "",
+	}
+	for typ, want := range cases {
+		if got := index.TypeRange(typ); got != want {
+			t.Errorf("TypeRange(%s) = %q, want %q", typ, got, want)
+		}
+	}
+}
+
+func TestValidatorValueRange(t *testing.T) {
+	content := `#var Level: int32 = 300
++Data = {
+    Class = ReferenceContainer
+    +DS = {
+        Class = GAMDataSource
+        Signals = {
+            Good = { Type = uint8 NumberOfElements = 3 Default = { 0 128 255 } }
+            GoodBig = { Type = uint64 Default = 18446744073709551615 }
+            GoodFloat = { Type = float32 Default = -3.0e38 }
+            TooBig = { Type = uint8 Default = 300 }
+            Negative = { Type = uint32 NumberOfElements = 2 Default = { 1, -1 } }
+            Overflow = { Type = int16 Default = 100 * 1000 }
+            FromVar = { Type = uint8 Default = @Level }
+            BigFloat = { Type = float32 Default = 1e39 }
+            BigUint = { Type = uint64 Default = 18446744073709551616 }
+        }
+    }
+}
+
++Constants = {
+    Class = ConstantGAM
+    OutputSignals = {
+        Gain = { DataSource = DS Type = int8 Default = -129 }
+    }
+}
+`
+	config, err := parser.NewParser(content).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("range.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+
+	expected := map[string]string{
+		"TooBig":   "contains '300' which is out of range for uint8 (0 .. 255)",
+		"Negative": "contains '-1' which is out of range for uint32 (0 .. 4294967295)",
+		"Overflow": "contains '100000' which is out of range for int16 (-32768 .. 32767)",
+		"FromVar":  "contains '300' which is out of range for uint8",
+		"BigFloat": "contains '1e39' which is out of range for float32",
+		"BigUint":  "contains '18446744073709551616' which is out of range for uint64",
+		"Gain":     "contains '-129' which is out of range for int8 (-128 .. 127)",
+	}
+	found := map[string]bool{}
+	for _, d := range v.Diagnostics {
+		for sig, msg := range expected {
+			if strings.Contains(d.Message, "signal '"+sig+"'") && strings.Contains(d.Message, msg) {
+				found[sig] = true
+			}
+		}
+		for _, good := range []string{"'Good'", "'GoodBig'", "'GoodFloat'"} {
+			if strings.Contains(d.Message, "signal "+good) {
+				t.Errorf("Unexpected diagnostic for %s: %s", good, d.Message)
+			}
+		}
+	}
+	for sig, msg := range expected {
+		if !found[sig] {
+			t.Errorf("Expected diagnostic for %s: %s, got %+v", sig, msg, v.Diagnostics)
+		}
+	}
+	// Values taken from variables are reported where they are used.
+	if !hasDiagnostic(v, "Default of signal 'FromVar' contains '300' which is out of range for uint8 (0 .. 255)", 13) {
+		t.Errorf("Expected FromVar diagnostic at its Default, got %+v", v.Diagnostics)
+	}
+}
+
+func TestHoverTypeRange(t *testing.T) {
+	lsp.ResetTestServer()
+	lsp.GlobalSchema = schema.LoadFullSchema(".")
+	uri := "file://range.marte"
+	lsp.HandleDidOpen(lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Text: `+DS = {
+  Class = GAMDataSource
+  Signals = {
+    Level = { Type = int16 }
+  }
+}
+`},
+	})
+	hover := lsp.HandleHover(lsp.HoverParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: 3, Character: 5},
+	})
+	if hover == nil {
+		t.Fatal("Expected hover on Level")
+	}
+	if content := hover.Contents.(lsp.MarkupContent).Value; !strings.Contains(content, "**Range**: `-32768 .. 32767`") {
+		t.Errorf("Expected range in hover, got %q", content)
+	}
+}
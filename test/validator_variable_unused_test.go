@@ -0,0 +1,107 @@
+package integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const variableUsageContent = `//! allow(unknown_class)
+#var Count: int = 3
+#var Spare: int = 1
+#let Ts: float64 = 0.001
+#let Period: float64 = @Ts * 2
+
+#template Channel(ID: int, Count: int = 1)
+  Id = @ID
+  Size = @Count
+#end
+
+#template Unused(X: int)
+  Value = @X
+#end
+
+#template Base(Gain: float64 = 1.0)
+  Gain = @Gain
+#end
+
+#template Derived(Gain: float64 = 2.0) extends Base
+#end
+
++Config = {
+  Class = Test
+  Period = @Period
+  #foreach I in { 1, 2 }
+    #foreach I in { 3, 4 }
+      #use Channel C(ID = @I)
+    #end
+  #end
+  #foreach Ts in { 5 }
+    Last = @Ts
+  #end
+  #use Derived D()
+}
+`
+
+func validateVariableUsage(t *testing.T, overrides map[string]string) *validator.Validator {
+	t.Helper()
+	config, err := parser.NewParser(variableUsageContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("vars.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", overrides)
+	v.ValidateProject(context.Background())
+	return v
+}
+
+func TestVariableUsage(t *testing.T) {
+	v := validateVariableUsage(t, nil)
+
+	expected := []struct {
+		rule, msg string
+		line      int
+	}{
+		{"unused_variable", "Unused Variable: 'Spare' is defined but never referenced", 3},
+		{"unused_template", "Unused Template: 'Unused' is defined but never used by #use or extends", 12},
+		{"shadowed_variable", "Parameter 'Count' of template 'Channel' shadows variable 'Count' defined at vars.marte:2:1", 7},
+		{"shadowed_variable", "Loop variable 'I' shadows loop variable 'I' defined at vars.marte:26:3", 27},
+		{"shadowed_variable", "Loop variable 'Ts' shadows constant 'Ts' defined at vars.marte:4:1", 31},
+	}
+	for _, e := range expected {
+		found := false
+		for _, d := range findRule(v, e.rule) {
+			if d.Message == e.msg && d.Position.Line == e.line && d.Level == validator.LevelWarning {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s %q at line %d, got %+v", e.rule, e.msg, e.line, v.Diagnostics)
+		}
+	}
+	count := 0
+	for _, d := range v.Diagnostics {
+		switch d.Rule {
+		case "unused_variable", "unused_template", "shadowed_variable", "unknown_override":
+			count++
+		}
+	}
+	if count != len(expected) {
+		t.Errorf("Expected %d diagnostics, got %+v", len(expected), v.Diagnostics)
+	}
+}
+
+func TestUnknownVariableOverride(t *testing.T) {
+	v := validateVariableUsage(t, map[string]string{"Count": "4", "Cuont": "4", "Ts": "0.01"})
+	d := findRule(v, "unknown_override")
+	if len(d) != 2 || d[0].Level != validator.LevelError ||
+		d[0].Message != "Unknown variable 'Cuont' in override -vCuont=4: no #var defines it" ||
+		d[1].Message != "Unknown variable 'Ts' in override -vTs=0.01: no #var defines it" {
+		t.Errorf("Expected the overrides of Cuont and of the constant Ts to be reported, got %+v", d)
+	}
+}
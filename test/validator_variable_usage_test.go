@@ -62,8 +62,7 @@ func TestVariableValidation(t *testing.T) {
 	foundUsageError := false
 	for _, d := range v2.Diagnostics {
 		// Schema validation error
-		if strings.Contains(d.Message, "Schema Validation Error") && 
-		   (strings.Contains(d.Message, "conflicting values") || strings.Contains(d.Message, "mismatched types")) {
+		if d.Rule == "schema_type_mismatch" && strings.Contains(d.Message, "Field 'Kp' of class 'PIDGAM'") {
 			foundUsageError = true
 		}
 	}
@@ -95,7 +94,7 @@ func TestVariableValidation(t *testing.T) {
 	v3.ValidateProject(context.Background())
 	
 	for _, d := range v3.Diagnostics {
-		if strings.Contains(d.Message, "Schema Validation Error") {
+		if strings.HasPrefix(d.Rule, "schema_") {
 			t.Errorf("Unexpected schema error: %s", d.Message)
 		}
 	}
@@ -0,0 +1,275 @@
+package integration
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/marte-community/marte-dev-tools/internal/index"
+	"github.com/marte-community/marte-dev-tools/internal/parser"
+	"github.com/marte-community/marte-dev-tools/internal/validator"
+)
+
+const variantsContent = `#var Mode: "Sim" | "HW" = "Sim"
+#var Debug: bool = false
+
++App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timings = { Class = TimingDataSource }
+    +Timer = {
+      Class = LinuxTimer
+      Signals = {
+        Counter = { Type = uint32 }
+        Time = { Type = uint32 }
+      }
+    }
+    +Spare = {
+      Class = LinuxTimer
+      Signals = {
+        Counter = { Type = uint32 }
+      }
+    }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Read = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = Timer Type = uint32 } }
+      OutputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+    }
+    +Sim = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { SimOut = { DataSource = DDB Type = uint32 } }
+    }
+    +HW = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { HWOut = { DataSource = DDB Type = uint32 } }
+    }
+    +Log = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { LogOut = { DataSource = DDB Type = uint32 } }
+    }
+    +Orphan = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { OrphanOut = { DataSource = DDB Type = uint32 } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Main = {
+          Class = RealTimeThread
+          #if @Mode == "Sim"
+            Functions = { Read Sim }
+          #else
+            Functions = { Read HW }
+          #end
+        }
+        #if @Debug
+          +Debug = {
+            Class = RealTimeThread
+            Functions = { Log }
+          }
+        #end
+        #if @Mode == "Off"
+          +Fast = {
+            Class = RealTimeThread
+            Functions = { Orphan }
+          }
+        #end
+        #if @Mode == "Sim" || @Mode == "HW"
+          +Extra = {
+            Class = RealTimeThread
+            Functions = { Read }
+          }
+        #else
+          +Never = {
+            Class = RealTimeThread
+            Functions = { Orphan }
+          }
+        #end
+      }
+    }
+  }
+  +Scheduler = {
+    Class = GAMScheduler
+    TimingDataSource = Timings
+  }
+}
+`
+
+func deadDiagnostics(v *validator.Validator) []validator.Diagnostic {
+	var dead []validator.Diagnostic
+	for _, d := range v.Diagnostics {
+		if strings.HasPrefix(d.Rule, "dead_") {
+			dead = append(dead, d)
+		}
+	}
+	return dead
+}
+
+func TestCheckVariants(t *testing.T) {
+	config, err := parser.NewParser(variantsContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("variants.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+
+	n, err := v.CheckVariants(context.Background())
+	if err != nil {
+		t.Fatalf("CheckVariants failed: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("Expected 4 variants of Mode and Debug, got %d", n)
+	}
+
+	expected := []struct {
+		rule, msg string
+		line      int
+	}{
+		{"dead_gam", "Dead GAM: +Orphan is not referenced in any thread or scheduler in any variant", 47},
+		{"dead_datasource", "Dead DataSource: +Spare is not used in any variant", 18},
+		{"dead_signal", "Dead Signal: Time of DataSource +Timer is not referenced in any variant", 15},
+		{"dead_branch", "Dead Branch: the #if condition is false in every variant, so its branch is never taken", 73},
+		{"dead_branch", "Dead Branch: the #if condition is true in every variant, so its #else branch is never taken", 79},
+	}
+	dead := 0
+	for _, d := range deadDiagnostics(v) {
+		found := false
+		for _, e := range expected {
+			if d.Rule == e.rule && d.Message == e.msg && d.Position.Line == e.line {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Unexpected diagnostic %s %q at line %d", d.Rule, d.Message, d.Position.Line)
+		}
+		dead++
+	}
+	if dead != len(expected) {
+		t.Errorf("Expected %d variant diagnostics, got %+v", len(expected), deadDiagnostics(v))
+	}
+
+	// The GAMs of the default variant are still the active ones.
+	for _, d := range findRule(v, "unused_gam") {
+		if d.Message == "Unused GAM: +Read is defined but not referenced in any thread or scheduler" {
+			t.Errorf("CheckVariants did not restore the active configuration: %q", d.Message)
+		}
+	}
+}
+
+func TestCheckVariantsPinnedByOverride(t *testing.T) {
+	config, err := parser.NewParser(variantsContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("variants.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", map[string]string{"Mode": "HW"})
+	v.ValidateProject(context.Background())
+
+	n, err := v.CheckVariants(context.Background())
+	if err != nil {
+		t.Fatalf("CheckVariants failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 variants of Debug, got %d", n)
+	}
+	found := false
+	for _, d := range deadDiagnostics(v) {
+		if d.Rule == "dead_gam" && d.Message == "Dead GAM: +Sim is not referenced in any thread or scheduler in any variant" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected +Sim to be dead with Mode pinned to HW, got %+v", deadDiagnostics(v))
+	}
+}
+
+const freeVariableContent = `#var NumChannels: int = 4
+
++App = {
+  Class = RealTimeApplication
+  +Data = {
+    Class = ReferenceContainer
+    DefaultDataSource = DDB
+    +DDB = { Class = GAMDataSource }
+    +Timings = { Class = TimingDataSource }
+  }
+  +Functions = {
+    Class = ReferenceContainer
+    +Read = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { Out = { DataSource = DDB Type = uint32 } }
+    }
+    +Single = {
+      Class = IOGAM
+      InputSignals = { Counter = { DataSource = DDB Type = uint32 } }
+      OutputSignals = { SingleOut = { DataSource = DDB Type = uint32 } }
+    }
+  }
+  +States = {
+    Class = ReferenceContainer
+    +Run = {
+      Class = RealTimeState
+      +Threads = {
+        Class = ReferenceContainer
+        +Main = {
+          Class = RealTimeThread
+          #if (@NumChannels > 2)
+            Functions = { Read }
+          #else
+            Functions = { Single }
+          #end
+        }
+      }
+    }
+  }
+  +Scheduler = {
+    Class = GAMScheduler
+    TimingDataSource = Timings
+  }
+}
+`
+
+func TestCheckVariantsFreeVariable(t *testing.T) {
+	config, err := parser.NewParser(freeVariableContent).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	pt := index.NewProjectTree()
+	pt.AddFile("free.marte", config)
+	pt.ResolveReferences(nil)
+	v := validator.NewValidator(pt, ".", nil)
+	v.ValidateProject(context.Background())
+
+	n, err := v.CheckVariants(context.Background())
+	if err != nil {
+		t.Fatalf("CheckVariants failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Expected a single variant, got %d", n)
+	}
+	// NumChannels can be overridden with any int, so either branch may be
+	// taken and +Single may be used.
+	if dead := deadDiagnostics(v); len(dead) != 0 {
+		t.Errorf("Expected no variant diagnostics, got %+v", dead)
+	}
+}